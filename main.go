@@ -16,12 +16,15 @@ import (
 	"github.com/AdmiralBulldogTv/DiscordBot/src/modules"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/monitoring"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/guilds"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/prometheus"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/redis"
 
 	"github.com/bugsnag/panicwrap"
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var (
@@ -93,7 +96,122 @@ func main() {
 			URI:      gCtx.Config().Mongo.URI,
 			Database: gCtx.Config().Mongo.Database,
 			Direct:   gCtx.Config().Mongo.Direct,
-			Indexes:  []mongo.IndexRef{},
+			Indexes: []mongo.IndexRef{
+				{
+					Collection: mongo.CollectionNameMembers,
+					Index: mongo.IndexModel{
+						Keys:    bson.D{{Key: "guild_id", Value: 1}, {Key: "user_id", Value: 1}},
+						Options: options.Index().SetUnique(true),
+					},
+				},
+				{
+					Collection: mongo.CollectionNameMembers,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "guild_id", Value: 1}, {Key: "modules.points.points", Value: -1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNamePointsLedger,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "guild_id", Value: 1}, {Key: "created_at", Value: -1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNamePointsLedger,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "guild_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNamePointsSeasons,
+					Index: mongo.IndexModel{
+						Keys:    bson.D{{Key: "guild_id", Value: 1}, {Key: "number", Value: -1}},
+						Options: options.Index().SetUnique(true),
+					},
+				},
+				{
+					Collection: mongo.CollectionNameSeasonStandings,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "season_id", Value: 1}, {Key: "rank", Value: 1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNameSeasonStandings,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "guild_id", Value: 1}, {Key: "user_id", Value: 1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNameShopItems,
+					Index: mongo.IndexModel{
+						Keys:    bson.D{{Key: "guild_id", Value: 1}, {Key: "name", Value: 1}},
+						Options: options.Index().SetUnique(true),
+					},
+				},
+				{
+					Collection: mongo.CollectionNameShopRedemptions,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "guild_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNameShopRedemptions,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNameShopRedemptions,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "guild_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "item._id", Value: 1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNameInhouseMatches,
+					Index: mongo.IndexModel{
+						Keys:    bson.D{{Key: "guild_id", Value: 1}, {Key: "number", Value: -1}},
+						Options: options.Index().SetUnique(true),
+					},
+				},
+				{
+					Collection: mongo.CollectionNameInhouseMatches,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "status", Value: 1}, {Key: "finished_at", Value: 1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNameInhouseMatches,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "radiant.user_id", Value: 1}, {Key: "finished_at", Value: -1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNameInhouseMatches,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "dire.user_id", Value: 1}, {Key: "finished_at", Value: -1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNameInhouseMatches,
+					Index: mongo.IndexModel{
+						Keys:    bson.D{{Key: "voice_channels", Value: 1}},
+						Options: options.Index().SetSparse(true),
+					},
+				},
+				{
+					Collection: mongo.CollectionNameUsers,
+					Index: mongo.IndexModel{
+						Keys: bson.D{{Key: "modules.inhouse.rating", Value: -1}},
+					},
+				},
+				{
+					Collection: mongo.CollectionNameCommandAliases,
+					Index: mongo.IndexModel{
+						Keys:    bson.D{{Key: "guild_id", Value: 1}, {Key: "alias", Value: 1}},
+						Options: options.Index().SetUnique(true),
+					},
+				},
+			},
 		})
 		cancel()
 		if err != nil {
@@ -103,6 +221,26 @@ func main() {
 		gCtx.Inst().Mongo = mongoInst
 	}
 
+	{
+		ctx, cancel := context.WithTimeout(gCtx, time.Second*15)
+		guildsInst, err := guilds.New(ctx, guilds.SetupOptions{
+			Mongo:   gCtx.Inst().Mongo,
+			Primary: gCtx.Config().Discord.GuildID,
+			Defaults: configure.Guild{
+				AdminRoles:    gCtx.Config().Discord.AdminRoles,
+				Prefixes:      gCtx.Config().Discord.Prefixes,
+				MentionPrefix: gCtx.Config().Discord.MentionPrefix,
+				Modules:       gCtx.Config().Modules,
+			},
+		})
+		cancel()
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to load guilds")
+		}
+
+		gCtx.Inst().Guilds = guildsInst
+	}
+
 	{
 		gCtx.Inst().Prometheus = prometheus.New(prometheus.SetupOptions{
 			Labels: prometheus.LabelsFromKeyValue(gCtx.Config().Monitoring.Labels),
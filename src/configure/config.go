@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
@@ -88,10 +89,13 @@ type Config struct {
 	NoHeader   bool   `mapstructure:"noheader" json:"noheader"`
 
 	Discord struct {
-		GuildID    string   `mapstructure:"guild_id" json:"guild_id"`
-		Token      string   `mapstructure:"token" json:"token"`
-		AdminRoles []string `mapstructure:"admin_roles" json:"admin_roles"`
-		Logging    struct {
+		// GuildID is the primary guild, modules which are bound to a single guild use it.
+		GuildID       string   `mapstructure:"guild_id" json:"guild_id"`
+		Token         string   `mapstructure:"token" json:"token"`
+		AdminRoles    []string `mapstructure:"admin_roles" json:"admin_roles"`
+		Prefixes      []string `mapstructure:"prefixes" json:"prefixes"`
+		MentionPrefix bool     `mapstructure:"mention_prefix" json:"mention_prefix"`
+		Logging       struct {
 			Enabled   bool   `mapstructure:"enabled" json:"enabled"`
 			ChannelID string `mapstructure:"channel_id" json:"channel_id"`
 			Debug     bool   `mapstructure:"debug" json:"debug"`
@@ -128,68 +132,145 @@ type Config struct {
 		Bind    string `mapstructure:"bind" json:"bind"`
 	} `mapstructure:"health" json:"health"`
 
-	Modules struct {
-		Points struct {
-			Enabled          bool     `mapstructure:"enabled" json:"enabled"`
-			HourlyLimit      int      `mapstructure:"hourly_limit" json:"hourly_limit"`
-			DailyLimit       int      `mapstructure:"daily_limit" json:"daily_limit"`
-			WeeklyLimit      int      `mapstructure:"weekly_limit" json:"weekly_limit"`
-			PointsPerMessage int      `mapstructure:"points_per_message" json:"points_per_message"`
-			RequiredRoleIDs  []string `mapstructure:"required_role_ids" json:"required_role_ids"`
-			Roles            []struct {
-				ID     string `mapstructure:"id" json:"id"`
-				Points int    `mapstructure:"points" json:"points"`
-			} `mapstructure:"roles" json:"roles"`
-		} `mapstructure:"points" json:"points"`
-		Common struct {
-			Enabled         bool   `mapstructure:"enabled" json:"enabled"`
-			DankRoleID      string `mapstructure:"dank_role_id" json:"dank_role_id"`
-			BasedRoleID     string `mapstructure:"based_role_id" json:"based_role_id"`
-			BasedRoleColors []int  `mapstructure:"based_role_colors" json:"based_role_colors"`
-		} `mapstructure:"common" json:"common"`
-		GoodNight struct {
-			Enabled bool `mapstructure:"enabled" json:"enabled"`
-		} `mapstructure:"goodnight" json:"goodnight"`
-		InHouse struct {
-			Enabled         bool     `mapstructure:"enabled" json:"enabled"`
-			InhouseRoleID   string   `mapstructure:"inhouse_role_id" json:"inhouse_role_id"`
-			GoldRoleID      string   `mapstructure:"gold_role_id" json:"gold_role_id"`
-			RequiredRoleIDs []string `mapstructure:"required_role_ids" json:"required_role_ids"`
-		} `mapstructure:"inhouse" json:"inhouse"`
-		Tracker struct {
-			Enabled      bool     `mapstructure:"enabled" json:"enabled"`
-			SubRoles     []string `mapstructure:"sub_roles" json:"sub_roles"`
-			SpecialRoles []string `mapstructure:"special_roles" json:"special_roles"`
-			Discord      struct {
-				ClientID     string `mapstructure:"client_id" json:"client_id"`
-				ClientSecret string `mapstructure:"client_secret" json:"client_secret"`
-				RedirectURL  string `mapstructure:"redirect_url" json:"redirect_url"`
-			} `mapstructure:"discord" json:"discord"`
-			HTTP struct {
-				CookieDomain string `mapstructure:"cookie_domain" json:"cookie_domain"`
-				CookieSecure bool   `mapstructure:"cookie_secure" json:"cookie_secure"`
-				Bind         string `mapstructure:"bind" json:"bind"`
-			} `mapstructure:"http" json:"http"`
-			Steam struct {
-				ApiKey string `mapstructure:"api_key" json:"api_key"`
-				Main   struct {
-					Username   string `mapstructure:"username" json:"username"`
-					Password   string `mapstructure:"password" json:"password"`
-					TotpSecret string `mapstructure:"totp_secret" json:"totp_secret"`
-				} `mapstructure:"main" json:"main"`
-				Games struct {
-					Username   string `mapstructure:"username" json:"username"`
-					Password   string `mapstructure:"password" json:"password"`
-					TotpSecret string `mapstructure:"totp_secret" json:"totp_secret"`
-				} `mapstructure:"games" json:"games"`
-				Dota struct {
-					Username   string `mapstructure:"username" json:"username"`
-					Password   string `mapstructure:"password" json:"password"`
-					TotpSecret string `mapstructure:"totp_secret" json:"totp_secret"`
-				} `mapstructure:"dota" json:"dota"`
-			} `mapstructure:"steam" json:"steam"`
-		} `mapstructure:"tracker" json:"tracker"`
-	} `mapstructure:"modules" json:"modules"`
+	Modules Modules `mapstructure:"modules" json:"modules"`
+}
+
+// Guild is the configuration which can be different for every guild, the yaml config is used as the defaults.
+// Guilds other than the primary guild start without admin roles and with every module disabled.
+type Guild struct {
+	AdminRoles    []string `mapstructure:"admin_roles" json:"admin_roles" bson:"admin_roles"`
+	Prefixes      []string `mapstructure:"prefixes" json:"prefixes" bson:"prefixes"`
+	MentionPrefix bool     `mapstructure:"mention_prefix" json:"mention_prefix" bson:"mention_prefix"`
+	Modules       Modules  `mapstructure:"modules" json:"modules" bson:"modules"`
+}
+
+// Modules is the configuration of the modules, guilds can override it.
+// Fields which can not be overridden by a guild are skipped by bson.
+type Modules struct {
+	Points    PointsModule    `mapstructure:"points" json:"points" bson:"points,omitempty"`
+	Common    CommonModule    `mapstructure:"common" json:"common" bson:"common,omitempty"`
+	GoodNight GoodNightModule `mapstructure:"goodnight" json:"goodnight" bson:"goodnight,omitempty"`
+	InHouse   InHouseModule   `mapstructure:"inhouse" json:"inhouse" bson:"inhouse,omitempty"`
+	Tracker   TrackerModule   `mapstructure:"tracker" json:"tracker" bson:"tracker,omitempty"`
+}
+
+type PointsModule struct {
+	Enabled          bool         `mapstructure:"enabled" json:"enabled" bson:"enabled"`
+	HourlyLimit      int          `mapstructure:"hourly_limit" json:"hourly_limit" bson:"hourly_limit"`
+	DailyLimit       int          `mapstructure:"daily_limit" json:"daily_limit" bson:"daily_limit"`
+	WeeklyLimit      int          `mapstructure:"weekly_limit" json:"weekly_limit" bson:"weekly_limit"`
+	PointsPerMessage int          `mapstructure:"points_per_message" json:"points_per_message" bson:"points_per_message"`
+	RequiredRoleIDs  []string     `mapstructure:"required_role_ids" json:"required_role_ids" bson:"required_role_ids"`
+	ModeratorRoles   []string     `mapstructure:"moderator_roles" json:"moderator_roles" bson:"moderator_roles"`
+	Roles            []PointsRole `mapstructure:"roles" json:"roles" bson:"roles"`
+	// messages shorter than MinMessageLength letters, sent within MessageInterval of the last counted message
+	// or similar to one of the last DuplicateHistory messages of a member do not give points.
+	MinMessageLength   int                 `mapstructure:"min_message_length" json:"min_message_length" bson:"min_message_length"`
+	MessageInterval    time.Duration       `mapstructure:"message_interval" json:"message_interval" bson:"message_interval"`
+	DuplicateHistory   int                 `mapstructure:"duplicate_history" json:"duplicate_history" bson:"duplicate_history"`
+	ExcludedChannels   []string            `mapstructure:"excluded_channels" json:"excluded_channels" bson:"excluded_channels"`
+	ChannelMultipliers []ChannelMultiplier `mapstructure:"channel_multipliers" json:"channel_multipliers" bson:"channel_multipliers"`
+	// DecayPercent of the points of a member are taken for every week they did not earn points, 0 disables decay.
+	DecayPercent int `mapstructure:"decay_percent" json:"decay_percent" bson:"decay_percent"`
+	// the top SeasonChampions members of a season get the champion role when it is closed,
+	// SeasonCarryOver is the percentage of the points which is kept for the next season.
+	SeasonChampionRoleID string `mapstructure:"season_champion_role_id" json:"season_champion_role_id" bson:"season_champion_role_id"`
+	SeasonChampions      int    `mapstructure:"season_champions" json:"season_champions" bson:"season_champions"`
+	SeasonCarryOver      int    `mapstructure:"season_carry_over" json:"season_carry_over" bson:"season_carry_over"`
+	// VoicePointsPerMinute is given for every minute in a voice channel with others, 0 disables voice points.
+	VoicePointsPerMinute int `mapstructure:"voice_points_per_minute" json:"voice_points_per_minute" bson:"voice_points_per_minute"`
+	// TransferDailyLimit is how many points a user can give away per day, 0 disables giving points.
+	TransferDailyLimit    int           `mapstructure:"transfer_daily_limit" json:"transfer_daily_limit" bson:"transfer_daily_limit"`
+	TransferMinAccountAge time.Duration `mapstructure:"transfer_min_account_age" json:"transfer_min_account_age" bson:"transfer_min_account_age"`
+}
+
+type PointsRole struct {
+	ID     string `mapstructure:"id" json:"id" bson:"id"`
+	Points int    `mapstructure:"points" json:"points" bson:"points"`
+}
+
+// ChannelMultiplier changes the points given for messages in a channel, or in every channel of a category.
+type ChannelMultiplier struct {
+	ID         string  `mapstructure:"id" json:"id" bson:"id"`
+	Multiplier float64 `mapstructure:"multiplier" json:"multiplier" bson:"multiplier"`
+}
+
+type CommonModule struct {
+	Enabled         bool          `mapstructure:"enabled" json:"enabled" bson:"enabled"`
+	DankRoleID      string        `mapstructure:"dank_role_id" json:"dank_role_id" bson:"dank_role_id"`
+	DankCooldown    time.Duration `mapstructure:"dank_cooldown" json:"dank_cooldown" bson:"dank_cooldown"`
+	BasedRoleID     string        `mapstructure:"based_role_id" json:"based_role_id" bson:"based_role_id"`
+	BasedRoleColors []int         `mapstructure:"based_role_colors" json:"based_role_colors" bson:"based_role_colors"`
+	BasedCooldown   time.Duration `mapstructure:"based_cooldown" json:"based_cooldown" bson:"based_cooldown"`
+}
+
+type GoodNightModule struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled" bson:"enabled"`
+}
+
+type InHouseModule struct {
+	Enabled         bool     `mapstructure:"enabled" json:"enabled" bson:"enabled"`
+	InhouseRoleID   string   `mapstructure:"inhouse_role_id" json:"inhouse_role_id" bson:"inhouse_role_id"`
+	GoldRoleID      string   `mapstructure:"gold_role_id" json:"gold_role_id" bson:"gold_role_id"`
+	RequiredRoleIDs []string `mapstructure:"required_role_ids" json:"required_role_ids" bson:"required_role_ids"`
+	ModeratorRoles  []string `mapstructure:"moderator_roles" json:"moderator_roles" bson:"moderator_roles"`
+	// QueueChannelID is where the queue and the ready checks are posted, the channel of the first player to queue when empty.
+	QueueChannelID string        `mapstructure:"queue_channel_id" json:"queue_channel_id" bson:"queue_channel_id"`
+	ReadyTimeout   time.Duration `mapstructure:"ready_timeout" json:"ready_timeout" bson:"ready_timeout"`
+	// SeedCaptains puts the two best players with the gold role on different teams as their captains.
+	SeedCaptains bool `mapstructure:"seed_captains" json:"seed_captains" bson:"seed_captains"`
+	// CaptainsMode lets two captains pick the teams instead of balancing them, the best remaining player is picked
+	// for a captain who does not pick within PickTimeout.
+	CaptainsMode bool          `mapstructure:"captains_mode" json:"captains_mode" bson:"captains_mode"`
+	PickTimeout  time.Duration `mapstructure:"pick_timeout" json:"pick_timeout" bson:"pick_timeout"`
+	// VoiceCategoryID is the category the voice channels of the teams are created in, there are none when it is empty.
+	// players waiting in WaitingRoomChannelID are moved into them, the channels are deleted once the match is reported
+	// or nobody was in them for VoiceIdleTimeout.
+	VoiceCategoryID      string        `mapstructure:"voice_category_id" json:"voice_category_id" bson:"voice_category_id"`
+	WaitingRoomChannelID string        `mapstructure:"waiting_room_channel_id" json:"waiting_room_channel_id" bson:"waiting_room_channel_id"`
+	VoiceIdleTimeout     time.Duration `mapstructure:"voice_idle_timeout" json:"voice_idle_timeout" bson:"voice_idle_timeout"`
+	// LobbyEnabled hosts a practice lobby for every match on the dota client of the tracker, the players are invited
+	// through their linked steam accounts and the result is reported once the game is over. the lobby is closed when
+	// the players did not take their slots within LobbyTimeout.
+	LobbyEnabled  bool          `mapstructure:"lobby_enabled" json:"lobby_enabled" bson:"lobby_enabled"`
+	LobbyRegion   int           `mapstructure:"lobby_region" json:"lobby_region" bson:"lobby_region"`
+	LobbyGameMode int           `mapstructure:"lobby_game_mode" json:"lobby_game_mode" bson:"lobby_game_mode"`
+	LobbyTimeout  time.Duration `mapstructure:"lobby_timeout" json:"lobby_timeout" bson:"lobby_timeout"`
+}
+
+type TrackerModule struct {
+	Enabled        bool     `mapstructure:"enabled" json:"enabled" bson:"enabled"`
+	SubRoles       []string `mapstructure:"sub_roles" json:"sub_roles" bson:"sub_roles"`
+	SpecialRoles   []string `mapstructure:"special_roles" json:"special_roles" bson:"special_roles"`
+	ModeratorRoles []string `mapstructure:"moderator_roles" json:"moderator_roles" bson:"moderator_roles"`
+	Discord        struct {
+		ClientID     string `mapstructure:"client_id" json:"client_id"`
+		ClientSecret string `mapstructure:"client_secret" json:"client_secret"`
+		RedirectURL  string `mapstructure:"redirect_url" json:"redirect_url"`
+	} `mapstructure:"discord" json:"discord" bson:"-"`
+	HTTP struct {
+		CookieDomain string `mapstructure:"cookie_domain" json:"cookie_domain"`
+		CookieSecure bool   `mapstructure:"cookie_secure" json:"cookie_secure"`
+		Bind         string `mapstructure:"bind" json:"bind"`
+	} `mapstructure:"http" json:"http" bson:"-"`
+	Steam struct {
+		ApiKey string `mapstructure:"api_key" json:"api_key"`
+		Main   struct {
+			Username   string `mapstructure:"username" json:"username"`
+			Password   string `mapstructure:"password" json:"password"`
+			TotpSecret string `mapstructure:"totp_secret" json:"totp_secret"`
+		} `mapstructure:"main" json:"main"`
+		Games struct {
+			Username   string `mapstructure:"username" json:"username"`
+			Password   string `mapstructure:"password" json:"password"`
+			TotpSecret string `mapstructure:"totp_secret" json:"totp_secret"`
+		} `mapstructure:"games" json:"games"`
+		Dota struct {
+			Username   string `mapstructure:"username" json:"username"`
+			Password   string `mapstructure:"password" json:"password"`
+			TotpSecret string `mapstructure:"totp_secret" json:"totp_secret"`
+		} `mapstructure:"dota" json:"dota"`
+	} `mapstructure:"steam" json:"steam" bson:"-"`
 }
 
 type KeyValue struct {
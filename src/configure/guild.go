@@ -0,0 +1,322 @@
+package configure
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var ErrUnknownKey = errors.New("unknown config key")
+
+// ValidationError is returned for values which can not be used, Reason is shown to the user.
+type ValidationError struct {
+	Key    string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid value for %s: %s", e.Key, e.Reason)
+}
+
+var (
+	durationType          = reflect.TypeOf(time.Duration(0))
+	pointsRoleType        = reflect.TypeOf(PointsRole{})
+	channelMultiplierType = reflect.TypeOf(ChannelMultiplier{})
+)
+
+// GuildKeys returns the keys of every setting a guild can override, nested keys are joined by dots.
+func GuildKeys() []string {
+	keys := []string{}
+	walkKeys(reflect.TypeOf(Guild{}), "", func(key string) {
+		keys = append(keys, key)
+	})
+	sort.Strings(keys)
+
+	return keys
+}
+
+func walkKeys(t reflect.Type, prefix string, fn func(key string)) {
+	for i := 0; i < t.NumField(); i++ {
+		name := bsonName(t.Field(i))
+		if name == "" {
+			continue
+		}
+
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			walkKeys(t.Field(i).Type, prefix+name+".", fn)
+			continue
+		}
+
+		fn(prefix + name)
+	}
+}
+
+func bsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("bson"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+
+	return name
+}
+
+// field returns the field of a key, keys are the ones returned by GuildKeys.
+func (g *Guild) field(key string) (reflect.Value, error) {
+	v := reflect.ValueOf(g).Elem()
+	for _, part := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, ErrUnknownKey
+		}
+
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			if bsonName(v.Type().Field(i)) == part {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return reflect.Value{}, ErrUnknownKey
+		}
+	}
+
+	if v.Kind() == reflect.Struct {
+		return reflect.Value{}, ErrUnknownKey
+	}
+
+	return v, nil
+}
+
+// Value returns the current value of a key.
+func (g *Guild) Value(key string) (interface{}, error) {
+	v, err := g.field(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Interface(), nil
+}
+
+// SetValue sets a key to a value returned by ParseValue.
+func (g *Guild) SetValue(key string, value interface{}) error {
+	v, err := g.field(key)
+	if err != nil {
+		return err
+	}
+
+	val := reflect.ValueOf(value)
+	if !val.IsValid() || val.Type() != v.Type() {
+		return &ValidationError{Key: key, Reason: "wrong type"}
+	}
+
+	v.Set(val)
+	return nil
+}
+
+// ParseValue parses the text form of a value for a key.
+// lists are separated by commas or spaces and can be cleared with "none", points roles are written as role:points.
+func ParseValue(key string, raw string) (interface{}, error) {
+	v, err := (&Guild{}).field(key)
+	if err != nil {
+		return nil, err
+	}
+
+	raw = strings.TrimSpace(raw)
+	invalid := func(reason string, args ...interface{}) error {
+		return &ValidationError{Key: key, Reason: fmt.Sprintf(reason, args...)}
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return nil, invalid("`%s` is not a duration like 5m or 1h30m", raw)
+		}
+
+		return d, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, invalid("`%s` is not true or false", raw)
+		}
+
+		return b, nil
+	case reflect.Int:
+		i, err := strconv.Atoi(raw)
+		if err != nil || i < 0 {
+			return nil, invalid("`%s` is not a positive number", raw)
+		}
+
+		return i, nil
+	case reflect.String:
+		if IsRoleKey(key) {
+			if raw, err = parseRole(key, raw); err != nil {
+				return nil, err
+			}
+		}
+		if IsChannelKey(key) {
+			if raw, err = parseChannel(key, raw); err != nil {
+				return nil, err
+			}
+		}
+
+		return raw, nil
+	case reflect.Slice:
+		items := strings.FieldsFunc(raw, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+		if len(items) == 1 && strings.EqualFold(items[0], "none") {
+			items = nil
+		}
+
+		switch v.Type().Elem() {
+		case reflect.TypeOf(""):
+			values := make([]string, len(items))
+			for i, item := range items {
+				if IsRoleKey(key) {
+					if item, err = parseRole(key, item); err != nil {
+						return nil, err
+					}
+				}
+				if IsChannelKey(key) {
+					if item, err = parseChannel(key, item); err != nil {
+						return nil, err
+					}
+				}
+
+				values[i] = item
+			}
+
+			return values, nil
+		case reflect.TypeOf(0):
+			values := make([]int, len(items))
+			for i, item := range items {
+				n, err := strconv.ParseInt(strings.Replace(item, "#", "0x", 1), 0, 32)
+				if err != nil || n < 0 {
+					return nil, invalid("`%s` is not a positive number", item)
+				}
+
+				values[i] = int(n)
+			}
+
+			return values, nil
+		case pointsRoleType:
+			values := make([]PointsRole, len(items))
+			for i, item := range items {
+				idx := strings.LastIndex(item, ":")
+				if idx == -1 {
+					return nil, invalid("`%s` is not written as role:points", item)
+				}
+
+				id, err := parseRole(key, item[:idx])
+				if err != nil {
+					return nil, err
+				}
+
+				points, err := strconv.Atoi(item[idx+1:])
+				if err != nil || points < 0 {
+					return nil, invalid("`%s` is not a positive number", item[idx+1:])
+				}
+
+				values[i] = PointsRole{ID: id, Points: points}
+			}
+
+			return values, nil
+		case channelMultiplierType:
+			values := make([]ChannelMultiplier, len(items))
+			for i, item := range items {
+				idx := strings.LastIndex(item, ":")
+				if idx == -1 {
+					return nil, invalid("`%s` is not written as channel:multiplier", item)
+				}
+
+				id, err := parseChannel(key, item[:idx])
+				if err != nil {
+					return nil, err
+				}
+
+				multiplier, err := strconv.ParseFloat(item[idx+1:], 64)
+				if err != nil || multiplier < 0 {
+					return nil, invalid("`%s` is not a positive number", item[idx+1:])
+				}
+
+				values[i] = ChannelMultiplier{ID: id, Multiplier: multiplier}
+			}
+
+			return values, nil
+		}
+	}
+
+	return nil, invalid("this setting can not be changed")
+}
+
+// IsRoleKey reports if a key holds role ids.
+func IsRoleKey(key string) bool {
+	return strings.HasSuffix(key, "role_id") || strings.HasSuffix(key, "role_ids") || strings.HasSuffix(key, "roles")
+}
+
+// IsChannelKey reports if a key holds channel ids, categories are channels as well.
+func IsChannelKey(key string) bool {
+	return strings.HasSuffix(key, "channel_id") || strings.HasSuffix(key, "category_id") || strings.HasSuffix(key, "channels") || strings.HasSuffix(key, "channel_multipliers")
+}
+
+// parseChannel accepts a channel id or a channel mention.
+func parseChannel(key string, raw string) (string, error) {
+	id := strings.TrimSuffix(strings.TrimPrefix(raw, "<#"), ">")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return "", &ValidationError{Key: key, Reason: fmt.Sprintf("`%s` is not a channel", raw)}
+	}
+
+	return id, nil
+}
+
+// parseRole accepts a role id or a role mention.
+func parseRole(key string, raw string) (string, error) {
+	id := strings.TrimSuffix(strings.TrimPrefix(raw, "<@&"), ">")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return "", &ValidationError{Key: key, Reason: fmt.Sprintf("`%s` is not a role", raw)}
+	}
+
+	return id, nil
+}
+
+// Validate checks the settings which depend on each other.
+func (g *Guild) Validate() error {
+	for _, v := range g.Prefixes {
+		if v == "" || strings.IndexFunc(v, unicode.IsSpace) != -1 {
+			return &ValidationError{Key: "prefixes", Reason: "prefixes can not be empty or contain spaces"}
+		}
+	}
+
+	points := g.Modules.Points
+	if points.HourlyLimit > points.DailyLimit {
+		return &ValidationError{Key: "modules.points.hourly_limit", Reason: "the hourly limit can not be higher than the daily limit"}
+	}
+
+	if points.DailyLimit > points.WeeklyLimit {
+		return &ValidationError{Key: "modules.points.daily_limit", Reason: "the daily limit can not be higher than the weekly limit"}
+	}
+
+	if points.DecayPercent > 100 {
+		return &ValidationError{Key: "modules.points.decay_percent", Reason: "the decay can not be more than 100 percent"}
+	}
+
+	if points.SeasonCarryOver > 100 {
+		return &ValidationError{Key: "modules.points.season_carry_over", Reason: "the carry over can not be more than 100 percent"}
+	}
+
+	if points.PointsPerMessage > points.HourlyLimit {
+		return &ValidationError{Key: "modules.points.points_per_message", Reason: "the points per message can not be higher than the hourly limit"}
+	}
+
+	return nil
+}
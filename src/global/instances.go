@@ -7,4 +7,6 @@ type Instances struct {
 	Mongo      instance.Mongo
 	Prometheus instance.Prometheus
 	Discord    instance.Discord
+	Guilds     instance.Guilds
+	Modules    instance.Modules
 }
@@ -2,6 +2,7 @@ package health
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/AdmiralBulldogTv/DiscordBot/src/global"
@@ -43,6 +44,16 @@ func New(gCtx global.Context) <-chan struct{} {
 			if err := gCtx.Inst().Mongo.Ping(mongoCtx); err != nil {
 				logrus.Error("mongo down: ", err)
 				ctx.SetStatusCode(503)
+				return
+			}
+
+			// failed modules are retried, so they are reported without failing the health check.
+			if gCtx.Inst().Modules != nil {
+				data, _ := json.Marshal(map[string]interface{}{
+					"modules": gCtx.Inst().Modules.Status(),
+				})
+				ctx.SetContentType("application/json")
+				ctx.SetBody(data)
 			}
 		},
 		GetOnly:          true,
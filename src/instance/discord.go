@@ -12,4 +12,6 @@ type Discord interface {
 	SendPrivateMessage(userID string, msg *discordgo.MessageSend) (*discordgo.Message, error)
 	Member(guildID string, userID string) (*discordgo.Member, error)
 	AddHandler(handler interface{}) func()
+	// Session is for the requests which have no helper here, like editing roles of members outside of commands.
+	Session() *discordgo.Session
 }
@@ -0,0 +1,25 @@
+package instance
+
+import (
+	"context"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/configure"
+	"github.com/bwmarrin/discordgo"
+)
+
+type Guilds interface {
+	// Primary is the guild from the yaml config, modules which are bound to a single guild use it.
+	Primary() string
+	// Config returns the config of a guild, the returned config must not be modified.
+	Config(guildID string) *configure.Guild
+	// Overridden reports if a key of the config of a guild is set instead of using the default.
+	Overridden(guildID string, key string) bool
+	// Set validates and stores a value returned by configure.ParseValue, the change applies immediately.
+	Set(ctx context.Context, guildID string, key string, value interface{}) error
+	// Reset removes the override of a key so that the default is used again.
+	Reset(ctx context.Context, guildID string, key string) error
+	// Reload loads the overrides of every guild again, for changes which were made in the database directly.
+	Reload(ctx context.Context) error
+	// Join stores a guild the bot is in.
+	Join(ctx context.Context, guild *discordgo.Guild) error
+}
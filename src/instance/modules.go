@@ -0,0 +1,31 @@
+package instance
+
+import "time"
+
+type ModuleState string
+
+const (
+	ModuleStateStopped  ModuleState = "stopped"
+	ModuleStateStarting ModuleState = "starting"
+	ModuleStateRunning  ModuleState = "running"
+	ModuleStateStopping ModuleState = "stopping"
+	ModuleStateFailed   ModuleState = "failed"
+)
+
+type ModuleStatus struct {
+	Name  string      `json:"name"`
+	State ModuleState `json:"state"`
+	// Error is the reason the module failed, it is retried at RetryAt.
+	Error    string    `json:"error,omitempty"`
+	Attempts int       `json:"attempts,omitempty"`
+	RetryAt  time.Time `json:"retry_at,omitempty"`
+	Since    time.Time `json:"since"`
+}
+
+type Modules interface {
+	// Status returns the status of every module sorted by name.
+	Status() []ModuleStatus
+	Start(name string) error
+	Stop(name string) error
+	Restart(name string) error
+}
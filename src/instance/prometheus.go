@@ -6,4 +6,7 @@ import (
 
 type Prometheus interface {
 	Register(prometheus.Registerer)
+
+	CommandExecutions() *prometheus.CounterVec
+	CommandDuration() *prometheus.HistogramVec
 }
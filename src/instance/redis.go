@@ -12,6 +12,7 @@ type Redis interface {
 	Subscribe(ctx context.Context, ch chan string, subscribeTo ...string)
 	Get(ctx context.Context, key string) (string, error)
 	Del(ctx context.Context, key string) (int, error)
+	DelIfEquals(ctx context.Context, key string, value string) (bool, error)
 	Set(ctx context.Context, key string, value string) error
 	SetEX(ctx context.Context, key string, value interface{}, ttl time.Duration) error
 	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
@@ -20,4 +21,5 @@ type Redis interface {
 	Exists(ctx context.Context, key string) (bool, error)
 	SAdd(ctx context.Context, key string, value string) error
 	TTL(ctx context.Context, key string) (time.Duration, error)
+	Scan(ctx context.Context, match string) ([]string, error)
 }
@@ -0,0 +1,133 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/instance"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/bwmarrin/discordgo"
+)
+
+func (m *manager) ModulesCmd() command.Cmd {
+	status := m.StatusCmd()
+
+	return &command.CommandGroup{
+		NameCmd: func() string {
+			return "modules"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "modules")
+		},
+		Info:        "Shows the status of the modules and starts or stops them",
+		UsageInfo:   "[command]",
+		ExampleInfo: []string{"modules", "modules restart points"},
+		Perms:       command.AdminPermission,
+		EnabledCmd:  m.primary,
+		Commands: map[string]command.Cmd{
+			"status":  status,
+			"start":   m.actionCmd("start", "Start a module which is stopped", m.Start),
+			"stop":    m.actionCmd("stop", "Stop a module, its commands are removed until it is started again", m.Stop),
+			"restart": m.actionCmd("restart", "Stop and start a module again", m.Restart),
+		},
+		DefaultComnmnd: status,
+	}
+}
+
+// primary limits the modules command to the primary guild, modules are started and stopped for every guild at once.
+func (m *manager) primary(guildID string) bool {
+	return guildID == m.gCtx.Inst().Guilds.Primary()
+}
+
+func (m *manager) StatusCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "modules status"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) == 0 || strings.EqualFold(path[0], "status")
+		},
+		Info:        "Shows the status of every module",
+		ExampleInfo: []string{"modules status"},
+		Perms:       command.AdminPermission,
+		EnabledCmd:  m.primary,
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			lines := []string{}
+			for _, v := range m.Status() {
+				line := fmt.Sprintf("**%s** %s %s", v.Name, v.State, relative(v.Since))
+				if v.State == instance.ModuleStateFailed {
+					line += fmt.Sprintf("\n↳ %s, retry %d %s", v.Error, v.Attempts, relative(v.RetryAt))
+				}
+
+				lines = append(lines, line)
+			}
+
+			_, err := ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:       "Modules",
+					Description: strings.Join(lines, "\n"),
+					Color:       ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID),
+				},
+			})
+			return err
+		},
+	}
+}
+
+func (m *manager) actionCmd(name string, info string, action func(name string) error) command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "modules " + name
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], name)
+		},
+		Info:        info,
+		ExampleInfo: []string{fmt.Sprintf("modules %s points", name)},
+		Perms:       command.AdminPermission,
+		EnabledCmd:  m.primary,
+		Args: []command.Arg{
+			{
+				Name:        "module",
+				Description: "The name of the module",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			module := ctx.Args.String("module")
+
+			err := action(module)
+			switch err {
+			case nil:
+			case ErrUnknownModule:
+				names := []string{}
+				for _, v := range m.Status() {
+					names = append(names, strings.ToLower(v.Name))
+				}
+
+				return ctx.ReplyError(fmt.Sprintf("Unknown module `%s`, the modules are %s.", module, strings.Join(names, ", ")))
+			case ErrModuleRunning, ErrModuleStopped, ErrModuleStopping:
+				return ctx.ReplyError(fmt.Sprintf("Cannot %s `%s`, the %s.", name, module, err.Error()))
+			default:
+				return err
+			}
+
+			_, err = ctx.Reply(fmt.Sprintf("Done, `%s` is %s.", module, m.state(module)))
+			return err
+		},
+	}
+}
+
+func (m *manager) state(name string) instance.ModuleState {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.modules[strings.ToLower(name)].status.State
+}
+
+// relative formats a time which discord shows relative to now.
+func relative(t time.Time) string {
+	return fmt.Sprintf("<t:%d:R>", t.Unix())
+}
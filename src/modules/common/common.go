@@ -4,11 +4,10 @@ import (
 	"fmt"
 	"math/rand"
 	"strings"
-	"time"
 
+	"github.com/AdmiralBulldogTv/DiscordBot/src/configure"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/global"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
-	"github.com/AdmiralBulldogTv/DiscordBot/src/utils"
 	"github.com/bwmarrin/discordgo"
 	"github.com/hashicorp/go-multierror"
 )
@@ -26,12 +25,24 @@ func (m *Module) Register(gCtx global.Context) (<-chan struct{}, error) {
 	m.done = make(chan struct{})
 	m.gCtx = gCtx
 
-	err := multierror.Append(nil, gCtx.Inst().Discord.RegisterCommand("avatar", m.AvatarCmd()))
-	err = multierror.Append(err, gCtx.Inst().Discord.RegisterCommand("dank", m.DankCmd()))
-	err = multierror.Append(err, gCtx.Inst().Discord.RegisterCommand("based", m.BasedCmd()))
+	closeFns := []func(){}
+
+	var err *multierror.Error
+	for prefix, cmd := range map[string]command.Cmd{
+		"avatar": m.AvatarCmd(),
+		"dank":   m.DankCmd(),
+		"based":  m.BasedCmd(),
+	} {
+		closeFn, e := command.Register(gCtx.Inst().Discord, prefix, cmd)
+		closeFns = append(closeFns, closeFn)
+		err = multierror.Append(err, e)
+	}
 
 	go func() {
 		<-gCtx.Done()
+		for _, fn := range closeFns {
+			fn()
+		}
 		close(m.done)
 	}()
 
@@ -42,6 +53,14 @@ func (m *Module) Name() string {
 	return "Common"
 }
 
+func (m *Module) config(guildID string) configure.CommonModule {
+	return m.gCtx.Inst().Guilds.Config(guildID).Modules.Common
+}
+
+func (m *Module) enabled(guildID string) bool {
+	return m.config(guildID).Enabled
+}
+
 func (m *Module) AvatarCmd() command.Cmd {
 	return &command.Command{
 		NameCmd: func() string {
@@ -50,31 +69,26 @@ func (m *Module) AvatarCmd() command.Cmd {
 		MatchCmd: func(path []string) bool {
 			return len(path) != 0 && strings.EqualFold(path[0], "avatar")
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			guild, err := s.State.Guild(msg.GuildID)
-			if err != nil {
-				return err
-			}
-
-			search := strings.TrimSpace(strings.ToLower(strings.Join(path, " ")))
-			member := utils.FindMember(s, guild, msg.Message, search)
-
-			if member == nil && search == "" {
-				msg.Member.User = msg.Author
-				member = msg.Member
-			}
-
+		Info:        "Shows the avatar of a user",
+		EnabledCmd:  m.enabled,
+		ExampleInfo: []string{"avatar", "avatar Troy"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to show the avatar of, defaults to you",
+				Type:        command.ArgMember,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
 			if member == nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
+				member = ctx.Member
 			}
 
-			_, err = s.ChannelMessageSendComplex(msg.ChannelID, &discordgo.MessageSend{
-				Reference: msg.Reference(),
+			_, err := ctx.ReplyComplex(&discordgo.MessageSend{
 				Embed: &discordgo.MessageEmbed{
 					Title: fmt.Sprintf("%s's Avatar", member.User),
-					Color: s.State.UserColor(member.User.ID, msg.ChannelID),
+					Color: ctx.Session.State.UserColor(member.User.ID, ctx.ChannelID),
 					Image: &discordgo.MessageEmbedImage{
 						URL:    member.User.AvatarURL("1024"),
 						Width:  1024,
@@ -96,42 +110,30 @@ func (m *Module) DankCmd() command.Cmd {
 		MatchCmd: func(path []string) bool {
 			return len(path) != 0 && strings.EqualFold(path[0], "dank")
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			found := false
-			for _, v := range msg.Member.Roles {
-				if v == m.gCtx.Config().Modules.Common.DankRoleID {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return nil
-			}
-
-			set, err := m.gCtx.Inst().Redis.SetNX(m.gCtx, "dank-global-cooldown", "1", time.Minute*20)
-			if err != nil {
-				return err
-			}
-			if !set {
-				ttl, err := m.gCtx.Inst().Redis.TTL(m.gCtx, "dank-global-cooldown")
-				if err != nil {
-					return err
-				}
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("Command is on cooldown, try again in %s", (ttl/time.Second)*time.Second), msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
+		Info:        "Changes the color of the dank memers role",
+		ExampleInfo: []string{"dank"},
+		EnabledCmd:  m.enabled,
+		Perms: command.Permission{
+			Roles: func(guildID string) []string {
+				return []string{m.config(guildID).DankRoleID}
+			},
+		},
+		CooldownsCmd: func(guildID string) command.Cooldown {
+			return command.Cooldown{
+				Global: m.config(guildID).DankCooldown,
 			}
-
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
 			var color int
 			for i := 0; i < 3; i++ {
 				color |= rand.Intn(255) << (i * 8)
 			}
 
-			_, err = s.GuildRoleEdit(msg.GuildID, m.gCtx.Config().Modules.Common.DankRoleID, "Dank Memers", color, false, discordgo.PermissionAddReactions, false)
+			_, err := ctx.Session.GuildRoleEdit(ctx.GuildID, m.config(ctx.GuildID).DankRoleID, "Dank Memers", color, false, discordgo.PermissionAddReactions, false)
 			if err != nil {
 				return err
 			}
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("Changed the color of dank memers to #%06x", color), msg.Reference())
+			_, err = ctx.Reply(fmt.Sprintf("Changed the color of dank memers to #%06x", color))
 			return err
 		},
 	}
@@ -145,39 +147,32 @@ func (m *Module) BasedCmd() command.Cmd {
 		MatchCmd: func(path []string) bool {
 			return len(path) != 0 && strings.EqualFold(path[0], "based")
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			found := false
-			for _, v := range msg.Member.Roles {
-				if v == m.gCtx.Config().Modules.Common.BasedRoleID {
-					found = true
-					break
-				}
+		Info:        "Changes the color of the based memers role",
+		ExampleInfo: []string{"based"},
+		EnabledCmd:  m.enabled,
+		Perms: command.Permission{
+			Roles: func(guildID string) []string {
+				return []string{m.config(guildID).BasedRoleID}
+			},
+		},
+		CooldownsCmd: func(guildID string) command.Cooldown {
+			return command.Cooldown{
+				Global: m.config(guildID).BasedCooldown,
 			}
-			if !found || len(m.gCtx.Config().Modules.Common.BasedRoleColors) == 0 {
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			cfg := m.config(ctx.GuildID)
+			if len(cfg.BasedRoleColors) == 0 {
 				return nil
 			}
 
-			set, err := m.gCtx.Inst().Redis.SetNX(m.gCtx, "based-global-cooldown", "1", time.Minute*5)
-			if err != nil {
-				return err
-			}
-			if !set {
-				ttl, err := m.gCtx.Inst().Redis.TTL(m.gCtx, "based-global-cooldown")
-				if err != nil {
-					return err
-				}
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("Command is on cooldown, try again in %s", (ttl/time.Second)*time.Second), msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
-			}
-
-			color := m.gCtx.Config().Modules.Common.BasedRoleColors[rand.Intn(len(m.gCtx.Config().Modules.Common.BasedRoleColors))]
+			color := cfg.BasedRoleColors[rand.Intn(len(cfg.BasedRoleColors))]
 
-			_, err = s.GuildRoleEdit(msg.GuildID, m.gCtx.Config().Modules.Common.BasedRoleID, "Based Memers", color, true, discordgo.PermissionAddReactions, false)
+			_, err := ctx.Session.GuildRoleEdit(ctx.GuildID, cfg.BasedRoleID, "Based Memers", color, true, discordgo.PermissionAddReactions, false)
 			if err != nil {
 				return err
 			}
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("Changed the color of based memers to #%06x", color), msg.Reference())
+			_, err = ctx.Reply(fmt.Sprintf("Changed the color of based memers to #%06x", color))
 			return err
 		},
 	}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/AdmiralBulldogTv/DiscordBot/src/global"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
-	"github.com/AdmiralBulldogTv/DiscordBot/src/utils"
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-redis/redis/v8"
 	"github.com/hashicorp/go-multierror"
@@ -28,10 +27,21 @@ func (m *Module) Register(gCtx global.Context) (<-chan struct{}, error) {
 	m.done = make(chan struct{})
 	m.gCtx = gCtx
 
+	if err := m.migrate(); err != nil {
+		return nil, err
+	}
+
 	closeFns := []func(){}
 
-	err := multierror.Append(nil, gCtx.Inst().Discord.RegisterCommand("gn", m.GnCmd()))
-	err = multierror.Append(err, gCtx.Inst().Discord.RegisterCommand("tuck", m.TuckCmd()))
+	var err *multierror.Error
+	for prefix, cmd := range map[string]command.Cmd{
+		"gn":   m.GnCmd(),
+		"tuck": m.TuckCmd(),
+	} {
+		closeFn, e := command.Register(gCtx.Inst().Discord, prefix, cmd)
+		closeFns = append(closeFns, closeFn)
+		err = multierror.Append(err, e)
+	}
 	closeFns = append(closeFns, gCtx.Inst().Discord.AddHandler(m.onMessage))
 
 	go func() {
@@ -49,8 +59,51 @@ func (m *Module) Name() string {
 	return "GoodNight"
 }
 
+// migrate moves the sleepers which were stored before guilds were supported to the primary guild.
+func (m *Module) migrate() error {
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Minute)
+	defer cancel()
+
+	keys, err := m.gCtx.Inst().Redis.Scan(ctx, "sleepers:*")
+	if err != nil {
+		return err
+	}
+
+	guildID := m.gCtx.Inst().Guilds.Primary()
+	pipe := m.gCtx.Inst().Redis.Pipeline(ctx)
+	n := 0
+	for _, key := range keys {
+		// the old keys are sleepers:<user> followed by :tucked or :mentions, the new ones have the guild before the user.
+		parts := strings.Split(key, ":")
+		if len(parts) != 2 && (len(parts) != 3 || parts[2] != "tucked" && parts[2] != "mentions") {
+			continue
+		}
+
+		// a user who went to sleep again since keeps their new key.
+		pipe.RenameNX(ctx, key, fmt.Sprintf("sleepers:%s:%s", guildID, strings.Join(parts[1:], ":")))
+		pipe.Del(ctx, key)
+		n++
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	if _, err = pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	logrus.Infof("goodnight, migrated %d keys to guild %s", n, guildID)
+
+	return nil
+}
+
+func (m *Module) enabled(guildID string) bool {
+	return m.gCtx.Inst().Guilds.Config(guildID).Modules.GoodNight.Enabled
+}
+
 func (m *Module) onMessage(s *discordgo.Session, msg *discordgo.MessageCreate) {
-	if msg.GuildID != m.gCtx.Config().Discord.GuildID || msg.Author.Bot {
+	if msg.GuildID == "" || msg.Author.Bot || !m.enabled(msg.GuildID) {
 		return
 	}
 
@@ -69,11 +122,11 @@ func (m *Module) onMessage(s *discordgo.Session, msg *discordgo.MessageCreate) {
 	}
 
 	pipe := m.gCtx.Inst().Redis.Pipeline(ctx)
-	getCmd := pipe.Get(ctx, fmt.Sprintf("sleepers:%s", msg.Author.ID))
-	sMembersCmd := pipe.SMembers(ctx, fmt.Sprintf("sleepers:%s:mentions", msg.Author.ID))
-	pipe.Del(ctx, fmt.Sprintf("sleepers:%s", msg.Author.ID))
-	pipe.Del(ctx, fmt.Sprintf("sleepers:%s:tucked", msg.Author.ID))
-	pipe.Del(ctx, fmt.Sprintf("sleepers:%s:mentions", msg.Author.ID))
+	getCmd := pipe.Get(ctx, fmt.Sprintf("sleepers:%s:%s", msg.GuildID, msg.Author.ID))
+	sMembersCmd := pipe.SMembers(ctx, fmt.Sprintf("sleepers:%s:%s:mentions", msg.GuildID, msg.Author.ID))
+	pipe.Del(ctx, fmt.Sprintf("sleepers:%s:%s", msg.GuildID, msg.Author.ID))
+	pipe.Del(ctx, fmt.Sprintf("sleepers:%s:%s:tucked", msg.GuildID, msg.Author.ID))
+	pipe.Del(ctx, fmt.Sprintf("sleepers:%s:%s:mentions", msg.GuildID, msg.Author.ID))
 	_, _ = pipe.Exec(ctx)
 
 	val, err := getCmd.Result()
@@ -128,10 +181,10 @@ func (m *Module) onMessage(s *discordgo.Session, msg *discordgo.MessageCreate) {
 		}
 
 		mentions[v.ID] = true
-		if exists, _ := m.gCtx.Inst().Redis.Exists(ctx, fmt.Sprintf("sleepers:%s", v.ID)); exists {
+		if exists, _ := m.gCtx.Inst().Redis.Exists(ctx, fmt.Sprintf("sleepers:%s:%s", msg.GuildID, v.ID)); exists {
 			perms, _ := s.UserChannelPermissions(v.ID, msg.ChannelID)
 			if perms&discordgo.PermissionViewChannel != 0 {
-				_ = m.gCtx.Inst().Redis.SAdd(ctx, fmt.Sprintf("sleepers:%s:mentions", v.ID), fmt.Sprintf("%s %s %s", msg.ChannelID, msg.ID, msg.GuildID))
+				_ = m.gCtx.Inst().Redis.SAdd(ctx, fmt.Sprintf("sleepers:%s:%s:mentions", msg.GuildID, v.ID), fmt.Sprintf("%s %s %s", msg.ChannelID, msg.ID, msg.GuildID))
 			}
 
 			if st != nil {
@@ -151,41 +204,44 @@ func (m *Module) GnCmd() command.Cmd {
 		MatchCmd: func(path []string) bool {
 			return len(path) != 0 && strings.EqualFold(path[0], "gn")
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			if msg.Member == nil {
+		Info:        "Go to sleep, you will be told who mentioned you when you wake up",
+		ExampleInfo: []string{"gn"},
+		EnabledCmd:  m.enabled,
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			s := ctx.Session
+			if ctx.Member == nil {
 				var err error
-				msg.Member, err = s.GuildMember(m.gCtx.Config().Discord.GuildID, msg.Author.ID)
+				ctx.Member, err = s.GuildMember(ctx.GuildID, ctx.Author.ID)
 				if err != nil {
-					logrus.Errorf("failed to fetch member (%s#%s - %s): %s", msg.Author.Username, msg.Author.Discriminator, msg.Author.ID, err.Error())
+					logrus.Errorf("failed to fetch member (%s#%s - %s): %s", ctx.Author.Username, ctx.Author.Discriminator, ctx.Author.ID, err.Error())
 					return err
 				}
 			}
 
-			nick := msg.Member.Nick
+			nick := ctx.Member.Nick
 			if nick == "" {
-				nick = msg.Author.Username
+				nick = ctx.Author.Username
 			}
-			username := fmt.Sprintf("%s#%s", nick, msg.Author.Discriminator)
+			username := fmt.Sprintf("%s#%s", nick, ctx.Author.Discriminator)
 
-			set, err := m.gCtx.Inst().Redis.SetNX(m.gCtx, fmt.Sprintf("sleepers:%s", msg.Author.ID), time.Now().Format(time.RFC3339), 0)
+			set, err := m.gCtx.Inst().Redis.SetNX(m.gCtx, fmt.Sprintf("sleepers:%s:%s", ctx.GuildID, ctx.Author.ID), time.Now().Format(time.RFC3339), 0)
 			if err != nil {
 				return err
 			}
 
 			if !set {
 				pipe := m.gCtx.Inst().Redis.Pipeline(m.gCtx)
-				getCmd := pipe.Get(m.gCtx, fmt.Sprintf("sleepers:%s", msg.Author.ID))
-				sMembersCmd := pipe.SMembers(m.gCtx, fmt.Sprintf("sleepers:%s:mentions", msg.Author.ID))
-				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s", msg.Author.ID))
-				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s:tucked", msg.Author.ID))
-				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s:mentions", msg.Author.ID))
+				getCmd := pipe.Get(m.gCtx, fmt.Sprintf("sleepers:%s:%s", ctx.GuildID, ctx.Author.ID))
+				sMembersCmd := pipe.SMembers(m.gCtx, fmt.Sprintf("sleepers:%s:%s:mentions", ctx.GuildID, ctx.Author.ID))
+				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s:%s", ctx.GuildID, ctx.Author.ID))
+				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s:%s:tucked", ctx.GuildID, ctx.Author.ID))
+				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s:%s:mentions", ctx.GuildID, ctx.Author.ID))
 				_, _ = pipe.Exec(m.gCtx)
 
 				val, _ := getCmd.Result()
 				sleepDate, _ := time.Parse(time.RFC3339, val)
 				data := &discordgo.MessageSend{
-					Reference: msg.Reference(),
-					Content:   fmt.Sprintf("%s woke up after %s", username, (time.Since(sleepDate)/time.Second)*time.Second),
+					Content: fmt.Sprintf("%s woke up after %s", username, (time.Since(sleepDate)/time.Second)*time.Second),
 				}
 
 				content := []string{}
@@ -202,16 +258,16 @@ func (m *Module) GnCmd() command.Cmd {
 				}
 				if len(content) != 0 {
 					data.Embed = &discordgo.MessageEmbed{
-						Color: s.State.MessageColor(msg.Message),
+						Color: s.State.UserColor(ctx.Author.ID, ctx.ChannelID),
 						Author: &discordgo.MessageEmbedAuthor{
-							Name:    fmt.Sprintf("People who mentioned %s", msg.Author.Username),
-							IconURL: msg.Author.AvatarURL(""),
+							Name:    fmt.Sprintf("People who mentioned %s", ctx.Author.Username),
+							IconURL: ctx.Author.AvatarURL(""),
 						},
 						Description: strings.Join(content, "\n"),
 					}
 				}
 
-				_, err := s.ChannelMessageSendComplex(msg.ChannelID, data)
+				_, err := ctx.ReplyComplex(data)
 				if err != nil {
 					logrus.Error("failed to send message: ", err)
 				}
@@ -219,7 +275,7 @@ func (m *Module) GnCmd() command.Cmd {
 				return err
 			}
 
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("%s has gone to sleep somebody tuck them!", username), msg.Reference())
+			_, err = ctx.Reply(fmt.Sprintf("%s has gone to sleep somebody tuck them!", username))
 			return err
 		},
 	}
@@ -233,39 +289,41 @@ func (m *Module) TuckCmd() command.Cmd {
 		MatchCmd: func(path []string) bool {
 			return len(path) != 0 && strings.EqualFold(path[0], "tuck")
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			guild, err := s.State.Guild(msg.GuildID)
-			if err != nil {
-				return err
-			}
-
-			search := strings.TrimSpace(strings.ToLower(strings.Join(path, " ")))
-			member := utils.FindMember(s, guild, msg.Message, search)
-
-			if member == nil && search == "" {
-				msg.Member.User = msg.Author
-				member = msg.Member
+		Info:        "Tuck a sleeping user into bed",
+		ExampleInfo: []string{"tuck Troy"},
+		EnabledCmd:  m.enabled,
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to tuck in",
+				Type:        command.ArgMember,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			s := ctx.Session
+			member := ctx.Args.Member("user")
+			if member == nil {
+				member = ctx.Member
 			}
 
-			set, err := m.gCtx.Inst().Redis.Exists(m.gCtx, fmt.Sprintf("sleepers:%s", msg.Author.ID))
+			set, err := m.gCtx.Inst().Redis.Exists(m.gCtx, fmt.Sprintf("sleepers:%s:%s", ctx.GuildID, ctx.Author.ID))
 			if err != nil {
 				return err
 			}
 
-			if set && (member == nil || member.User.ID != msg.Author.ID) {
+			if set && (member == nil || member.User.ID != ctx.Author.ID) {
 				pipe := m.gCtx.Inst().Redis.Pipeline(m.gCtx)
-				getCmd := pipe.Get(m.gCtx, fmt.Sprintf("sleepers:%s", msg.Author.ID))
-				sMembersCmd := pipe.SMembers(m.gCtx, fmt.Sprintf("sleepers:%s:mentions", msg.Author.ID))
-				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s", msg.Author.ID))
-				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s:tucked", msg.Author.ID))
-				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s:mentions", msg.Author.ID))
+				getCmd := pipe.Get(m.gCtx, fmt.Sprintf("sleepers:%s:%s", ctx.GuildID, ctx.Author.ID))
+				sMembersCmd := pipe.SMembers(m.gCtx, fmt.Sprintf("sleepers:%s:%s:mentions", ctx.GuildID, ctx.Author.ID))
+				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s:%s", ctx.GuildID, ctx.Author.ID))
+				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s:%s:tucked", ctx.GuildID, ctx.Author.ID))
+				pipe.Del(m.gCtx, fmt.Sprintf("sleepers:%s:%s:mentions", ctx.GuildID, ctx.Author.ID))
 				_, _ = pipe.Exec(m.gCtx)
 
 				val, _ := getCmd.Result()
 				sleepDate, _ := time.Parse(time.RFC3339, val)
 				data := &discordgo.MessageSend{
-					Reference: msg.Reference(),
-					Content:   fmt.Sprintf("%s woke up after %s", msg.Author, (time.Since(sleepDate)/time.Second)*time.Second),
+					Content: fmt.Sprintf("%s woke up after %s", ctx.Author, (time.Since(sleepDate)/time.Second)*time.Second),
 				}
 
 				content := []string{}
@@ -282,16 +340,16 @@ func (m *Module) TuckCmd() command.Cmd {
 				}
 				if len(content) != 0 {
 					data.Embed = &discordgo.MessageEmbed{
-						Color: s.State.MessageColor(msg.Message),
+						Color: s.State.UserColor(ctx.Author.ID, ctx.ChannelID),
 						Author: &discordgo.MessageEmbedAuthor{
-							Name:    fmt.Sprintf("People who mentioned %s", msg.Author.Username),
-							IconURL: msg.Author.AvatarURL(""),
+							Name:    fmt.Sprintf("People who mentioned %s", ctx.Author.Username),
+							IconURL: ctx.Author.AvatarURL(""),
 						},
 						Description: strings.Join(content, "\n"),
 					}
 				}
 
-				_, err := s.ChannelMessageSendComplex(msg.ChannelID, data)
+				_, err := ctx.ReplyComplex(data)
 				if err != nil {
 					logrus.Error("failed to send message: ", err)
 				}
@@ -300,36 +358,24 @@ func (m *Module) TuckCmd() command.Cmd {
 			}
 
 			if member == nil {
-				if search == "" {
-					st, err := s.ChannelMessageSendReply(msg.ChannelID, "Invalid usage: `!tuck <user ...> <message>`", msg.Reference())
-					utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-					return err
-				}
-
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
+				return ctx.ReplyUsage("")
 			}
 
-			if exists, _ := m.gCtx.Inst().Redis.Exists(m.gCtx, fmt.Sprintf("sleepers:%s", member.User.ID)); !exists {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("%s isnt even sleeping.", member.User), msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
+			if exists, _ := m.gCtx.Inst().Redis.Exists(m.gCtx, fmt.Sprintf("sleepers:%s:%s", ctx.GuildID, member.User.ID)); !exists {
+				return ctx.ReplyError(fmt.Sprintf("%s isnt even sleeping.", member.User))
 			}
 
-			set, err = m.gCtx.Inst().Redis.SetNX(m.gCtx, fmt.Sprintf("sleepers:%s:tucked", member.User.ID), "", 0)
+			set, err = m.gCtx.Inst().Redis.SetNX(m.gCtx, fmt.Sprintf("sleepers:%s:%s:tucked", ctx.GuildID, member.User.ID), "", 0)
 			if err != nil {
 				return err
 			}
 
 			if set {
-				_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("%s has tucked %s to bed.", msg.Author.Mention(), member.User), msg.Reference())
+				_, err = ctx.Reply(fmt.Sprintf("%s has tucked %s to bed.", ctx.Author.Mention(), member.User))
 				return err
 			}
 
-			st, err := s.ChannelMessageSendReply(msg.ChannelID, "Tucking the tucked WeirdChamp", msg.Reference())
-			utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-			return err
+			return ctx.ReplyError("Tucking the tucked WeirdChamp")
 		},
 	}
 }
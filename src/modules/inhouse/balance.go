@@ -0,0 +1,164 @@
+package inhouse
+
+import (
+	"context"
+	"math/bits"
+	"math/rand"
+	"sort"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	teamSize      = 5
+	matchSize     = teamSize * 2
+	defaultRating = 1000
+)
+
+// ratings returns the inhouse rating of every user, users who did not play a match yet have the default rating.
+func (m *Module) ratings(ctx context.Context, userIDs []string) (map[string]int32, error) {
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).Find(ctx, bson.M{
+		"discord.id": bson.M{"$in": userIDs},
+	}, options.Find().SetProjection(bson.M{"discord.id": 1, "modules.inhouse": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	users := []structures.User{}
+	if err = cur.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	ratings := map[string]int32{}
+	for _, id := range userIDs {
+		ratings[id] = defaultRating
+	}
+	for _, v := range users {
+		if v.Modules.Inhouse.Rating != 0 {
+			ratings[v.Discord.ID] = v.Modules.Inhouse.Rating
+		}
+	}
+
+	return ratings, nil
+}
+
+// balanceTeams splits the players into the two teams with the smallest difference between their total ratings.
+// when seed is set the two best players with the gold role are put on different teams.
+func balanceTeams(players []structures.InhousePlayer, gold map[string]bool, seed bool) ([]structures.InhousePlayer, []structures.InhousePlayer) {
+	seeded := []int{}
+	if seed {
+		for _, i := range byRating(players) {
+			if gold[players[i].UserID] && len(seeded) < 2 {
+				seeded = append(seeded, i)
+			}
+		}
+	}
+
+	best, bestDiff := 0, int64(-1)
+	for mask := 0; mask < 1<<len(players); mask++ {
+		// the first player is always in the first team, so that every split is only tried once.
+		if bits.OnesCount(uint(mask)) != len(players)/2 || mask&1 == 0 {
+			continue
+		}
+		if len(seeded) == 2 && (mask>>seeded[0])&1 == (mask>>seeded[1])&1 {
+			continue
+		}
+
+		var diff int64
+		for i, p := range players {
+			if (mask>>i)&1 == 1 {
+				diff += int64(p.Rating)
+			} else {
+				diff -= int64(p.Rating)
+			}
+		}
+		if diff < 0 {
+			diff = -diff
+		}
+
+		if bestDiff == -1 || diff < bestDiff {
+			best, bestDiff = mask, diff
+		}
+	}
+
+	a, b := []structures.InhousePlayer{}, []structures.InhousePlayer{}
+	for i, p := range players {
+		if (best>>i)&1 == 1 {
+			a = append(a, p)
+		} else {
+			b = append(b, p)
+		}
+	}
+
+	if rand.Intn(2) == 0 {
+		a, b = b, a
+	}
+
+	return withCaptain(a, gold), withCaptain(b, gold)
+}
+
+// pickCaptains returns the indexes of the two captains of a draft, the best players with the gold role come first
+// and the best players without it fill in when there are not enough of them.
+func pickCaptains(players []structures.InhousePlayer, gold map[string]bool) (int, int) {
+	order := byRating(players)
+	candidates := []int{}
+	for _, i := range order {
+		if gold[players[i].UserID] {
+			candidates = append(candidates, i)
+		}
+	}
+	for _, i := range order {
+		if !gold[players[i].UserID] {
+			candidates = append(candidates, i)
+		}
+	}
+
+	return candidates[0], candidates[1]
+}
+
+// withCaptain sorts a team by rating and makes the best player with the gold role, or the best player, its captain.
+func withCaptain(team []structures.InhousePlayer, gold map[string]bool) []structures.InhousePlayer {
+	sorted := make([]structures.InhousePlayer, len(team))
+	for i, v := range byRating(team) {
+		sorted[i] = team[v]
+	}
+
+	captain := 0
+	for i, v := range sorted {
+		if gold[v.UserID] {
+			captain = i
+			break
+		}
+	}
+	if len(sorted) != 0 {
+		sorted[captain].Captain = true
+	}
+
+	return sorted
+}
+
+// byRating returns the indexes of the players from the highest to the lowest rating.
+func byRating(players []structures.InhousePlayer) []int {
+	order := make([]int, len(players))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return players[order[i]].Rating > players[order[j]].Rating
+	})
+
+	return order
+}
+
+func teamRating(team []structures.InhousePlayer) int64 {
+	var total int64
+	for _, v := range team {
+		total += int64(v.Rating)
+	}
+
+	return total
+}
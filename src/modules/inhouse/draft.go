@@ -0,0 +1,316 @@
+package inhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	draftPickID        = "inhouse-draft"
+	defaultPickTimeout = time.Second * 30
+)
+
+var (
+	errDraftOver   = errors.New("draft is over")
+	errNotYourPick = errors.New("not the turn of the user")
+	errNotInPool   = errors.New("player is not in the pool")
+)
+
+// draftOrder is whose turn it is for every pick, the first captain picks once and then both pick twice in turns.
+var draftOrder = []int{0, 1, 1, 0, 0, 1, 1, 0}
+
+// draft is a captains draft, the captains pick the players of their team from the pool one after another.
+type draft struct {
+	channelID string
+	messageID string
+	// teams are the captains followed by their picks, the first team is the one which picks first.
+	teams [2][]structures.InhousePlayer
+	// sides are the sides the teams play on.
+	sides [2]structures.InhouseTeam
+	// pool are the players who were not picked yet, the best one first.
+	pool     []structures.InhousePlayer
+	names    map[string]string
+	picks    int
+	deadline time.Time
+	// picked is notified after every pick so that the timer starts again.
+	picked chan struct{}
+}
+
+func (d *draft) players() []structures.InhousePlayer {
+	return append(append(append([]structures.InhousePlayer{}, d.teams[0]...), d.teams[1]...), d.pool...)
+}
+
+// turn is the team which picks next.
+func (d *draft) turn() int {
+	if d.picks >= len(draftOrder) {
+		return draftOrder[len(draftOrder)-1]
+	}
+
+	return draftOrder[d.picks]
+}
+
+// startDraft makes the two best players with the gold role, or the best players, the captains of a draft between
+// the players of a ready check. the captain with the lower rating picks first.
+func (m *Module) startDraft(s *discordgo.Session, q *queue, check *readyCheck) error {
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Second*30)
+	defer cancel()
+
+	players, gold, err := m.players(ctx, s, q.guildID, check.players)
+	if err != nil {
+		return err
+	}
+
+	a, b := pickCaptains(players, gold)
+	first, second := players[a], players[b]
+	if first.Rating > second.Rating {
+		first, second = second, first
+	}
+	first.Captain, second.Captain = true, true
+
+	d := &draft{
+		channelID: check.channelID,
+		teams:     [2][]structures.InhousePlayer{{first}, {second}},
+		sides:     [2]structures.InhouseTeam{structures.InhouseTeamRadiant, structures.InhouseTeamDire},
+		names:     map[string]string{},
+		deadline:  time.Now().Add(m.pickTimeout(q.guildID)),
+		picked:    make(chan struct{}, 1),
+	}
+	if rand.Intn(2) == 0 {
+		d.sides[0], d.sides[1] = d.sides[1], d.sides[0]
+	}
+
+	for _, i := range byRating(players) {
+		if i != a && i != b {
+			d.pool = append(d.pool, players[i])
+		}
+	}
+	for _, v := range players {
+		d.names[v.UserID] = m.memberName(s, q.guildID, v.UserID)
+	}
+
+	msg, err := s.ChannelMessageSendComplex(d.channelID, &discordgo.MessageSend{
+		Content:    fmt.Sprintf("<@%s> <@%s> you are the captains, pick your teams.", first.UserID, second.UserID),
+		Embed:      draftEmbed(d),
+		Components: draftComponents(d),
+	})
+	if err != nil {
+		return err
+	}
+
+	d.messageID = msg.ID
+	q.draft = d
+
+	go m.runDraft(s, q, d)
+
+	return nil
+}
+
+// runDraft picks the best remaining player for captains who do not pick in time.
+func (m *Module) runDraft(s *discordgo.Session, q *queue, d *draft) {
+	for {
+		q.mtx.Lock()
+		if q.draft != d {
+			q.mtx.Unlock()
+			return
+		}
+		timer := time.NewTimer(time.Until(d.deadline))
+		q.mtx.Unlock()
+
+		select {
+		case <-m.gCtx.Done():
+			timer.Stop()
+			return
+		case <-d.picked:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		q.mtx.Lock()
+		if q.draft == d && !time.Now().Before(d.deadline) {
+			m.pick(s, q, d, d.pool[0].UserID)
+		}
+		q.mtx.Unlock()
+	}
+}
+
+// draftPick picks the player for the captain whose turn it is.
+func (m *Module) draftPick(s *discordgo.Session, guildID string, messageID string, userID string, values []string) error {
+	q := m.queue(guildID)
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	d := q.draft
+	if d == nil || d.messageID != messageID {
+		return errDraftOver
+	}
+
+	if d.teams[d.turn()][0].UserID != userID {
+		return errNotYourPick
+	}
+
+	if len(values) != 1 {
+		return nil
+	}
+
+	if !m.pick(s, q, d, values[0]) {
+		return errNotInPool
+	}
+
+	return nil
+}
+
+// pick moves a player from the pool to the team whose turn it is, the last player is picked automatically
+// and the match starts once the teams are full. it is false when the player is not in the pool.
+func (m *Module) pick(s *discordgo.Session, q *queue, d *draft, userID string) bool {
+	picked := false
+	for i, v := range d.pool {
+		if v.UserID == userID {
+			turn := d.turn()
+			d.teams[turn] = append(d.teams[turn], v)
+			d.pool = append(d.pool[:i], d.pool[i+1:]...)
+			d.picks++
+			picked = true
+			break
+		}
+	}
+	if !picked {
+		return false
+	}
+
+	if len(d.pool) == 1 {
+		return m.pick(s, q, d, d.pool[0].UserID)
+	}
+
+	if len(d.pool) != 0 {
+		d.deadline = time.Now().Add(m.pickTimeout(q.guildID))
+		select {
+		case d.picked <- struct{}{}:
+		default:
+		}
+
+		edit := discordgo.NewMessageEdit(d.channelID, d.messageID).SetEmbed(draftEmbed(d))
+		edit.Components = draftComponents(d)
+		if _, err := s.ChannelMessageEditComplex(edit); err != nil {
+			logrus.WithField("guild_id", q.guildID).Error("failed to update inhouse draft: ", err)
+		}
+		return true
+	}
+
+	m.finishDraft(s, q, d)
+	return true
+}
+
+// finishDraft saves the match with the drafted teams.
+func (m *Module) finishDraft(s *discordgo.Session, q *queue, d *draft) {
+	q.draft = nil
+
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Second*30)
+	defer cancel()
+
+	teams := map[structures.InhouseTeam][]structures.InhousePlayer{
+		d.sides[0]: d.teams[0],
+		d.sides[1]: d.teams[1],
+	}
+
+	userIDs := []string{}
+	for _, v := range d.players() {
+		userIDs = append(userIDs, v.UserID)
+	}
+
+	edit := discordgo.NewMessageEdit(d.channelID, d.messageID)
+	edit.Components = []discordgo.MessageComponent{}
+
+	match, err := m.createMatch(ctx, s, q.guildID, teams[structures.InhouseTeamRadiant], teams[structures.InhouseTeamDire])
+	if err != nil {
+		logrus.WithField("guild_id", q.guildID).Error("failed to start inhouse match: ", err)
+		q.players = append(userIDs, q.players...)
+		edit.SetContent("The match could not be started, everyone is back in the queue.")
+	} else {
+		edit.SetContent(mentions(userIDs))
+		edit.SetEmbed(matchEmbed(match))
+	}
+
+	if _, err = s.ChannelMessageEditComplex(edit); err != nil {
+		logrus.WithField("guild_id", q.guildID).Error("failed to update inhouse draft: ", err)
+	}
+
+	m.pop(s, q, "")
+	m.updateQueue(s, q, "")
+}
+
+func (m *Module) pickTimeout(guildID string) time.Duration {
+	if timeout := m.config(guildID).PickTimeout; timeout > 0 {
+		return timeout
+	}
+
+	return defaultPickTimeout
+}
+
+// memberName is the nickname or else the username of a member, it is used where mentions are not shown.
+func (m *Module) memberName(s *discordgo.Session, guildID string, userID string) string {
+	member, err := s.State.Member(guildID, userID)
+	if err != nil {
+		member, err = m.gCtx.Inst().Discord.Member(guildID, userID)
+	}
+	if err != nil || member.User == nil {
+		return userID
+	}
+
+	if member.Nick != "" {
+		return member.Nick
+	}
+
+	return member.User.Username
+}
+
+func draftEmbed(d *draft) *discordgo.MessageEmbed {
+	pool := make([]string, len(d.pool))
+	for i, v := range d.pool {
+		pool[i] = fmt.Sprintf("<@%s> (%d)", v.UserID, v.Rating)
+	}
+
+	turn := d.teams[d.turn()][0]
+	return &discordgo.MessageEmbed{
+		Title:       "Captains draft",
+		Description: fmt.Sprintf("<@%s> is picking, the best remaining player is picked for them <t:%d:R>.", turn.UserID, d.deadline.Unix()),
+		Fields: []*discordgo.MessageEmbedField{
+			teamField(teamName(d.sides[0]), d.teams[0]),
+			teamField(teamName(d.sides[1]), d.teams[1]),
+			{Name: "Remaining", Value: strings.Join(pool, "\n")},
+		},
+	}
+}
+
+func draftComponents(d *draft) []discordgo.MessageComponent {
+	options := make([]discordgo.SelectMenuOption, len(d.pool))
+	for i, v := range d.pool {
+		options[i] = discordgo.SelectMenuOption{
+			Label:       d.names[v.UserID],
+			Value:       v.UserID,
+			Description: fmt.Sprintf("Rating %d", v.Rating),
+			// options without an emoji are rejected by discord, discordgo always sends one.
+			Emoji: discordgo.ComponentEmoji{Name: "👤"},
+		}
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					CustomID:    draftPickID,
+					Placeholder: fmt.Sprintf("Pick a player for %s", teamName(d.sides[d.turn()])),
+					Options:     options,
+				},
+			},
+		},
+	}
+}
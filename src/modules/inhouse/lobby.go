@@ -0,0 +1,455 @@
+package inhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/dota2"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/utils"
+	"github.com/bwmarrin/discordgo"
+	"github.com/hashicorp/go-multierror"
+	"github.com/paralin/go-dota2/protocol"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	lobbyInterval       = time.Second * 5
+	resultInterval      = time.Minute
+	defaultLobbyTimeout = time.Minute * 15
+	// resultTimeout is how long after the start of a match its result is looked up before it is left to the moderators.
+	resultTimeout = time.Hour * 4
+)
+
+var errNoDota = errors.New("the dota client is not connected")
+
+// lobby is the lobby which is being set up, the dota client can only be in one lobby at a time.
+// it leaves the lobby once the game started, so that the lobby of the next waiting match can be hosted.
+type lobby struct {
+	matchID primitive.ObjectID
+	cancel  context.CancelFunc
+}
+
+// startLobby hosts the lobby of a match, the players are told to set it up themselves when it can not be hosted.
+func (m *Module) startLobby(match structures.InhouseMatch) {
+	if err := m.hostLobby(match); err != nil {
+		logrus.WithField("guild_id", match.GuildID).Error("failed to host inhouse lobby: ", err)
+		m.announce(match.GuildID, &discordgo.MessageSend{
+			Content: fmt.Sprintf("The lobby of inhouse match #%d could not be hosted, the players have to set it up themselves and the result has to be reported with the report command.", match.Number),
+		})
+	}
+}
+
+// hostLobby creates the lobby of a match and invites the players with a linked steam account.
+// the match waits for its lobby while the lobby of another match is being set up.
+func (m *Module) hostLobby(match structures.InhouseMatch) error {
+	cfg := m.config(match.GuildID)
+	if !cfg.LobbyEnabled {
+		return nil
+	}
+
+	client := m.dota()
+	if client == nil {
+		return errNoDota
+	}
+
+	m.lobbyMtx.Lock()
+	if m.lobby != nil {
+		m.waiting = append(m.waiting, match)
+		m.lobbyMtx.Unlock()
+
+		m.announce(match.GuildID, &discordgo.MessageSend{
+			Content: fmt.Sprintf("The lobby of inhouse match #%d is hosted once the lobby of another match is set up.", match.Number),
+		})
+		return nil
+	}
+	ctx, cancel := context.WithCancel(m.gCtx)
+	m.lobby = &lobby{matchID: match.ID, cancel: cancel}
+	m.lobbyMtx.Unlock()
+
+	userIDs := []string{}
+	for _, v := range append(append([]structures.InhousePlayer{}, match.Radiant...), match.Dire...) {
+		userIDs = append(userIDs, v.UserID)
+	}
+
+	accounts, err := m.steamAccounts(ctx, userIDs)
+	if err != nil {
+		m.releaseLobby(match.ID)
+		return err
+	}
+
+	match.Lobby = &structures.InhouseLobby{
+		Name:     fmt.Sprintf("Inhouse #%d", match.Number),
+		Password: fmt.Sprintf("%06d", rand.Intn(1000000)),
+	}
+
+	allowSpectating := true
+	details := &protocol.CMsgPracticeLobbySetDetails{
+		GameName:        &match.Lobby.Name,
+		PassKey:         &match.Lobby.Password,
+		AllowSpectating: &allowSpectating,
+	}
+	if cfg.LobbyRegion > 0 {
+		region := uint32(cfg.LobbyRegion)
+		details.ServerRegion = &region
+	}
+	if cfg.LobbyGameMode > 0 {
+		mode := uint32(cfg.LobbyGameMode)
+		details.GameMode = &mode
+	}
+
+	createCtx, cancelCreate := context.WithTimeout(ctx, time.Second*30)
+	err = client.CreateLobby(createCtx, details)
+	cancelCreate()
+	if err != nil {
+		m.releaseLobby(match.ID)
+		return err
+	}
+
+	for _, v := range accounts {
+		client.InviteLobbyMember(v)
+	}
+
+	if _, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).UpdateOne(ctx, bson.M{
+		"_id": match.ID,
+	}, bson.M{
+		"$set": bson.M{
+			"lobby": match.Lobby,
+		},
+	}); err != nil {
+		m.releaseLobby(match.ID)
+		return err
+	}
+
+	go m.runLobby(ctx, client, match, accounts)
+
+	for _, team := range []structures.InhouseTeam{structures.InhouseTeamRadiant, structures.InhouseTeamDire} {
+		for _, v := range match.Players(team) {
+			join := "join"
+			if accounts[v.UserID] != 0 {
+				join = "you were invited, join"
+			}
+
+			if _, err = m.gCtx.Inst().Discord.SendPrivateMessage(v.UserID, &discordgo.MessageSend{
+				Content: fmt.Sprintf("Inhouse match #%d is hosted in the lobby **%s** with the password `%s`, %s %s.", match.Number, match.Lobby.Name, match.Lobby.Password, join, teamName(team)),
+			}); err != nil {
+				logrus.WithField("guild_id", match.GuildID).WithField("user_id", v.UserID).Warn("failed to send inhouse lobby: ", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// runLobby launches the lobby once every player took their slot and saves the id of the dota match once the game
+// started. the lobby is closed when the players did not take their slots in time or the match is over.
+func (m *Module) runLobby(ctx context.Context, client *dota2.DotaClient, match structures.InhouseMatch, accounts map[string]uint64) {
+	defer m.releaseLobby(match.ID)
+
+	timeout := m.config(match.GuildID).LobbyTimeout
+	if timeout <= 0 {
+		timeout = defaultLobbyTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	tick := time.NewTicker(lobbyInterval)
+	defer tick.Stop()
+
+	launched := false
+	for {
+		select {
+		case <-ctx.Done():
+			m.leaveLobby(client, true)
+			return
+		case <-timer.C:
+			if launched {
+				continue
+			}
+
+			m.leaveLobby(client, true)
+			m.announce(match.GuildID, &discordgo.MessageSend{
+				Content: fmt.Sprintf("The lobby of inhouse match #%d was closed since the players did not take their slots in time, the result has to be reported with the report command.", match.Number),
+			})
+			return
+		case <-tick.C:
+		}
+
+		current := client.Lobby()
+		if current == nil {
+			m.announce(match.GuildID, &discordgo.MessageSend{
+				Content: fmt.Sprintf("The dota client left the lobby of inhouse match #%d, the players have to set up a lobby themselves and the result has to be reported with the report command.", match.Number),
+			})
+			return
+		}
+
+		if current.GetMatchId() != 0 {
+			if _, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).UpdateOne(ctx, bson.M{
+				"_id": match.ID,
+			}, bson.M{
+				"$set": bson.M{
+					"lobby.match_id": strconv.FormatUint(current.GetMatchId(), 10),
+				},
+			}); err != nil {
+				logrus.WithField("guild_id", match.GuildID).Error("failed to save inhouse dota match: ", err)
+			}
+
+			m.leaveLobby(client, false)
+			return
+		}
+
+		if !launched && current.GetState() == protocol.CSODOTALobby_UI && lobbyReady(client, current, match, accounts) {
+			client.LaunchLobby()
+			launched = true
+		}
+	}
+}
+
+// lobbyReady is true when both teams are full and every player with a linked steam account is on their team.
+// members who are not players are moved out of the teams when every player can be recognized.
+func lobbyReady(client *dota2.DotaClient, current *protocol.CSODOTALobby, match structures.InhouseMatch, accounts map[string]uint64) bool {
+	teams := map[uint64]protocol.DOTA_GC_TEAM{}
+	for _, v := range match.Radiant {
+		if accounts[v.UserID] != 0 {
+			teams[accounts[v.UserID]] = protocol.DOTA_GC_TEAM_DOTA_GC_TEAM_GOOD_GUYS
+		}
+	}
+	for _, v := range match.Dire {
+		if accounts[v.UserID] != 0 {
+			teams[accounts[v.UserID]] = protocol.DOTA_GC_TEAM_DOTA_GC_TEAM_BAD_GUYS
+		}
+	}
+
+	slots, placed := 0, 0
+	for _, v := range current.GetAllMembers() {
+		team := v.GetTeam()
+		if team != protocol.DOTA_GC_TEAM_DOTA_GC_TEAM_GOOD_GUYS && team != protocol.DOTA_GC_TEAM_DOTA_GC_TEAM_BAD_GUYS {
+			continue
+		}
+
+		expected, ok := teams[v.GetId()]
+		if !ok && len(teams) == matchSize {
+			client.KickLobbyMemberFromTeam(uint32(utils.SteamID64ToSteamID3(v.GetId())))
+			continue
+		}
+
+		slots++
+		if ok && team == expected {
+			placed++
+		}
+	}
+
+	return slots == matchSize && placed == len(teams)
+}
+
+func (m *Module) leaveLobby(client *dota2.DotaClient, destroy bool) {
+	// the context of the lobby is done when it is closed, so leaving it has its own.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if err := client.LeaveLobby(ctx, destroy); err != nil {
+		logrus.Error("failed to leave inhouse lobby: ", err)
+	}
+}
+
+// closeLobby stops setting up the lobby of a match, it is destroyed unless the game started.
+// a match which is still waiting for its lobby is not hosted anymore.
+func (m *Module) closeLobby(matchID primitive.ObjectID) {
+	m.lobbyMtx.Lock()
+	defer m.lobbyMtx.Unlock()
+
+	if m.lobby != nil && m.lobby.matchID == matchID {
+		m.lobby.cancel()
+	}
+
+	for i, v := range m.waiting {
+		if v.ID == matchID {
+			m.waiting = append(m.waiting[:i], m.waiting[i+1:]...)
+			break
+		}
+	}
+}
+
+// releaseLobby frees the dota client once it left the lobby of a match and hosts the lobby of the next waiting match.
+func (m *Module) releaseLobby(matchID primitive.ObjectID) {
+	m.lobbyMtx.Lock()
+	if m.lobby == nil || m.lobby.matchID != matchID {
+		m.lobbyMtx.Unlock()
+		return
+	}
+
+	m.lobby.cancel()
+	m.lobby = nil
+
+	if len(m.waiting) == 0 {
+		m.lobbyMtx.Unlock()
+		return
+	}
+
+	next := m.waiting[0]
+	m.waiting = m.waiting[1:]
+	m.lobbyMtx.Unlock()
+
+	go m.startLobby(next)
+}
+
+// steamAccounts returns the steam ids of the users who linked their steam account.
+func (m *Module) steamAccounts(ctx context.Context, userIDs []string) (map[string]uint64, error) {
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).Find(ctx, bson.M{
+		"discord.id": bson.M{"$in": userIDs},
+		"steam.id":   bson.M{"$exists": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := []structures.User{}
+	if err = cur.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	accounts := map[string]uint64{}
+	for _, v := range users {
+		if id, err := strconv.ParseUint(v.Steam.ID, 10, 64); err == nil {
+			accounts[v.Discord.ID] = id
+		}
+	}
+
+	return accounts, nil
+}
+
+// resultLoop reports the results of the matches played in a hosted lobby once their games are over.
+func (m *Module) resultLoop() {
+	tick := time.NewTicker(resultInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-m.gCtx.Done():
+			return
+		case <-tick.C:
+		}
+
+		if err := m.reportResults(m.gCtx.Inst().Discord.Session()); err != nil {
+			logrus.Error("failed to report inhouse results: ", err)
+		}
+	}
+}
+
+func (m *Module) reportResults(s *discordgo.Session) error {
+	client := m.dota()
+	if client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Minute*5)
+	defer cancel()
+
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).Find(ctx, bson.M{
+		"status":         structures.InhouseMatchStatusPlaying,
+		"lobby.match_id": bson.M{"$exists": true},
+	})
+	if err != nil {
+		return err
+	}
+
+	matches := []structures.InhouseMatch{}
+	if err = cur.All(ctx, &matches); err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		matchID, _ := strconv.ParseUint(match.Lobby.MatchID, 10, 64)
+
+		// the details of a game are only available once it is over.
+		details, e := client.MatchDetails(ctx, matchID)
+		if e != nil {
+			logrus.WithField("guild_id", match.GuildID).Debugf("inhouse dota match %d is not over yet: %s", matchID, e)
+		}
+
+		var winner structures.InhouseTeam
+		switch details.GetMatchOutcome() {
+		case protocol.EMatchOutcome_k_EMatchOutcome_RadVictory:
+			winner = structures.InhouseTeamRadiant
+		case protocol.EMatchOutcome_k_EMatchOutcome_DireVictory:
+			winner = structures.InhouseTeamDire
+		case protocol.EMatchOutcome_k_EMatchOutcome_Unknown:
+			if time.Since(match.CreatedAt) < resultTimeout {
+				continue
+			}
+
+			if e = m.handOverResult(ctx, match, fmt.Sprintf("The result of inhouse match #%d could not be read from dota, it has to be reported with the report command.", match.Number)); e != nil {
+				err = multierror.Append(err, e)
+			}
+			continue
+		default:
+			// games which were not won by a team, like ones which were abandoned, are left to the moderators.
+			if e = m.handOverResult(ctx, match, fmt.Sprintf("The game of inhouse match #%d ended without a winner, the result has to be reported with the report command.", match.Number)); e != nil {
+				err = multierror.Append(err, e)
+			}
+			continue
+		}
+
+		match, e = m.finishMatch(ctx, match, winner, s.State.User.ID)
+		if e == errMatchOver {
+			continue
+		}
+		if e != nil {
+			err = multierror.Append(err, e)
+			continue
+		}
+
+		if e = m.removeVoice(s, match); e != nil {
+			err = multierror.Append(err, e)
+		}
+
+		m.announce(match.GuildID, &discordgo.MessageSend{Embed: resultEmbed(match)})
+	}
+
+	return err
+}
+
+// handOverResult stops looking up the result of a match and asks the moderators to report it.
+func (m *Module) handOverResult(ctx context.Context, match structures.InhouseMatch, content string) error {
+	res, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).UpdateOne(ctx, bson.M{
+		"_id":            match.ID,
+		"lobby.match_id": bson.M{"$exists": true},
+	}, bson.M{
+		"$unset": bson.M{
+			"lobby.match_id": 1,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.ModifiedCount != 0 {
+		m.announce(match.GuildID, &discordgo.MessageSend{Content: content})
+	}
+
+	return nil
+}
+
+// announce posts a message in the queue channel of a guild.
+func (m *Module) announce(guildID string, msg *discordgo.MessageSend) {
+	q := m.queue(guildID)
+	q.mtx.Lock()
+	channelID := m.queueChannel(q, "")
+	q.mtx.Unlock()
+
+	if channelID == "" {
+		return
+	}
+
+	if _, err := m.gCtx.Inst().Discord.SendMessage(channelID, msg); err != nil {
+		logrus.WithField("guild_id", guildID).Error("failed to post inhouse message: ", err)
+	}
+}
@@ -3,23 +3,39 @@ package inhouse
 import (
 	"fmt"
 	"strings"
-	"time"
+	"sync"
 
+	"github.com/AdmiralBulldogTv/DiscordBot/src/configure"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/global"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
-	"github.com/AdmiralBulldogTv/DiscordBot/src/utils"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/dota2"
 	"github.com/bwmarrin/discordgo"
-	"github.com/hashicorp/go-multierror"
 	"github.com/sirupsen/logrus"
 )
 
 type Module struct {
 	done chan struct{}
 	gCtx global.Context
+	// dota returns the dota client lobbies are hosted on, nil when it is not connected.
+	dota func() *dota2.DotaClient
+
+	queuesMtx sync.Mutex
+	queues    map[string]*queue
+	// ratingMtx makes sure ratings are updated by one result at a time.
+	ratingMtx sync.Mutex
+
+	lobbyMtx sync.Mutex
+	lobby    *lobby
+	// waiting are the matches whose lobby is hosted once the dota client is free.
+	waiting []structures.InhouseMatch
 }
 
-func New() *Module {
-	return &Module{}
+func New(dota func() *dota2.DotaClient) *Module {
+	return &Module{
+		dota:   dota,
+		queues: map[string]*queue{},
+	}
 }
 
 func (m *Module) Register(gCtx global.Context) (<-chan struct{}, error) {
@@ -28,65 +44,102 @@ func (m *Module) Register(gCtx global.Context) (<-chan struct{}, error) {
 
 	closeFns := []func(){}
 
-	err := multierror.Append(nil, gCtx.Inst().Discord.RegisterCommand("inhouse", m.CommandGroup()))
-	closeFns = append(closeFns, gCtx.Inst().Discord.AddHandler(m.onMessage))
+	closeFn, err := command.Register(gCtx.Inst().Discord, "inhouse", m.CommandGroup())
+	closeFns = append(closeFns, closeFn, gCtx.Inst().Discord.AddHandler(m.onMessage), gCtx.Inst().Discord.AddHandler(m.onInteraction))
+
+	wg := &sync.WaitGroup{}
+	for _, loop := range []func(){m.voiceLoop, m.resultLoop} {
+		wg.Add(1)
+		go func(loop func()) {
+			defer wg.Done()
+			loop()
+		}(loop)
+	}
 
 	go func() {
 		<-gCtx.Done()
 		for _, fn := range closeFns {
 			fn()
 		}
+		wg.Wait()
 		close(m.done)
 	}()
 
-	return m.done, err.ErrorOrNil()
+	return m.done, err
 }
 
 func (m *Module) Name() string {
-	return "Points"
+	return "InHouse"
+}
+
+func (m *Module) config(guildID string) configure.InHouseModule {
+	return m.gCtx.Inst().Guilds.Config(guildID).Modules.InHouse
+}
+
+func (m *Module) enabled(guildID string) bool {
+	return m.config(guildID).Enabled
+}
+
+func (m *Module) moderatorPermission() command.Permission {
+	return command.RolePermission(func(guildID string) []string {
+		return m.config(guildID).ModeratorRoles
+	})
 }
 
 func (m *Module) onMessage(s *discordgo.Session, msg *discordgo.MessageCreate) {
-	if msg.GuildID != m.gCtx.Config().Discord.GuildID || msg.Author.Bot {
+	if msg.GuildID == "" || msg.Author.Bot || !m.enabled(msg.GuildID) {
 		return
 	}
 
+	cfg := m.config(msg.GuildID)
+
 	mp := map[string]bool{}
 	for _, r := range msg.Member.Roles {
 		mp[r] = true
 	}
 
-	if !mp[m.gCtx.Config().Modules.InHouse.InhouseRoleID] {
+	if !mp[cfg.InhouseRoleID] {
 		return
 	}
 
-	for _, role := range m.gCtx.Config().Modules.InHouse.RequiredRoleIDs {
+	for _, role := range cfg.RequiredRoleIDs {
 		if mp[role] {
 			return
 		}
 	}
 
-	if err := s.GuildMemberRoleRemove(m.gCtx.Config().Discord.GuildID, msg.Author.ID, m.gCtx.Config().Modules.InHouse.InhouseRoleID); err != nil {
-		logrus.Errorf("cannot remove role (%s) from user (%s): %s", m.gCtx.Config().Modules.InHouse.InhouseRoleID, msg.Author.ID, err.Error())
+	if err := s.GuildMemberRoleRemove(msg.GuildID, msg.Author.ID, cfg.InhouseRoleID); err != nil {
+		logrus.Errorf("cannot remove role (%s) from user (%s): %s", cfg.InhouseRoleID, msg.Author.ID, err.Error())
 	}
 }
 
 func (m *Module) CommandGroup() command.Cmd {
 	return &command.CommandGroup{
 		NameCmd: func() string {
-			return "points"
+			return "inhouse"
 		},
 		MatchCmd: func(path []string) bool {
 			return len(path) != 0 && strings.EqualFold(path[0], "inhouse")
 		},
+		Info:        "Manage the inhouse league",
+		UsageInfo:   "<command>",
+		ExampleInfo: []string{"inhouse join"},
+		EnabledCmd:  m.enabled,
 		Commands: map[string]command.Cmd{
-			"join":      m.JoinCmd(),
-			"leave":     m.LeaveCmd(),
-			"gold":      m.GoldCmd(),
-			"take-gold": m.TakeGoldCmd(),
-			"add":       m.AddCmd(),
-			"remove":    m.RemoveCmd(),
-			"ping":      m.PingCmd(),
+			"join":        m.JoinCmd(),
+			"leave":       m.LeaveCmd(),
+			"gold":        m.GoldCmd(),
+			"take-gold":   m.TakeGoldCmd(),
+			"add":         m.AddCmd(),
+			"remove":      m.RemoveCmd(),
+			"ping":        m.PingCmd(),
+			"queue":       m.QueueCmd(),
+			"unqueue":     m.UnqueueCmd(),
+			"report":      m.ReportCmd(),
+			"stats":       m.StatsCmd(),
+			"history":     m.HistoryCmd(),
+			"leaderboard": m.LeaderboardCmd(),
+			"recompute":   m.RecomputeCmd(),
 		},
 	}
 }
@@ -99,35 +152,29 @@ func (m *Module) JoinCmd() command.Cmd {
 		NameCmd: func() string {
 			return "inhouse join"
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
+		Info:        "Join the inhouse league",
+		ExampleInfo: []string{"inhouse join"},
+		Perms: command.Permission{
+			Roles: func(guildID string) []string {
+				return m.config(guildID).RequiredRoleIDs
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
 			mp := map[string]bool{}
-			for _, r := range msg.Member.Roles {
+			for _, r := range ctx.Member.Roles {
 				mp[r] = true
 			}
 
-			found := false
-			for _, role := range m.gCtx.Config().Modules.InHouse.RequiredRoleIDs {
-				if mp[role] {
-					found = true
-				}
-			}
-
-			if !found {
-				return nil
+			if mp[m.config(ctx.GuildID).InhouseRoleID] {
+				return ctx.ReplyError("You are already in the inhouse league")
 			}
 
-			if mp[m.gCtx.Config().Modules.InHouse.InhouseRoleID] {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "You are already in the inhouse league", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
+			if err := ctx.Session.GuildMemberRoleAdd(ctx.GuildID, ctx.Author.ID, m.config(ctx.GuildID).InhouseRoleID); err != nil {
+				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.config(ctx.GuildID).InhouseRoleID, ctx.Author.ID, err.Error())
 				return err
 			}
 
-			if err := s.GuildMemberRoleAdd(m.gCtx.Config().Discord.GuildID, msg.Author.ID, m.gCtx.Config().Modules.InHouse.InhouseRoleID); err != nil {
-				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.gCtx.Config().Modules.InHouse.InhouseRoleID, msg.Author.ID, err.Error())
-				return err
-			}
-
-			_, err := s.ChannelMessageSendReply(msg.ChannelID, "Welcome to the inhouse league!", msg.Reference())
+			_, err := ctx.Reply("Welcome to the inhouse league!")
 			return err
 		},
 	}
@@ -141,35 +188,29 @@ func (m *Module) LeaveCmd() command.Cmd {
 		NameCmd: func() string {
 			return "inhouse leave"
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
+		Info:        "Leave the inhouse league",
+		ExampleInfo: []string{"inhouse leave"},
+		Perms: command.Permission{
+			Roles: func(guildID string) []string {
+				return m.config(guildID).RequiredRoleIDs
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
 			mp := map[string]bool{}
-			for _, r := range msg.Member.Roles {
+			for _, r := range ctx.Member.Roles {
 				mp[r] = true
 			}
 
-			found := false
-			for _, role := range m.gCtx.Config().Modules.InHouse.RequiredRoleIDs {
-				if mp[role] {
-					found = true
-				}
-			}
-
-			if !found {
-				return nil
+			if !mp[m.config(ctx.GuildID).InhouseRoleID] {
+				return ctx.ReplyError("You are not in the inhouse league")
 			}
 
-			if !mp[m.gCtx.Config().Modules.InHouse.InhouseRoleID] {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "You are not in the inhouse league", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
+			if err := ctx.Session.GuildMemberRoleRemove(ctx.GuildID, ctx.Author.ID, m.config(ctx.GuildID).InhouseRoleID); err != nil {
+				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.config(ctx.GuildID).InhouseRoleID, ctx.Author.ID, err.Error())
 				return err
 			}
 
-			if err := s.GuildMemberRoleRemove(m.gCtx.Config().Discord.GuildID, msg.Author.ID, m.gCtx.Config().Modules.InHouse.InhouseRoleID); err != nil {
-				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.gCtx.Config().Modules.InHouse.InhouseRoleID, msg.Author.ID, err.Error())
-				return err
-			}
-
-			_, err := s.ChannelMessageSendReply(msg.ChannelID, "You have left the inhouse league", msg.Reference())
+			_, err := ctx.Reply("You have left the inhouse league")
 			return err
 		},
 	}
@@ -183,48 +224,28 @@ func (m *Module) GoldCmd() command.Cmd {
 		NameCmd: func() string {
 			return "inhouse gold"
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			perms, err := s.State.MessagePermissions(msg.Message)
-			if err != nil {
-				return err
-			}
-
-			if perms&discordgo.PermissionAdministrator == 0 {
-				mp := map[string]bool{}
-				for _, v := range msg.Member.Roles {
-					mp[v] = true
-				}
-				found := false
-				for _, v := range m.gCtx.Config().Discord.AdminRoles {
-					if mp[v] {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return nil
-				}
-			}
-
-			guild, err := s.State.Guild(msg.GuildID)
-			if err != nil {
-				return err
-			}
-
-			search := strings.TrimSpace(strings.ToLower(strings.Join(path, " ")))
-			member := utils.FindMember(s, guild, msg.Message, search)
-			if member == nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
-			}
-
-			if err := s.GuildMemberRoleAdd(m.gCtx.Config().Discord.GuildID, member.User.ID, m.gCtx.Config().Modules.InHouse.GoldRoleID); err != nil {
-				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.gCtx.Config().Modules.InHouse.InhouseRoleID, member.User.ID, err.Error())
+		Info:        "Give a user the gold role",
+		ExampleInfo: []string{"inhouse gold Troy"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to give gold to",
+				Type:        command.ArgMember,
+				Required:    true,
+			},
+		},
+		Perms: command.RolePermission(func(guildID string) []string {
+			return m.config(guildID).ModeratorRoles
+		}),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
+
+			if err := ctx.Session.GuildMemberRoleAdd(ctx.GuildID, member.User.ID, m.config(ctx.GuildID).GoldRoleID); err != nil {
+				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.config(ctx.GuildID).InhouseRoleID, member.User.ID, err.Error())
 				return err
 			}
 
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("You gave gold to %s", member.User), msg.Reference())
+			_, err := ctx.Reply(fmt.Sprintf("You gave gold to %s", member.User))
 			return err
 		},
 	}
@@ -238,48 +259,28 @@ func (m *Module) AddCmd() command.Cmd {
 		NameCmd: func() string {
 			return "inhouse add"
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			perms, err := s.State.MessagePermissions(msg.Message)
-			if err != nil {
-				return err
-			}
-
-			if perms&discordgo.PermissionAdministrator == 0 {
-				mp := map[string]bool{}
-				for _, v := range msg.Member.Roles {
-					mp[v] = true
-				}
-				found := false
-				for _, v := range m.gCtx.Config().Discord.AdminRoles {
-					if mp[v] {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return nil
-				}
-			}
-
-			guild, err := s.State.Guild(msg.GuildID)
-			if err != nil {
-				return err
-			}
-
-			search := strings.TrimSpace(strings.ToLower(strings.Join(path, " ")))
-			member := utils.FindMember(s, guild, msg.Message, search)
-			if member == nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
-			}
-
-			if err := s.GuildMemberRoleAdd(m.gCtx.Config().Discord.GuildID, member.User.ID, m.gCtx.Config().Modules.InHouse.InhouseRoleID); err != nil {
-				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.gCtx.Config().Modules.InHouse.InhouseRoleID, member.User.ID, err.Error())
+		Info:        "Add a user to the inhouse league",
+		ExampleInfo: []string{"inhouse add Troy"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to add",
+				Type:        command.ArgMember,
+				Required:    true,
+			},
+		},
+		Perms: command.RolePermission(func(guildID string) []string {
+			return m.config(guildID).ModeratorRoles
+		}),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
+
+			if err := ctx.Session.GuildMemberRoleAdd(ctx.GuildID, member.User.ID, m.config(ctx.GuildID).InhouseRoleID); err != nil {
+				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.config(ctx.GuildID).InhouseRoleID, member.User.ID, err.Error())
 				return err
 			}
 
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("You added %s to the inhouse league", member.User), msg.Reference())
+			_, err := ctx.Reply(fmt.Sprintf("You added %s to the inhouse league", member.User))
 			return err
 		},
 	}
@@ -293,48 +294,28 @@ func (m *Module) RemoveCmd() command.Cmd {
 		NameCmd: func() string {
 			return "inhouse remove"
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			perms, err := s.State.MessagePermissions(msg.Message)
-			if err != nil {
-				return err
-			}
-
-			if perms&discordgo.PermissionAdministrator == 0 {
-				mp := map[string]bool{}
-				for _, v := range msg.Member.Roles {
-					mp[v] = true
-				}
-				found := false
-				for _, v := range m.gCtx.Config().Discord.AdminRoles {
-					if mp[v] {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return nil
-				}
-			}
-
-			guild, err := s.State.Guild(msg.GuildID)
-			if err != nil {
-				return err
-			}
-
-			search := strings.TrimSpace(strings.ToLower(strings.Join(path, " ")))
-			member := utils.FindMember(s, guild, msg.Message, search)
-			if member == nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
-			}
-
-			if err := s.GuildMemberRoleRemove(m.gCtx.Config().Discord.GuildID, member.User.ID, m.gCtx.Config().Modules.InHouse.InhouseRoleID); err != nil {
-				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.gCtx.Config().Modules.InHouse.InhouseRoleID, member.User.ID, err.Error())
+		Info:        "Remove a user from the inhouse league",
+		ExampleInfo: []string{"inhouse remove Troy"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to remove",
+				Type:        command.ArgMember,
+				Required:    true,
+			},
+		},
+		Perms: command.RolePermission(func(guildID string) []string {
+			return m.config(guildID).ModeratorRoles
+		}),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
+
+			if err := ctx.Session.GuildMemberRoleRemove(ctx.GuildID, member.User.ID, m.config(ctx.GuildID).InhouseRoleID); err != nil {
+				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.config(ctx.GuildID).InhouseRoleID, member.User.ID, err.Error())
 				return err
 			}
 
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("You removed %s from the inhouse league", member.User), msg.Reference())
+			_, err := ctx.Reply(fmt.Sprintf("You removed %s from the inhouse league", member.User))
 			return err
 		},
 	}
@@ -348,48 +329,28 @@ func (m *Module) TakeGoldCmd() command.Cmd {
 		NameCmd: func() string {
 			return "inhouse take-gold"
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			perms, err := s.State.MessagePermissions(msg.Message)
-			if err != nil {
-				return err
-			}
-
-			if perms&discordgo.PermissionAdministrator == 0 {
-				mp := map[string]bool{}
-				for _, v := range msg.Member.Roles {
-					mp[v] = true
-				}
-				found := false
-				for _, v := range m.gCtx.Config().Discord.AdminRoles {
-					if mp[v] {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return nil
-				}
-			}
-
-			guild, err := s.State.Guild(msg.GuildID)
-			if err != nil {
-				return err
-			}
-
-			search := strings.TrimSpace(strings.ToLower(strings.Join(path, " ")))
-			member := utils.FindMember(s, guild, msg.Message, search)
-			if member == nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
-			}
-
-			if err := s.GuildMemberRoleRemove(m.gCtx.Config().Discord.GuildID, member.User.ID, m.gCtx.Config().Modules.InHouse.GoldRoleID); err != nil {
-				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.gCtx.Config().Modules.InHouse.InhouseRoleID, member.User.ID, err.Error())
+		Info:        "Take the gold role from a user",
+		ExampleInfo: []string{"inhouse take-gold Troy"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to take gold from",
+				Type:        command.ArgMember,
+				Required:    true,
+			},
+		},
+		Perms: command.RolePermission(func(guildID string) []string {
+			return m.config(guildID).ModeratorRoles
+		}),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
+
+			if err := ctx.Session.GuildMemberRoleRemove(ctx.GuildID, member.User.ID, m.config(ctx.GuildID).GoldRoleID); err != nil {
+				logrus.Errorf("cannot add role (%s) from user (%s): %s", m.config(ctx.GuildID).InhouseRoleID, member.User.ID, err.Error())
 				return err
 			}
 
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("You removed gold from %s", member.User), msg.Reference())
+			_, err := ctx.Reply(fmt.Sprintf("You removed gold from %s", member.User))
 			return err
 		},
 	}
@@ -403,30 +364,13 @@ func (m *Module) PingCmd() command.Cmd {
 		NameCmd: func() string {
 			return "inhouse ping"
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			perms, err := s.State.MessagePermissions(msg.Message)
-			if err != nil {
-				return err
-			}
-
-			if perms&discordgo.PermissionAdministrator == 0 {
-				mp := map[string]bool{}
-				for _, v := range msg.Member.Roles {
-					mp[v] = true
-				}
-				found := false
-				for _, v := range m.gCtx.Config().Discord.AdminRoles {
-					if mp[v] {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return nil
-				}
-			}
-
-			_, err = s.ChannelMessageSend(msg.ChannelID, fmt.Sprintf("<@&%s> pinged by %s", m.gCtx.Config().Modules.InHouse.InhouseRoleID, msg.Author))
+		Info:        "Ping the inhouse league",
+		ExampleInfo: []string{"inhouse ping"},
+		Perms: command.RolePermission(func(guildID string) []string {
+			return m.config(guildID).ModeratorRoles
+		}),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			_, err := ctx.Send(fmt.Sprintf("<@&%s> pinged by %s", m.config(ctx.GuildID).InhouseRoleID, ctx.Author))
 			return err
 		},
 	}
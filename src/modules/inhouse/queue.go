@@ -0,0 +1,671 @@
+package inhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	queueJoinID    = "inhouse-queue"
+	queueLeaveID   = "inhouse-unqueue"
+	readyAcceptID  = "inhouse-ready"
+	readyDeclineID = "inhouse-decline"
+
+	defaultReadyTimeout = time.Minute
+	matchNumberRetries  = 5
+)
+
+var (
+	errAlreadyQueued = errors.New("already queued")
+	errNotQueued     = errors.New("not queued")
+	errCheckOver     = errors.New("ready check is over")
+	errNotInCheck    = errors.New("not part of the ready check")
+)
+
+// queue are the players of a guild who wait for a match in the order they queued.
+type queue struct {
+	mtx       sync.Mutex
+	guildID   string
+	channelID string
+	messageID string
+	players   []string
+	// check is the ready check of the players the queue popped with, there is only one at a time.
+	check *readyCheck
+	// draft is the draft of the captains after a ready check in captains mode, the queue does not pop until it is over.
+	draft *draft
+}
+
+// readyCheck waits for every player of a popped queue to accept the match.
+type readyCheck struct {
+	channelID string
+	messageID string
+	players   []string
+	ready     map[string]bool
+	declined  map[string]bool
+	deadline  time.Time
+	done      chan struct{}
+	finished  bool
+}
+
+func (c *readyCheck) finish() {
+	if !c.finished {
+		c.finished = true
+		close(c.done)
+	}
+}
+
+func (c *readyCheck) allReady() bool {
+	return len(c.ready) == len(c.players)
+}
+
+func (m *Module) queue(guildID string) *queue {
+	m.queuesMtx.Lock()
+	defer m.queuesMtx.Unlock()
+
+	q, ok := m.queues[guildID]
+	if !ok {
+		q = &queue{guildID: guildID}
+		m.queues[guildID] = q
+	}
+
+	return q
+}
+
+func (q *queue) has(userID string) bool {
+	for _, v := range q.players {
+		if v == userID {
+			return true
+		}
+	}
+
+	if q.check != nil {
+		for _, v := range q.check.players {
+			if v == userID {
+				return true
+			}
+		}
+	}
+
+	if q.draft != nil {
+		for _, v := range q.draft.players() {
+			if v.UserID == userID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// join adds a player to the queue, it pops once there are enough players for a match.
+func (m *Module) join(s *discordgo.Session, guildID string, channelID string, userID string) (int, error) {
+	q := m.queue(guildID)
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.has(userID) {
+		return 0, errAlreadyQueued
+	}
+
+	q.players = append(q.players, userID)
+	n := len(q.players)
+
+	m.pop(s, q, channelID)
+	m.updateQueue(s, q, channelID)
+
+	return n, nil
+}
+
+// leave removes a player from the queue, leaving during a ready check declines it.
+func (m *Module) leave(s *discordgo.Session, guildID string, userID string) error {
+	q := m.queue(guildID)
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for i, v := range q.players {
+		if v == userID {
+			q.players = append(q.players[:i], q.players[i+1:]...)
+			m.updateQueue(s, q, "")
+			return nil
+		}
+	}
+
+	if q.check != nil && q.has(userID) {
+		return m.respondReady(s, q, q.check.messageID, userID, false)
+	}
+
+	return errNotQueued
+}
+
+// pop starts a ready check with the first players of the queue when there are enough of them.
+func (m *Module) pop(s *discordgo.Session, q *queue, channelID string) {
+	if q.check != nil || q.draft != nil || len(q.players) < matchSize {
+		return
+	}
+
+	timeout := m.config(q.guildID).ReadyTimeout
+	if timeout <= 0 {
+		timeout = defaultReadyTimeout
+	}
+
+	check := &readyCheck{
+		channelID: m.queueChannel(q, channelID),
+		players:   append([]string{}, q.players[:matchSize]...),
+		ready:     map[string]bool{},
+		declined:  map[string]bool{},
+		deadline:  time.Now().Add(timeout),
+		done:      make(chan struct{}),
+	}
+	q.players = append([]string{}, q.players[matchSize:]...)
+
+	msg, err := s.ChannelMessageSendComplex(check.channelID, &discordgo.MessageSend{
+		Content:    mentions(check.players),
+		Embed:      readyEmbed(check),
+		Components: readyComponents(),
+	})
+	if err != nil {
+		logrus.WithField("guild_id", q.guildID).Error("failed to start ready check: ", err)
+		q.players = append(check.players, q.players...)
+		return
+	}
+
+	check.messageID = msg.ID
+	q.check = check
+
+	go m.runReadyCheck(s, q, check)
+}
+
+// runReadyCheck waits for the ready check to end, the match starts when every player accepted.
+// otherwise the players who accepted go back to the front of the queue.
+func (m *Module) runReadyCheck(s *discordgo.Session, q *queue, check *readyCheck) {
+	timer := time.NewTimer(time.Until(check.deadline))
+	defer timer.Stop()
+
+	select {
+	case <-m.gCtx.Done():
+		return
+	case <-check.done:
+	case <-timer.C:
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	check.finished = true
+	q.check = nil
+
+	edit := discordgo.NewMessageEdit(check.channelID, check.messageID)
+	edit.Components = []discordgo.MessageComponent{}
+
+	if check.allReady() && m.config(q.guildID).CaptainsMode {
+		if err := m.startDraft(s, q, check); err != nil {
+			logrus.WithField("guild_id", q.guildID).Error("failed to start inhouse draft: ", err)
+			q.players = append(check.players, q.players...)
+			edit.SetContent("The draft could not be started, everyone is back in the queue.")
+		} else {
+			edit.SetContent("Everyone is ready, the captains are drafting the teams.")
+			edit.SetEmbed(readyEmbed(check))
+		}
+	} else if check.allReady() {
+		match, err := m.startMatch(s, q.guildID, check.players)
+		if err != nil {
+			logrus.WithField("guild_id", q.guildID).Error("failed to start inhouse match: ", err)
+			q.players = append(check.players, q.players...)
+			edit.SetContent("The match could not be started, everyone is back in the queue.")
+		} else {
+			edit.SetContent(mentions(check.players))
+			edit.SetEmbed(matchEmbed(match))
+		}
+	} else {
+		accepted, missing := []string{}, []string{}
+		for _, v := range check.players {
+			if check.ready[v] {
+				accepted = append(accepted, v)
+			} else {
+				missing = append(missing, v)
+			}
+		}
+
+		q.players = append(accepted, q.players...)
+		edit.SetContent(fmt.Sprintf("The ready check failed, %s did not accept. Everyone else is back at the front of the queue.", mentions(missing)))
+		edit.SetEmbed(readyEmbed(check))
+	}
+
+	if _, err := s.ChannelMessageEditComplex(edit); err != nil {
+		logrus.WithField("guild_id", q.guildID).Error("failed to update ready check: ", err)
+	}
+
+	m.pop(s, q, "")
+	m.updateQueue(s, q, "")
+}
+
+// respondReady accepts or declines the ready check of the message for a player.
+func (m *Module) respondReady(s *discordgo.Session, q *queue, messageID string, userID string, accept bool) error {
+	check := q.check
+	if check == nil || check.messageID != messageID || check.finished {
+		return errCheckOver
+	}
+
+	found := false
+	for _, v := range check.players {
+		found = found || v == userID
+	}
+	if !found {
+		return errNotInCheck
+	}
+
+	if accept {
+		check.ready[userID] = true
+	} else {
+		check.declined[userID] = true
+		delete(check.ready, userID)
+	}
+
+	if !accept || check.allReady() {
+		check.finish()
+		return nil
+	}
+
+	edit := discordgo.NewMessageEdit(check.channelID, check.messageID).SetEmbed(readyEmbed(check))
+	edit.Components = readyComponents()
+	_, err := s.ChannelMessageEditComplex(edit)
+	return err
+}
+
+// players looks up the ratings of the users and which of them have the gold role.
+func (m *Module) players(ctx context.Context, s *discordgo.Session, guildID string, userIDs []string) ([]structures.InhousePlayer, map[string]bool, error) {
+	cfg := m.config(guildID)
+
+	ratings, err := m.ratings(ctx, userIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gold := map[string]bool{}
+	players := make([]structures.InhousePlayer, len(userIDs))
+	for i, v := range userIDs {
+		players[i] = structures.InhousePlayer{UserID: v, Rating: ratings[v]}
+		gold[v] = cfg.GoldRoleID != "" && m.hasRole(s, guildID, v, cfg.GoldRoleID)
+	}
+
+	return players, gold, nil
+}
+
+// startMatch balances the players into two teams and saves the match.
+func (m *Module) startMatch(s *discordgo.Session, guildID string, userIDs []string) (structures.InhouseMatch, error) {
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Second*30)
+	defer cancel()
+
+	players, gold, err := m.players(ctx, s, guildID, userIDs)
+	if err != nil {
+		return structures.InhouseMatch{}, err
+	}
+
+	radiant, dire := balanceTeams(players, gold, m.config(guildID).SeedCaptains)
+	return m.createMatch(ctx, s, guildID, radiant, dire)
+}
+
+// createMatch saves a match which is being played with the teams, creates the voice channels of the teams and hosts
+// its lobby.
+func (m *Module) createMatch(ctx context.Context, s *discordgo.Session, guildID string, radiant []structures.InhousePlayer, dire []structures.InhousePlayer) (structures.InhouseMatch, error) {
+	match := structures.InhouseMatch{
+		ID:        primitive.NewObjectID(),
+		GuildID:   guildID,
+		Status:    structures.InhouseMatchStatusPlaying,
+		Radiant:   radiant,
+		Dire:      dire,
+		CreatedAt: time.Now(),
+	}
+
+	if err := m.insertMatch(ctx, &match); err != nil {
+		return match, err
+	}
+
+	go func() {
+		if err := m.createVoice(s, match); err != nil {
+			logrus.WithField("guild_id", guildID).Error("failed to create inhouse voice channels: ", err)
+		}
+		m.startLobby(match)
+	}()
+
+	return match, nil
+}
+
+// insertMatch saves a match with the next number of its guild.
+func (m *Module) insertMatch(ctx context.Context, match *structures.InhouseMatch) error {
+	var err error
+	for i := 0; i < matchNumberRetries; i++ {
+		last := structures.InhouseMatch{}
+		err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).FindOne(ctx, bson.M{
+			"guild_id": match.GuildID,
+		}, options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}})).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		match.Number = last.Number + 1
+		_, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).InsertOne(ctx, match)
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (m *Module) hasRole(s *discordgo.Session, guildID string, userID string, roleID string) bool {
+	member, err := s.State.Member(guildID, userID)
+	if err != nil {
+		member, err = m.gCtx.Inst().Discord.Member(guildID, userID)
+	}
+	if err != nil {
+		return false
+	}
+
+	for _, v := range member.Roles {
+		if v == roleID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queueChannel is the channel the queue is posted in, the configured one or else where it was used first.
+func (m *Module) queueChannel(q *queue, channelID string) string {
+	if id := m.config(q.guildID).QueueChannelID; id != "" {
+		return id
+	}
+	if q.channelID != "" {
+		return q.channelID
+	}
+
+	return channelID
+}
+
+// updateQueue edits the queue message, a new one is posted when there is none or it was deleted.
+func (m *Module) updateQueue(s *discordgo.Session, q *queue, channelID string) {
+	if q.messageID != "" {
+		edit := discordgo.NewMessageEdit(q.channelID, q.messageID).SetEmbed(queueEmbed(q))
+		edit.Components = queueComponents()
+		if _, err := s.ChannelMessageEditComplex(edit); err == nil {
+			return
+		}
+	}
+
+	channelID = m.queueChannel(q, channelID)
+	if channelID == "" {
+		return
+	}
+
+	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embed:      queueEmbed(q),
+		Components: queueComponents(),
+	})
+	if err != nil {
+		logrus.WithField("guild_id", q.guildID).Error("failed to post inhouse queue: ", err)
+		return
+	}
+
+	q.channelID = msg.ChannelID
+	q.messageID = msg.ID
+}
+
+func queueEmbed(q *queue) *discordgo.MessageEmbed {
+	lines := make([]string, len(q.players))
+	for i, v := range q.players {
+		lines[i] = fmt.Sprintf("%d. <@%s>", i+1, v)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Nobody is queued.")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Inhouse queue (%d/%d)", len(q.players), matchSize),
+		Description: strings.Join(lines, "\n"),
+	}
+	if q.check != nil {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: "A ready check is running."}
+	} else if q.draft != nil {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: "The captains are drafting."}
+	}
+
+	return embed
+}
+
+func queueComponents() []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Queue", Style: discordgo.SuccessButton, CustomID: queueJoinID},
+				discordgo.Button{Label: "Leave", Style: discordgo.SecondaryButton, CustomID: queueLeaveID},
+			},
+		},
+	}
+}
+
+func readyEmbed(check *readyCheck) *discordgo.MessageEmbed {
+	lines := make([]string, len(check.players))
+	for i, v := range check.players {
+		status := "⌛"
+		if check.ready[v] {
+			status = command.ConfirmEmoji
+		} else if check.declined[v] {
+			status = command.CancelEmoji
+		}
+
+		lines[i] = fmt.Sprintf("%s <@%s>", status, v)
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Match found, %d/%d ready", len(check.ready), len(check.players)),
+		Description: fmt.Sprintf("%s\n\nThe ready check ends <t:%d:R>.", strings.Join(lines, "\n"), check.deadline.Unix()),
+	}
+}
+
+func readyComponents() []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Ready", Style: discordgo.SuccessButton, CustomID: readyAcceptID},
+				discordgo.Button{Label: "Decline", Style: discordgo.DangerButton, CustomID: readyDeclineID},
+			},
+		},
+	}
+}
+
+func matchEmbed(match structures.InhouseMatch) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("Inhouse match #%d", match.Number),
+		Fields: []*discordgo.MessageEmbedField{
+			teamField("Radiant", match.Radiant),
+			teamField("Dire", match.Dire),
+		},
+	}
+}
+
+func teamField(name string, team []structures.InhousePlayer) *discordgo.MessageEmbedField {
+	lines := make([]string, len(team))
+	for i, v := range team {
+		lines[i] = fmt.Sprintf("<@%s> (%d)", v.UserID, v.Rating)
+		if v.Delta != 0 {
+			lines[i] = fmt.Sprintf("<@%s> (%d %+d)", v.UserID, v.Rating, v.Delta)
+		}
+		if v.Captain {
+			lines[i] += " 👑"
+		}
+	}
+
+	return &discordgo.MessageEmbedField{
+		Name:   fmt.Sprintf("%s (%d)", name, teamRating(team)),
+		Value:  strings.Join(lines, "\n"),
+		Inline: true,
+	}
+}
+
+func mentions(userIDs []string) string {
+	items := make([]string, len(userIDs))
+	for i, v := range userIDs {
+		items[i] = fmt.Sprintf("<@%s>", v)
+	}
+
+	return strings.Join(items, ", ")
+}
+
+// onInteraction handles the buttons of the queue and the ready checks.
+func (m *Module) onInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionMessageComponent || i.GuildID == "" || i.Member == nil || i.Message == nil {
+		return
+	}
+
+	id := i.MessageComponentData().CustomID
+	switch id {
+	case queueJoinID, queueLeaveID, readyAcceptID, readyDeclineID, draftPickID:
+	default:
+		return
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		logrus.Error("failed to respond to interaction: ", err)
+		return
+	}
+
+	if !m.enabled(i.GuildID) {
+		m.followup(s, i, "The inhouse league is disabled.")
+		return
+	}
+
+	userID := i.Member.User.ID
+
+	var err error
+	switch {
+	case id == queueJoinID:
+		if !m.inLeague(i.GuildID, i.Member) {
+			m.followup(s, i, "You have to join the inhouse league to queue.")
+			return
+		}
+		_, err = m.join(s, i.GuildID, i.ChannelID, userID)
+	case id == queueLeaveID:
+		err = m.leave(s, i.GuildID, userID)
+	case id == draftPickID:
+		err = m.draftPick(s, i.GuildID, i.Message.ID, userID, i.MessageComponentData().Values)
+	default:
+		q := m.queue(i.GuildID)
+		q.mtx.Lock()
+		err = m.respondReady(s, q, i.Message.ID, userID, id == readyAcceptID)
+		q.mtx.Unlock()
+	}
+
+	switch err {
+	case nil:
+	case errAlreadyQueued:
+		m.followup(s, i, "You are already in the queue.")
+	case errNotQueued:
+		m.followup(s, i, "You are not in the queue.")
+	case errCheckOver:
+		m.followup(s, i, "This ready check is over.")
+	case errNotInCheck:
+		m.followup(s, i, "You are not part of this match.")
+	case errDraftOver:
+		m.followup(s, i, "This draft is over.")
+	case errNotYourPick:
+		m.followup(s, i, "It is not your turn to pick.")
+	case errNotInPool:
+		m.followup(s, i, "This player can not be picked anymore.")
+	default:
+		logrus.WithField("guild_id", i.GuildID).Error("failed to handle inhouse button: ", err)
+		m.followup(s, i, "Something went wrong.")
+	}
+}
+
+func (m *Module) followup(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	if _, err := s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
+		Content: content,
+		Flags:   uint64(discordgo.MessageFlagsEphemeral),
+	}); err != nil {
+		logrus.Error("failed to respond to interaction: ", err)
+	}
+}
+
+func (m *Module) inLeague(guildID string, member *discordgo.Member) bool {
+	for _, v := range member.Roles {
+		if v == m.config(guildID).InhouseRoleID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Module) leaguePermission() command.Permission {
+	return command.RolePermission(func(guildID string) []string {
+		return []string{m.config(guildID).InhouseRoleID}
+	})
+}
+
+func (m *Module) QueueCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "inhouse queue"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "queue")
+		},
+		Info:        "Queue for an inhouse match, the match starts once 10 players are queued and ready",
+		ExampleInfo: []string{"inhouse queue"},
+		Perms:       m.leaguePermission(),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			n, err := m.join(ctx.Session, ctx.GuildID, ctx.ChannelID, ctx.Author.ID)
+			if err == errAlreadyQueued {
+				return ctx.ReplyError("You are already in the queue.")
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = ctx.Reply(fmt.Sprintf("You joined the queue (%d/%d).", n, matchSize))
+			return err
+		},
+	}
+}
+
+func (m *Module) UnqueueCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "inhouse unqueue"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "unqueue")
+		},
+		Info:        "Leave the inhouse queue",
+		ExampleInfo: []string{"inhouse unqueue"},
+		Perms:       m.leaguePermission(),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			err := m.leave(ctx.Session, ctx.GuildID, ctx.Author.ID)
+			if err == errNotQueued {
+				return ctx.ReplyError("You are not in the queue.")
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = ctx.Reply("You left the queue.")
+			return err
+		},
+	}
+}
@@ -0,0 +1,633 @@
+package inhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// eloK is the most a rating can change by one match.
+	eloK                    = 32
+	ratingPageSize          = 10
+	recomputeConfirmTimeout = time.Second * 30
+)
+
+var errMatchOver = errors.New("match is over")
+
+var pageArg = command.Arg{
+	Name:        "page",
+	Description: "The page to show, defaults to the first one",
+	Type:        command.ArgInteger,
+}
+
+// rateMatch sets the rating every player had before the match and how much it changes by the result,
+// every player of a team gains or loses the same amount based on the average ratings of the teams.
+func rateMatch(match *structures.InhouseMatch, ratings map[string]int32) {
+	average := func(team []structures.InhousePlayer) float64 {
+		var total float64
+		for _, v := range team {
+			total += float64(ratings[v.UserID])
+		}
+		return total / math.Max(float64(len(team)), 1)
+	}
+
+	expected := 1 / (1 + math.Pow(10, (average(match.Dire)-average(match.Radiant))/400))
+	score := 0.0
+	if match.Winner == structures.InhouseTeamRadiant {
+		score = 1
+	}
+
+	delta := int32(math.Round(eloK * (score - expected)))
+	for i, v := range match.Radiant {
+		match.Radiant[i].Rating = ratings[v.UserID]
+		match.Radiant[i].Delta = delta
+	}
+	for i, v := range match.Dire {
+		match.Dire[i].Rating = ratings[v.UserID]
+		match.Dire[i].Delta = -delta
+	}
+}
+
+// finishMatch sets the winner of a match which is still being played and updates the ratings of its players.
+func (m *Module) finishMatch(ctx context.Context, match structures.InhouseMatch, winner structures.InhouseTeam, actorID string) (structures.InhouseMatch, error) {
+	m.ratingMtx.Lock()
+	defer m.ratingMtx.Unlock()
+
+	match.Status = structures.InhouseMatchStatusFinished
+	match.Winner = winner
+	match.ReportedBy = actorID
+	match.FinishedAt = time.Now()
+
+	// the match is claimed first so that a result is only counted once.
+	res, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).UpdateOne(ctx, bson.M{
+		"_id":    match.ID,
+		"status": structures.InhouseMatchStatusPlaying,
+	}, bson.M{
+		"$set": bson.M{
+			"status":      match.Status,
+			"winner":      match.Winner,
+			"reported_by": match.ReportedBy,
+			"finished_at": match.FinishedAt,
+		},
+	})
+	if err != nil {
+		return match, err
+	}
+	if res.ModifiedCount == 0 {
+		return match, errMatchOver
+	}
+
+	userIDs := []string{}
+	for _, v := range append(append([]structures.InhousePlayer{}, match.Radiant...), match.Dire...) {
+		userIDs = append(userIDs, v.UserID)
+	}
+
+	ratings, err := m.ratings(ctx, userIDs)
+	if err != nil {
+		return match, err
+	}
+
+	rateMatch(&match, ratings)
+
+	if _, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).UpdateOne(ctx, bson.M{
+		"_id": match.ID,
+	}, bson.M{
+		"$set": bson.M{
+			"radiant": match.Radiant,
+			"dire":    match.Dire,
+		},
+	}); err != nil {
+		return match, err
+	}
+
+	for _, team := range []struct {
+		players []structures.InhousePlayer
+		won     bool
+	}{{match.Radiant, winner == structures.InhouseTeamRadiant}, {match.Dire, winner == structures.InhouseTeamDire}} {
+		field := "modules.inhouse.losses"
+		if team.won {
+			field = "modules.inhouse.wins"
+		}
+
+		for _, v := range team.players {
+			if _, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).UpdateOne(ctx, bson.M{
+				"discord.id": v.UserID,
+			}, bson.M{
+				"$set": bson.M{
+					"modules.inhouse.rating":     v.Rating + v.Delta,
+					"modules.inhouse.last_match": match.FinishedAt,
+				},
+				"$inc": bson.M{
+					field: 1,
+				},
+			}, options.Update().SetUpsert(true)); err != nil {
+				return match, err
+			}
+		}
+	}
+
+	return match, nil
+}
+
+// recompute calculates the ratings of every user again from the results of every match in the order they finished.
+// ratings are shared by every guild, so the matches of all guilds are used.
+func (m *Module) recompute(ctx context.Context) (int, error) {
+	m.ratingMtx.Lock()
+	defer m.ratingMtx.Unlock()
+
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).Find(ctx, bson.M{
+		"status": structures.InhouseMatchStatusFinished,
+	}, options.Find().SetSort(bson.D{{Key: "finished_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	users := map[string]*structures.UserModulesInhouse{}
+	ratings := map[string]int32{}
+	matches := 0
+	for cur.Next(ctx) {
+		match := structures.InhouseMatch{}
+		if err = cur.Decode(&match); err != nil {
+			return matches, err
+		}
+
+		for _, v := range append(append([]structures.InhousePlayer{}, match.Radiant...), match.Dire...) {
+			if _, ok := users[v.UserID]; !ok {
+				users[v.UserID] = &structures.UserModulesInhouse{Rating: defaultRating}
+			}
+			ratings[v.UserID] = users[v.UserID].Rating
+		}
+
+		rateMatch(&match, ratings)
+
+		if _, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).UpdateOne(ctx, bson.M{
+			"_id": match.ID,
+		}, bson.M{
+			"$set": bson.M{
+				"radiant": match.Radiant,
+				"dire":    match.Dire,
+			},
+		}); err != nil {
+			return matches, err
+		}
+
+		for _, v := range append(append([]structures.InhousePlayer{}, match.Radiant...), match.Dire...) {
+			user := users[v.UserID]
+			user.Rating = v.Rating + v.Delta
+			user.LastMatch = match.FinishedAt
+			if team, _ := match.Team(v.UserID); team == match.Winner {
+				user.Wins++
+			} else {
+				user.Losses++
+			}
+		}
+
+		matches++
+	}
+	if err = cur.Err(); err != nil {
+		return matches, err
+	}
+
+	userIDs := make([]string, 0, len(users))
+	for id, v := range users {
+		userIDs = append(userIDs, id)
+		if _, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).UpdateOne(ctx, bson.M{
+			"discord.id": id,
+		}, bson.M{
+			"$set": bson.M{
+				"modules.inhouse": v,
+			},
+		}, options.Update().SetUpsert(true)); err != nil {
+			return matches, err
+		}
+	}
+
+	_, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).UpdateMany(ctx, bson.M{
+		"discord.id":      bson.M{"$nin": userIDs},
+		"modules.inhouse": bson.M{"$exists": true},
+	}, bson.M{
+		"$unset": bson.M{
+			"modules.inhouse": 1,
+		},
+	})
+	return matches, err
+}
+
+func (m *Module) match(ctx context.Context, guildID string, number int64) (structures.InhouseMatch, error) {
+	match := structures.InhouseMatch{}
+	err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).FindOne(ctx, bson.M{
+		"guild_id": guildID,
+		"number":   number,
+	}).Decode(&match)
+
+	return match, err
+}
+
+func (m *Module) ReportCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "inhouse report"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "report")
+		},
+		Info:        "Report the winner of an inhouse match, both captains have to report the same winner unless a moderator reports it",
+		ExampleInfo: []string{"inhouse report 12 radiant"},
+		Perms: command.RolePermission(func(guildID string) []string {
+			return append([]string{m.config(guildID).InhouseRoleID}, m.config(guildID).ModeratorRoles...)
+		}),
+		Args: []command.Arg{
+			{
+				Name:        "match",
+				Description: "The number of the match",
+				Type:        command.ArgInteger,
+				Required:    true,
+			},
+			{
+				Name:        "winner",
+				Description: "The team which won, radiant or dire",
+				Required:    true,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			winner := structures.InhouseTeam(strings.ToLower(ctx.Args.String("winner")))
+			if winner != structures.InhouseTeamRadiant && winner != structures.InhouseTeamDire {
+				return &command.UsageError{Reason: "The winner has to be radiant or dire."}
+			}
+
+			match, err := m.match(m.gCtx, ctx.GuildID, ctx.Args.Int("match"))
+			if err == mongo.ErrNoDocuments {
+				return ctx.ReplyError("There is no such match.")
+			}
+			if err != nil {
+				return err
+			}
+			if match.Status != structures.InhouseMatchStatusPlaying {
+				return ctx.ReplyError(fmt.Sprintf("Match #%d is already over.", match.Number))
+			}
+
+			moderator, err := m.moderatorPermission().Allowed(ctx, m.gCtx.Inst().Guilds.Config(ctx.GuildID).AdminRoles)
+			if err != nil {
+				return err
+			}
+
+			if !moderator {
+				radiant, dire := match.Captain(structures.InhouseTeamRadiant), match.Captain(structures.InhouseTeamDire)
+				if ctx.Author.ID != radiant.UserID && ctx.Author.ID != dire.UserID {
+					return ctx.ReplyError("Only the captains or a moderator can report the result.")
+				}
+
+				err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).FindOneAndUpdate(m.gCtx, bson.M{
+					"_id":    match.ID,
+					"status": structures.InhouseMatchStatusPlaying,
+				}, bson.M{
+					"$set": bson.M{
+						"reports." + ctx.Author.ID: winner,
+					},
+				}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&match)
+				if err == mongo.ErrNoDocuments {
+					return ctx.ReplyError(fmt.Sprintf("Match #%d is already over.", match.Number))
+				}
+				if err != nil {
+					return err
+				}
+
+				other := radiant.UserID
+				if other == ctx.Author.ID {
+					other = dire.UserID
+				}
+
+				switch match.Reports[other] {
+				case "":
+					_, err = ctx.Reply(fmt.Sprintf("Reported %s as the winner of match #%d, waiting for <@%s> to confirm.", winner, match.Number, other))
+					return err
+				case winner:
+				default:
+					return ctx.ReplyError(fmt.Sprintf("<@%s> reported %s as the winner, a moderator has to report match #%d.", other, match.Reports[other], match.Number))
+				}
+			}
+
+			match, err = m.finishMatch(m.gCtx, match, winner, ctx.Author.ID)
+			if err == errMatchOver {
+				return ctx.ReplyError(fmt.Sprintf("Match #%d is already over.", match.Number))
+			}
+			if err != nil {
+				return err
+			}
+
+			m.closeLobby(match.ID)
+			go func() {
+				if err := m.removeVoice(ctx.Session, match); err != nil {
+					logrus.WithField("guild_id", match.GuildID).Error("failed to remove inhouse voice channels: ", err)
+				}
+			}()
+
+			embed := resultEmbed(match)
+			embed.Color = ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID)
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{Embed: embed})
+			return err
+		},
+	}
+}
+
+func (m *Module) StatsCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "inhouse stats"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "stats")
+		},
+		Info:        "Shows the inhouse rating and results of a user",
+		ExampleInfo: []string{"inhouse stats", "inhouse stats Troy"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to look up, defaults to you",
+				Type:        command.ArgMember,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
+			if member == nil {
+				member = ctx.Member
+			}
+
+			user := structures.User{}
+			err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).FindOne(m.gCtx, bson.M{
+				"discord.id": member.User.ID,
+			}).Decode(&user)
+			if err != nil && err != mongo.ErrNoDocuments {
+				return err
+			}
+
+			stats := user.Modules.Inhouse
+			if stats.Wins+stats.Losses == 0 {
+				return ctx.ReplyError(fmt.Sprintf("%s has not played an inhouse match yet.", member.User))
+			}
+
+			higher, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).CountDocuments(m.gCtx, bson.M{
+				"modules.inhouse.rating": bson.M{"$gt": stats.Rating},
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title: fmt.Sprintf("Inhouse stats of %s", member.User),
+					Color: ctx.Session.State.UserColor(member.User.ID, ctx.ChannelID),
+					Fields: []*discordgo.MessageEmbedField{
+						{Name: "Rating", Value: fmt.Sprintf("%d (#%d)", stats.Rating, higher+1), Inline: true},
+						{Name: "Record", Value: fmt.Sprintf("%d wins, %d losses", stats.Wins, stats.Losses), Inline: true},
+						{Name: "Win rate", Value: fmt.Sprintf("%.1f%%", float64(stats.Wins)*100/float64(stats.Wins+stats.Losses)), Inline: true},
+						{Name: "Last match", Value: fmt.Sprintf("<t:%d:R>", stats.LastMatch.Unix()), Inline: true},
+					},
+					Footer: &discordgo.MessageEmbedFooter{
+						Text: "Ratings are shared by every server",
+					},
+				},
+			})
+			return err
+		},
+	}
+}
+
+func (m *Module) HistoryCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "inhouse history"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "history")
+		},
+		Info:        "Shows the latest inhouse matches of a user and how their rating changed",
+		ExampleInfo: []string{"inhouse history", "inhouse history Troy 2"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to look up, defaults to you",
+				Type:        command.ArgMember,
+			},
+			pageArg,
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
+			if member == nil {
+				member = ctx.Member
+			}
+
+			page, err := pageNumber(ctx)
+			if err != nil {
+				return err
+			}
+
+			filter := bson.M{
+				"status": structures.InhouseMatchStatusFinished,
+				"$or": bson.A{
+					bson.M{"radiant.user_id": member.User.ID},
+					bson.M{"dire.user_id": member.User.ID},
+				},
+			}
+
+			total, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).CountDocuments(m.gCtx, filter)
+			if err != nil {
+				return err
+			}
+
+			cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).Find(m.gCtx, filter, options.Find().
+				SetSort(bson.D{{Key: "finished_at", Value: -1}}).
+				SetSkip((page-1)*ratingPageSize).
+				SetLimit(ratingPageSize),
+			)
+			if err != nil {
+				return err
+			}
+
+			matches := []structures.InhouseMatch{}
+			if err = cur.All(m.gCtx, &matches); err != nil {
+				return err
+			}
+
+			pages := (total + ratingPageSize - 1) / ratingPageSize
+			if len(matches) == 0 {
+				if pages == 0 {
+					return ctx.ReplyError(fmt.Sprintf("%s has not played an inhouse match yet.", member.User))
+				}
+
+				return ctx.ReplyError(fmt.Sprintf("There are only %d pages.", pages))
+			}
+
+			lines := make([]string, len(matches))
+			for i, v := range matches {
+				team, player := v.Team(member.User.ID)
+				result := "Lost"
+				if team == v.Winner {
+					result = "Won"
+				}
+
+				lines[i] = fmt.Sprintf("**#%d** <t:%d:R> %s as %s **%+d** → %d", v.Number, v.FinishedAt.Unix(), result, teamName(team), player.Delta, player.Rating+player.Delta)
+			}
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:       fmt.Sprintf("Inhouse history of %s", member.User),
+					Description: strings.Join(lines, "\n"),
+					Color:       ctx.Session.State.UserColor(member.User.ID, ctx.ChannelID),
+					Footer: &discordgo.MessageEmbedFooter{
+						Text: fmt.Sprintf("Page %d of %d", page, pages),
+					},
+				},
+			})
+			return err
+		},
+	}
+}
+
+func (m *Module) LeaderboardCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "inhouse leaderboard"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "leaderboard")
+		},
+		Info:        "Shows the players with the highest inhouse rating across every server",
+		ExampleInfo: []string{"inhouse leaderboard", "inhouse leaderboard 2"},
+		Args:        []command.Arg{pageArg},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			page, err := pageNumber(ctx)
+			if err != nil {
+				return err
+			}
+
+			filter := bson.M{"modules.inhouse.rating": bson.M{"$gt": 0}}
+
+			total, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).CountDocuments(m.gCtx, filter)
+			if err != nil {
+				return err
+			}
+
+			cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).Find(m.gCtx, filter, options.Find().
+				SetSort(bson.D{{Key: "modules.inhouse.rating", Value: -1}, {Key: "discord.id", Value: 1}}).
+				SetSkip((page-1)*ratingPageSize).
+				SetLimit(ratingPageSize),
+			)
+			if err != nil {
+				return err
+			}
+
+			users := []structures.User{}
+			if err = cur.All(m.gCtx, &users); err != nil {
+				return err
+			}
+
+			pages := (total + ratingPageSize - 1) / ratingPageSize
+			if len(users) == 0 {
+				if pages == 0 {
+					return ctx.ReplyError("Nobody has played an inhouse match yet.")
+				}
+
+				return ctx.ReplyError(fmt.Sprintf("There are only %d pages.", pages))
+			}
+
+			lines := make([]string, len(users))
+			for i, v := range users {
+				stats := v.Modules.Inhouse
+				lines[i] = fmt.Sprintf("**#%d** <@%s> %d (%d-%d)", (page-1)*ratingPageSize+int64(i)+1, v.Discord.ID, stats.Rating, stats.Wins, stats.Losses)
+			}
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:       "Inhouse leaderboard",
+					Description: strings.Join(lines, "\n"),
+					Color:       ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID),
+					Footer: &discordgo.MessageEmbedFooter{
+						Text: fmt.Sprintf("Page %d of %d, ratings are shared by every server", page, pages),
+					},
+				},
+			})
+			return err
+		},
+	}
+}
+
+func (m *Module) RecomputeCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "inhouse recompute"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "recompute")
+		},
+		Info:        "Calculates every inhouse rating again from the results of all matches of every server",
+		ExampleInfo: []string{"inhouse recompute"},
+		Perms:       command.AdminPermission,
+		// the ratings of every guild are rewritten, so only the primary guild can do it.
+		EnabledCmd: func(guildID string) bool {
+			return guildID == m.gCtx.Inst().Guilds.Primary()
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			confirmed, err := ctx.Confirm("Calculate every inhouse rating again from the results of all matches of every server?", recomputeConfirmTimeout)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				_, err = ctx.Send("The ratings were not recomputed.")
+				return err
+			}
+
+			matches, err := m.recompute(m.gCtx)
+			if err != nil {
+				logrus.Error("failed to recompute inhouse ratings: ", err)
+				return err
+			}
+
+			_, err = ctx.Send(fmt.Sprintf("Recomputed the ratings from %d matches.", matches))
+			return err
+		},
+	}
+}
+
+// resultEmbed shows the teams of a finished match with the changes of their ratings.
+func resultEmbed(match structures.InhouseMatch) *discordgo.MessageEmbed {
+	embed := matchEmbed(match)
+	embed.Title = fmt.Sprintf("Inhouse match #%d, %s won", match.Number, teamName(match.Winner))
+
+	return embed
+}
+
+func teamName(team structures.InhouseTeam) string {
+	if team == structures.InhouseTeamDire {
+		return "Dire"
+	}
+
+	return "Radiant"
+}
+
+func pageNumber(ctx *command.Context) (int64, error) {
+	if !ctx.Args.Has("page") {
+		return 1, nil
+	}
+
+	page := ctx.Args.Int("page")
+	if page < 1 {
+		return 0, &command.UsageError{Reason: "The page has to be at least 1."}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,260 @@
+package inhouse
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"github.com/hashicorp/go-multierror"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	voiceInterval           = time.Minute
+	defaultVoiceIdleTimeout = time.Minute * 15
+)
+
+// createVoice creates the voice channels of the teams of a match, only the players of a team can join its channel.
+// the players who are in the waiting room are moved into them.
+func (m *Module) createVoice(s *discordgo.Session, match structures.InhouseMatch) error {
+	cfg := m.config(match.GuildID)
+	if cfg.VoiceCategoryID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Minute)
+	defer cancel()
+
+	channels := map[structures.InhouseTeam]string{}
+	for _, team := range []structures.InhouseTeam{structures.InhouseTeamRadiant, structures.InhouseTeamDire} {
+		channel, err := s.GuildChannelCreateComplex(match.GuildID, discordgo.GuildChannelCreateData{
+			Name:                 fmt.Sprintf("#%d %s", match.Number, teamName(team)),
+			Type:                 discordgo.ChannelTypeGuildVoice,
+			ParentID:             cfg.VoiceCategoryID,
+			PermissionOverwrites: m.voiceOverwrites(s, match, team),
+		})
+		if err != nil {
+			return multierror.Append(err, m.deleteVoice(s, match.GuildID, channels))
+		}
+
+		channels[team] = channel.ID
+	}
+
+	if _, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).UpdateOne(ctx, bson.M{
+		"_id": match.ID,
+	}, bson.M{
+		"$set": bson.M{
+			"voice_channels": channels,
+		},
+	}); err != nil {
+		return multierror.Append(err, m.deleteVoice(s, match.GuildID, channels))
+	}
+
+	if cfg.WaitingRoomChannelID == "" {
+		return nil
+	}
+
+	var err error
+	for _, team := range []structures.InhouseTeam{structures.InhouseTeamRadiant, structures.InhouseTeamDire} {
+		channelID := channels[team]
+		for _, v := range match.Players(team) {
+			state, e := s.State.VoiceState(match.GuildID, v.UserID)
+			if e != nil || state.ChannelID != cfg.WaitingRoomChannelID {
+				continue
+			}
+
+			if e = s.GuildMemberMove(match.GuildID, v.UserID, &channelID); e != nil {
+				err = multierror.Append(err, e)
+			}
+		}
+	}
+
+	return err
+}
+
+// voiceOverwrites locks the voice channel of a team to its players, the moderators and the bot.
+func (m *Module) voiceOverwrites(s *discordgo.Session, match structures.InhouseMatch, team structures.InhouseTeam) []*discordgo.PermissionOverwrite {
+	overwrites := []*discordgo.PermissionOverwrite{
+		{
+			// the id of the everyone role is the id of the guild.
+			ID:   match.GuildID,
+			Type: discordgo.PermissionOverwriteTypeRole,
+			Deny: discordgo.PermissionVoiceConnect,
+		},
+		{
+			ID:    s.State.User.ID,
+			Type:  discordgo.PermissionOverwriteTypeMember,
+			Allow: discordgo.PermissionViewChannel | discordgo.PermissionVoiceConnect | discordgo.PermissionVoiceMoveMembers,
+		},
+	}
+
+	for _, v := range m.config(match.GuildID).ModeratorRoles {
+		overwrites = append(overwrites, &discordgo.PermissionOverwrite{
+			ID:    v,
+			Type:  discordgo.PermissionOverwriteTypeRole,
+			Allow: discordgo.PermissionViewChannel | discordgo.PermissionVoiceConnect,
+		})
+	}
+
+	for _, v := range match.Players(team) {
+		overwrites = append(overwrites, &discordgo.PermissionOverwrite{
+			ID:    v.UserID,
+			Type:  discordgo.PermissionOverwriteTypeMember,
+			Allow: discordgo.PermissionViewChannel | discordgo.PermissionVoiceConnect | discordgo.PermissionVoiceSpeak,
+		})
+	}
+
+	return overwrites
+}
+
+// removeVoice deletes the voice channels of a match, the players still in them are moved back to the waiting room.
+func (m *Module) removeVoice(s *discordgo.Session, match structures.InhouseMatch) error {
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Minute)
+	defer cancel()
+
+	// the channels are claimed first so that they are only deleted once.
+	err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).FindOneAndUpdate(ctx, bson.M{
+		"_id":            match.ID,
+		"voice_channels": bson.M{"$exists": true},
+	}, bson.M{
+		"$unset": bson.M{
+			"voice_channels": 1,
+		},
+	}).Decode(&match)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if waitingRoom := m.config(match.GuildID).WaitingRoomChannelID; waitingRoom != "" {
+		for _, channelID := range match.VoiceChannels {
+			for _, userID := range voiceMembers(s, match.GuildID, channelID) {
+				if e := s.GuildMemberMove(match.GuildID, userID, &waitingRoom); e != nil {
+					err = multierror.Append(err, e)
+				}
+			}
+		}
+	}
+
+	if e := m.deleteVoice(s, match.GuildID, match.VoiceChannels); e != nil {
+		err = multierror.Append(err, e)
+	}
+
+	return err
+}
+
+func (m *Module) deleteVoice(s *discordgo.Session, guildID string, channels map[structures.InhouseTeam]string) error {
+	var err error
+	for _, channelID := range channels {
+		if _, e := s.ChannelDelete(channelID); e != nil {
+			err = multierror.Append(err, e)
+		}
+	}
+
+	return err
+}
+
+// voiceLoop deletes the voice channels of matches which are over or which nobody was in for the idle timeout.
+func (m *Module) voiceLoop() {
+	tick := time.NewTicker(voiceInterval)
+	defer tick.Stop()
+
+	// idle is since when the channels of a match are empty.
+	idle := map[primitive.ObjectID]time.Time{}
+	for {
+		select {
+		case <-m.gCtx.Done():
+			return
+		case <-tick.C:
+		}
+
+		if err := m.cleanVoice(m.gCtx.Inst().Discord.Session(), idle); err != nil {
+			logrus.Error("failed to clean up inhouse voice channels: ", err)
+		}
+	}
+}
+
+func (m *Module) cleanVoice(s *discordgo.Session, idle map[primitive.ObjectID]time.Time) error {
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Minute)
+	defer cancel()
+
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameInhouseMatches).Find(ctx, bson.M{
+		"voice_channels": bson.M{"$exists": true},
+	})
+	if err != nil {
+		return err
+	}
+
+	matches := []structures.InhouseMatch{}
+	if err = cur.All(ctx, &matches); err != nil {
+		return err
+	}
+
+	seen := map[primitive.ObjectID]bool{}
+	for _, match := range matches {
+		seen[match.ID] = true
+
+		if match.Status == structures.InhouseMatchStatusPlaying {
+			empty := true
+			for _, channelID := range match.VoiceChannels {
+				empty = empty && len(voiceMembers(s, match.GuildID, channelID)) == 0
+			}
+			if !empty {
+				delete(idle, match.ID)
+				continue
+			}
+
+			if idle[match.ID].IsZero() {
+				idle[match.ID] = time.Now()
+			}
+
+			timeout := m.config(match.GuildID).VoiceIdleTimeout
+			if timeout <= 0 {
+				timeout = defaultVoiceIdleTimeout
+			}
+			if time.Since(idle[match.ID]) < timeout {
+				continue
+			}
+		}
+
+		delete(idle, match.ID)
+		if e := m.removeVoice(s, match); e != nil {
+			err = multierror.Append(err, e)
+		}
+	}
+
+	for id := range idle {
+		if !seen[id] {
+			delete(idle, id)
+		}
+	}
+
+	return err
+}
+
+// voiceMembers returns the users in a voice channel.
+func voiceMembers(s *discordgo.Session, guildID string, channelID string) []string {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return nil
+	}
+
+	s.State.RLock()
+	defer s.State.RUnlock()
+
+	userIDs := []string{}
+	for _, v := range guild.VoiceStates {
+		if v.ChannelID == channelID {
+			userIDs = append(userIDs, v.UserID)
+		}
+	}
+
+	return userIDs
+}
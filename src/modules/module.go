@@ -1,7 +1,16 @@
 package modules
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/AdmiralBulldogTv/DiscordBot/src/global"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/instance"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/modules/common"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/modules/goodnight"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/modules/inhouse"
@@ -10,46 +19,96 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	ErrUnknownModule  = errors.New("unknown module")
+	ErrModuleRunning  = errors.New("module is already running")
+	ErrModuleStopped  = errors.New("module is not running")
+	ErrModuleStopping = errors.New("module did not stop in time")
+)
+
+const (
+	stopTimeout = time.Second * 30
+	minBackoff  = time.Second * 5
+	maxBackoff  = time.Minute * 5
+)
+
+// Module registers its commands and handlers in Register, they have to be removed again once gCtx is done.
+// The done channel is closed once the module has stopped.
 type Module interface {
 	Name() string
 	Register(gCtx global.Context) (<-chan struct{}, error)
 }
 
-func New(gCtx global.Context) <-chan struct{} {
-	done := make(chan struct{})
+type moduleEntry struct {
+	module Module
+	status instance.ModuleStatus
 
-	modules := []Module{}
-	if gCtx.Config().Modules.Points.Enabled {
-		modules = append(modules, points.New())
-	}
-	if gCtx.Config().Modules.Common.Enabled {
-		modules = append(modules, common.New())
-	}
-	if gCtx.Config().Modules.GoodNight.Enabled {
-		modules = append(modules, goodnight.New())
+	// run is increased on every start so that a previous run can not change the status of the current one.
+	run     int
+	cancel  context.CancelFunc
+	stopped chan struct{}
+	retry   *time.Timer
+}
+
+type manager struct {
+	gCtx global.Context
+
+	mtx     sync.Mutex
+	modules map[string]*moduleEntry
+}
+
+func New(gCtx global.Context) <-chan struct{} {
+	m := &manager{
+		gCtx:    gCtx,
+		modules: map[string]*moduleEntry{},
 	}
-	if gCtx.Config().Modules.InHouse.Enabled {
-		modules = append(modules, inhouse.New())
+
+	trackerModule := tracker.New()
+	for _, v := range []Module{points.New(), common.New(), goodnight.New(), inhouse.New(trackerModule.Dota), trackerModule} {
+		m.modules[strings.ToLower(v.Name())] = &moduleEntry{
+			module: v,
+			status: instance.ModuleStatus{
+				Name:  v.Name(),
+				State: instance.ModuleStateStopped,
+				Since: time.Now(),
+			},
+		}
 	}
-	if gCtx.Config().Modules.Tracker.Enabled {
-		modules = append(modules, tracker.New())
+
+	gCtx.Inst().Modules = m
+
+	if err := gCtx.Inst().Discord.RegisterCommand("modules", m.ModulesCmd()); err != nil {
+		logrus.Error("failed to register modules command: ", err)
 	}
-	dones := []<-chan struct{}{}
 
-	for _, module := range modules {
-		d, err := module.Register(gCtx)
-		if err != nil {
-			logrus.Error("failed to load: ", module.Name())
+	for name := range m.modules {
+		// modules are enabled per guild, so they are started even when the primary guild does not use them.
+		// the tracker is the exception since it logs into steam.
+		if name == "tracker" && !gCtx.Config().Modules.Tracker.Enabled {
 			continue
 		}
-		dones = append(dones, d)
+
+		_ = m.Start(name)
 	}
 
+	done := make(chan struct{})
 	go func() {
 		<-gCtx.Done()
 
-		for _, d := range dones {
-			<-d
+		m.mtx.Lock()
+		stopped := []chan struct{}{}
+		for _, e := range m.modules {
+			if e.retry != nil {
+				e.retry.Stop()
+			}
+			if e.stopped != nil {
+				stopped = append(stopped, e.stopped)
+			}
+		}
+		m.mtx.Unlock()
+
+		for _, v := range stopped {
+			<-v
 		}
 
 		close(done)
@@ -57,3 +116,187 @@ func New(gCtx global.Context) <-chan struct{} {
 
 	return done
 }
+
+func (m *manager) Status() []instance.ModuleStatus {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	statuses := make([]instance.ModuleStatus, 0, len(m.modules))
+	for _, e := range m.modules {
+		statuses = append(statuses, e.status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+func (m *manager) Start(name string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	e, ok := m.modules[strings.ToLower(name)]
+	if !ok {
+		return ErrUnknownModule
+	}
+
+	switch e.status.State {
+	case instance.ModuleStateStarting, instance.ModuleStateRunning, instance.ModuleStateStopping:
+		return ErrModuleRunning
+	}
+
+	if e.retry != nil {
+		e.retry.Stop()
+		e.retry = nil
+	}
+	e.status.Attempts = 0
+
+	m.start(e)
+	return nil
+}
+
+func (m *manager) Stop(name string) error {
+	m.mtx.Lock()
+
+	e, ok := m.modules[strings.ToLower(name)]
+	if !ok {
+		m.mtx.Unlock()
+		return ErrUnknownModule
+	}
+
+	switch e.status.State {
+	case instance.ModuleStateStopped, instance.ModuleStateStopping:
+		m.mtx.Unlock()
+		return ErrModuleStopped
+	case instance.ModuleStateFailed:
+		// a failed module is not running, only its retry has to be cancelled.
+		if e.retry != nil {
+			e.retry.Stop()
+			e.retry = nil
+		}
+		m.setState(e, instance.ModuleStateStopped, nil)
+		m.mtx.Unlock()
+		return nil
+	}
+
+	stopped := e.stopped
+	m.setState(e, instance.ModuleStateStopping, nil)
+	e.cancel()
+	m.mtx.Unlock()
+
+	timer := time.NewTimer(stopTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+	case <-timer.C:
+		logrus.WithField("module", e.module.Name()).Error("module did not stop in time")
+		return ErrModuleStopping
+	}
+
+	logrus.WithField("module", e.module.Name()).Info("module stopped")
+
+	return nil
+}
+
+func (m *manager) Restart(name string) error {
+	if err := m.Stop(name); err != nil && err != ErrModuleStopped {
+		return err
+	}
+
+	return m.Start(name)
+}
+
+// start registers a module in the background, mtx must be held.
+func (m *manager) start(e *moduleEntry) {
+	if m.gCtx.Err() != nil {
+		return
+	}
+
+	e.run++
+	run := e.run
+
+	ctx, cancel := global.WithCancel(m.gCtx)
+	stopped := make(chan struct{})
+	e.cancel = cancel
+	e.stopped = stopped
+	m.setState(e, instance.ModuleStateStarting, nil)
+
+	go func() {
+		defer close(stopped)
+
+		done, err := e.module.Register(ctx)
+		if err != nil {
+			// the commands which were registered before the error are removed once the module is done.
+			cancel()
+			if done != nil {
+				<-done
+			}
+
+			m.ended(e, run, fmt.Errorf("failed to register: %w", err))
+			return
+		}
+
+		m.mtx.Lock()
+		if e.run == run && e.status.State == instance.ModuleStateStarting {
+			m.setState(e, instance.ModuleStateRunning, nil)
+			logrus.WithField("module", e.module.Name()).Info("module started")
+		}
+		m.mtx.Unlock()
+
+		if done != nil {
+			<-done
+		}
+		cancel()
+
+		m.ended(e, run, errors.New("stopped unexpectedly"))
+	}()
+}
+
+// ended is called once a run of a module is done, unless the module was stopped it is retried with a backoff.
+func (m *manager) ended(e *moduleEntry, run int, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if e.run != run || m.gCtx.Err() != nil {
+		return
+	}
+
+	if e.status.State == instance.ModuleStateStopping {
+		m.setState(e, instance.ModuleStateStopped, nil)
+		return
+	}
+
+	e.status.Attempts++
+	backoff := minBackoff << (e.status.Attempts - 1)
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+
+	logrus.WithField("module", e.module.Name()).WithField("attempts", e.status.Attempts).Errorf("module failed, retrying in %s: %s", backoff, err.Error())
+
+	m.setState(e, instance.ModuleStateFailed, err)
+	e.status.RetryAt = time.Now().Add(backoff)
+	e.retry = time.AfterFunc(backoff, func() {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+
+		if e.run == run && e.status.State == instance.ModuleStateFailed {
+			e.retry = nil
+			m.start(e)
+		}
+	})
+}
+
+// setState updates the status of a module, mtx must be held.
+func (m *manager) setState(e *moduleEntry, state instance.ModuleState, err error) {
+	e.status.State = state
+	e.status.Since = time.Now()
+	e.status.RetryAt = time.Time{}
+	e.status.Error = ""
+	if err != nil {
+		e.status.Error = err.Error()
+	}
+}
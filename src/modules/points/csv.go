@@ -0,0 +1,384 @@
+package points
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	importMaxSize        = 1 << 20
+	importMaxRows        = 5000
+	importConfirmTimeout = time.Minute
+	importMaxReport      = 2000
+)
+
+var snowflakeRegex = regexp.MustCompile(`^\d{17,20}$`)
+
+// importRow is a line of an import, the points are added to the member unless Set replaces them.
+type importRow struct {
+	Line   int
+	UserID string
+	Points int32
+	Set    bool
+}
+
+type importError struct {
+	Line   int
+	UserID string
+	Reason string
+}
+
+func (m *Module) ImportCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points import"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "import")
+		},
+		Info: "Adds or sets the points of many members from a csv file with the columns user_id, points and optionally " +
+			"mode, which is add or set",
+		ExampleInfo: []string{"points import"},
+		Perms:       m.moderatorPermission(),
+		Args: []command.Arg{
+			{
+				Name:        "file",
+				Description: "A csv file with the rows user_id,points[,add|set]",
+				Type:        command.ArgAttachment,
+				Required:    true,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			file := ctx.Args.Attachment("file")
+			if file.Size > importMaxSize {
+				return ctx.ReplyError(fmt.Sprintf("The file can be at most %d KB.", importMaxSize>>10))
+			}
+
+			if err := ctx.Defer(); err != nil {
+				return err
+			}
+
+			data, err := download(m.gCtx, file.URL)
+			if err != nil {
+				return err
+			}
+
+			rows, errs, err := parseImport(bytes.NewReader(data))
+			if err != nil {
+				return ctx.ReplyError(fmt.Sprintf("The file is not a valid csv file: %s", err.Error()))
+			}
+			if len(rows) == 0 {
+				_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+					Embed: importReport(ctx, "There is nothing to import", errs),
+				})
+				return err
+			}
+
+			var (
+				added int64
+				set   int
+			)
+			for _, v := range rows {
+				if v.Set {
+					set++
+				} else {
+					added += int64(v.Points)
+				}
+			}
+
+			title := fmt.Sprintf("Importing %d rows, %d add %d points and %d set the points", len(rows), len(rows)-set, added, set)
+			if len(errs) != 0 {
+				title += fmt.Sprintf(", %d rows are skipped", len(errs))
+			}
+
+			preview := &discordgo.MessageSend{
+				Embed: importReport(ctx, title, errs),
+			}
+			if len(errs) != 0 {
+				preview.Files = []*discordgo.File{importErrorsFile(errs)}
+			}
+
+			if _, err = ctx.ReplyComplex(preview); err != nil {
+				return err
+			}
+
+			confirmed, err := ctx.Confirm("Apply the import?", importConfirmTimeout)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				_, err = ctx.Send("The import was cancelled.")
+				return err
+			}
+
+			failed := m.applyImport(m.gCtx, ctx.GuildID, ctx.Author.ID, rows)
+
+			// the points roles of every imported member are synced at once instead of one by one.
+			go func() {
+				if _, err := m.syncGuild(m.gCtx, ctx.GuildID, false); err != nil {
+					logrus.WithField("guild_id", ctx.GuildID).Error("failed to sync points roles: ", err)
+				}
+			}()
+
+			title = fmt.Sprintf("Imported %d rows", len(rows)-len(failed))
+			if len(failed) != 0 {
+				title += fmt.Sprintf(", %d failed", len(failed))
+			}
+
+			msg := &discordgo.MessageSend{
+				Embed: importReport(ctx, title, failed),
+			}
+			if len(failed) != 0 {
+				msg.Files = []*discordgo.File{importErrorsFile(failed)}
+			}
+
+			_, err = ctx.ReplyComplex(msg)
+			return err
+		},
+	}
+}
+
+func (m *Module) ExportCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points export"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "export")
+		},
+		Info:        "Exports the points of every member as a csv file",
+		ExampleInfo: []string{"points export"},
+		Perms:       m.moderatorPermission(),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			if err := ctx.Defer(); err != nil {
+				return err
+			}
+
+			cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).Find(m.gCtx, bson.M{
+				"guild_id":              ctx.GuildID,
+				"modules.points.points": bson.M{"$ne": 0},
+			}, options.Find().
+				SetSort(bson.D{{Key: "modules.points.points", Value: -1}, {Key: "user_id", Value: 1}}).
+				SetProjection(bson.M{"user_id": 1, "modules.points.points": 1}),
+			)
+			if err != nil {
+				return err
+			}
+
+			members := []structures.Member{}
+			if err = cur.All(m.gCtx, &members); err != nil {
+				return err
+			}
+
+			buf := &bytes.Buffer{}
+			w := csv.NewWriter(buf)
+			_ = w.Write([]string{"user_id", "points"})
+			for _, v := range members {
+				_ = w.Write([]string{v.UserID, strconv.Itoa(int(v.Modules.Points.Points))})
+			}
+			w.Flush()
+			if err = w.Error(); err != nil {
+				return err
+			}
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Content: fmt.Sprintf("Exported the points of %d members.", len(members)),
+				Files: []*discordgo.File{{
+					Name:        fmt.Sprintf("points-%s.csv", time.Now().Format("2006-01-02")),
+					ContentType: "text/csv",
+					Reader:      buf,
+				}},
+			})
+			return err
+		},
+	}
+}
+
+// parseImport reads the rows of an import, rows which are invalid are returned as errors instead.
+// the first row is skipped when it is a header.
+func parseImport(r io.Reader) ([]importRow, []importError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows := []importRow{}
+	errs := []importError{}
+	seen := map[string]int{}
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if n > importMaxRows {
+			return nil, nil, fmt.Errorf("the file can have at most %d rows", importMaxRows)
+		}
+		line, _ := reader.FieldPos(0)
+
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+
+		if n == 1 && strings.EqualFold(record[0], "user_id") {
+			continue
+		}
+
+		row, reason := parseImportRow(line, record)
+		if reason == "" && seen[row.UserID] != 0 {
+			reason = fmt.Sprintf("the member is already in line %d", seen[row.UserID])
+		}
+		if reason != "" {
+			errs = append(errs, importError{Line: line, UserID: row.UserID, Reason: reason})
+			continue
+		}
+
+		seen[row.UserID] = line
+		rows = append(rows, row)
+	}
+
+	return rows, errs, nil
+}
+
+func parseImportRow(line int, record []string) (importRow, string) {
+	row := importRow{Line: line, UserID: record[0]}
+	if len(record) < 2 || len(record) > 3 {
+		return row, "expected user_id,points[,add|set]"
+	}
+
+	if !snowflakeRegex.MatchString(row.UserID) {
+		return row, fmt.Sprintf("`%s` is not a user id", row.UserID)
+	}
+
+	points, err := strconv.ParseInt(strings.TrimPrefix(record[1], "+"), 10, 64)
+	if err != nil || points > math.MaxInt32 || points < math.MinInt32 {
+		return row, fmt.Sprintf("`%s` is not a valid amount of points", record[1])
+	}
+	row.Points = int32(points)
+
+	if len(record) == 3 {
+		switch strings.ToLower(record[2]) {
+		case "", "add", "delta":
+		case "set", "absolute":
+			row.Set = true
+		default:
+			return row, fmt.Sprintf("`%s` is not a mode, use add or set", record[2])
+		}
+	}
+
+	if row.Set && row.Points < 0 {
+		return row, "the points can not be set to a negative amount"
+	}
+	if !row.Set && row.Points == 0 {
+		return row, "adding 0 points does nothing"
+	}
+
+	return row, ""
+}
+
+// applyImport changes the points of every row, the rows which failed are returned.
+func (m *Module) applyImport(ctx context.Context, guildID string, actorID string, rows []importRow) []importError {
+	failed := []importError{}
+	for _, v := range rows {
+		tx := structures.PointsTransaction{
+			GuildID: guildID,
+			UserID:  v.UserID,
+			Source:  structures.PointsSourceImport,
+			ActorID: actorID,
+		}
+
+		var err error
+		switch {
+		case v.Set:
+			_, err = m.setPoints(ctx, tx, v.Points)
+		case v.Points < 0:
+			// points which are taken can not drive the balance below zero.
+			tx.Delta = v.Points
+			_, err = m.takePoints(ctx, tx)
+		default:
+			tx.Delta = v.Points
+			_, err = m.addPoints(ctx, tx)
+		}
+		if err == ErrInsufficientPoints {
+			failed = append(failed, importError{Line: v.Line, UserID: v.UserID, Reason: fmt.Sprintf("<@%s> does not have %d points", v.UserID, -v.Points)})
+			continue
+		}
+		if err != nil {
+			logrus.WithField("guild_id", guildID).WithField("user_id", v.UserID).Error("failed to import points: ", err)
+			failed = append(failed, importError{Line: v.Line, UserID: v.UserID, Reason: "the points could not be changed"})
+		}
+	}
+
+	return failed
+}
+
+func importReport(ctx *command.Context, title string, errs []importError) *discordgo.MessageEmbed {
+	lines := make([]string, len(errs))
+	for i, v := range errs {
+		lines[i] = fmt.Sprintf("Line %d: %s", v.Line, v.Reason)
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       title,
+		Description: reportLines(lines, importMaxReport),
+		Color:       ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID),
+	}
+}
+
+// importErrorsFile lists the failed rows as a csv file, so that they can be fixed and imported again.
+func importErrorsFile(errs []importError) *discordgo.File {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"line", "user_id", "reason"})
+	for _, v := range errs {
+		_ = w.Write([]string{strconv.Itoa(v.Line), v.UserID, v.Reason})
+	}
+	w.Flush()
+
+	return &discordgo.File{
+		Name:        "import-errors.csv",
+		ContentType: "text/csv",
+		Reader:      buf,
+	}
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad response downloading attachment: %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, importMaxSize+1))
+}
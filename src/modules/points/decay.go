@@ -0,0 +1,122 @@
+package points
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	decayInterval = time.Hour
+	decayWeek     = time.Hour * 24 * 7
+)
+
+// trackActivity marks the members who have no activity yet as active now,
+// so that enabling decay does not take points from members who were active before activity was tracked.
+func (m *Module) trackActivity() error {
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Minute)
+	defer cancel()
+
+	_, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).UpdateMany(ctx, bson.M{
+		"modules.points.last_active": bson.M{"$exists": false},
+	}, bson.M{
+		"$set": bson.M{
+			"modules.points.last_active": time.Now(),
+		},
+	})
+	return err
+}
+
+// decayLoop takes points from the members who did not earn any for a week, once per inactive week.
+func (m *Module) decayLoop() {
+	tick := time.NewTicker(decayInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-m.gCtx.Done():
+			return
+		case <-tick.C:
+		}
+
+		s := m.gCtx.Inst().Discord.Session()
+
+		s.State.RLock()
+		guildIDs := make([]string, len(s.State.Guilds))
+		for i, g := range s.State.Guilds {
+			guildIDs[i] = g.ID
+		}
+		s.State.RUnlock()
+
+		for _, guildID := range guildIDs {
+			decayed, err := m.decayGuild(m.gCtx, guildID)
+			if err != nil {
+				logrus.WithField("guild_id", guildID).Error("failed to decay points: ", err)
+			}
+			if decayed != 0 {
+				logrus.WithField("guild_id", guildID).Infof("points, decayed the points of %d members", decayed)
+			}
+		}
+	}
+}
+
+func (m *Module) decayGuild(ctx context.Context, guildID string) (int, error) {
+	cfg := m.config(guildID)
+	if !cfg.Enabled || cfg.DecayPercent == 0 {
+		return 0, nil
+	}
+
+	weekAgo := time.Now().Add(-decayWeek)
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).Find(ctx, bson.M{
+		"guild_id":                   guildID,
+		"modules.points.points":      bson.M{"$gt": 0},
+		"modules.points.last_active": bson.M{"$lt": weekAgo},
+		"modules.points.last_decay":  bson.M{"$not": bson.M{"$gte": weekAgo}},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	members := []structures.Member{}
+	if err = cur.All(ctx, &members); err != nil {
+		return 0, err
+	}
+
+	decayed := 0
+	for _, v := range members {
+		// the decay is claimed first so that a member only decays once per week.
+		res, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).UpdateOne(ctx, bson.M{
+			"_id":                       v.ID,
+			"modules.points.last_decay": bson.M{"$not": bson.M{"$gte": weekAgo}},
+		}, bson.M{
+			"$set": bson.M{
+				"modules.points.last_decay": time.Now(),
+			},
+		})
+		if err != nil {
+			return decayed, err
+		}
+		if res.ModifiedCount == 0 {
+			continue
+		}
+
+		_, err = m.addPoints(ctx, structures.PointsTransaction{
+			GuildID: guildID,
+			UserID:  v.UserID,
+			Source:  structures.PointsSourceDecay,
+			Delta:   -int32(math.Ceil(float64(v.Modules.Points.Points) * float64(cfg.DecayPercent) / 100)),
+		})
+		if err != nil {
+			return decayed, err
+		}
+
+		decayed++
+	}
+
+	return decayed, nil
+}
@@ -0,0 +1,317 @@
+package points
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const leaderboardPageSize = 10
+
+type leaderboardEntry struct {
+	UserID string `bson:"_id"`
+	Points int64  `bson:"points"`
+}
+
+var pageArg = command.Arg{
+	Name:        "page",
+	Description: "The page of the leaderboard, defaults to the first one",
+	Type:        command.ArgInteger,
+}
+
+func (m *Module) TopCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points top"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "top")
+		},
+		Info:        "Shows the users with the most points",
+		ExampleInfo: []string{"points top", "points top 2"},
+		Args:        []command.Arg{pageArg},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			page, err := leaderboardPage(ctx)
+			if err != nil {
+				return err
+			}
+
+			filter := bson.M{
+				"guild_id":              ctx.GuildID,
+				"modules.points.points": bson.M{"$gt": 0},
+			}
+
+			total, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).CountDocuments(m.gCtx, filter)
+			if err != nil {
+				return err
+			}
+
+			cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).Find(m.gCtx, filter, options.Find().
+				SetSort(bson.D{{Key: "modules.points.points", Value: -1}, {Key: "user_id", Value: 1}}).
+				SetSkip((page-1)*leaderboardPageSize).
+				SetLimit(leaderboardPageSize),
+			)
+			if err != nil {
+				return err
+			}
+
+			members := []structures.Member{}
+			if err = cur.All(m.gCtx, &members); err != nil {
+				return err
+			}
+
+			entries := make([]leaderboardEntry, len(members))
+			for i, v := range members {
+				entries[i] = leaderboardEntry{UserID: v.UserID, Points: int64(v.Modules.Points.Points)}
+			}
+
+			return replyLeaderboard(ctx, "Leaderboard", entries, page, total)
+		},
+	}
+}
+
+// WindowTopCmd shows the users who gained the most points within the last window, based on the ledger.
+func (m *Module) WindowTopCmd(name string, period string, window time.Duration) command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points " + name
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], name)
+		},
+		Info:        fmt.Sprintf("Shows the users who gained the most points in the last %s", period),
+		ExampleInfo: []string{"points " + name, fmt.Sprintf("points %s 2", name)},
+		Args:        []command.Arg{pageArg},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			page, err := leaderboardPage(ctx)
+			if err != nil {
+				return err
+			}
+
+			cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsLedger).Aggregate(m.gCtx, append(windowPipeline(ctx.GuildID, window),
+				bson.M{"$match": bson.M{"points": bson.M{"$gt": 0}}},
+				bson.M{"$sort": bson.D{{Key: "points", Value: -1}, {Key: "_id", Value: 1}}},
+				bson.M{"$facet": bson.M{
+					"entries": bson.A{
+						bson.M{"$skip": (page - 1) * leaderboardPageSize},
+						bson.M{"$limit": leaderboardPageSize},
+					},
+					"total": bson.A{
+						bson.M{"$count": "total"},
+					},
+				}},
+			))
+			if err != nil {
+				return err
+			}
+
+			results := []struct {
+				Entries []leaderboardEntry `bson:"entries"`
+				Total   []struct {
+					Total int64 `bson:"total"`
+				} `bson:"total"`
+			}{}
+			if err = cur.All(m.gCtx, &results); err != nil {
+				return err
+			}
+
+			var (
+				entries []leaderboardEntry
+				total   int64
+			)
+			if len(results) != 0 {
+				entries = results[0].Entries
+				if len(results[0].Total) != 0 {
+					total = results[0].Total[0].Total
+				}
+			}
+
+			return replyLeaderboard(ctx, fmt.Sprintf("Leaderboard of the last %s", period), entries, page, total)
+		},
+	}
+}
+
+func (m *Module) RankCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points rank"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "rank")
+		},
+		Info:        "Shows the rank of a user on the leaderboards",
+		ExampleInfo: []string{"points rank", "points rank Troy"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to look up, defaults to you",
+				Type:        command.ArgMember,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
+			if member == nil {
+				member = ctx.Member
+			}
+
+			result := structures.Member{}
+			err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).FindOne(m.gCtx, bson.M{
+				"guild_id": ctx.GuildID,
+				"user_id":  member.User.ID,
+			}).Decode(&result)
+			if err != nil && err != mongo.ErrNoDocuments {
+				return err
+			}
+
+			points := int64(result.Modules.Points.Points)
+			fields := []*discordgo.MessageEmbedField{}
+
+			higher, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).CountDocuments(m.gCtx, bson.M{
+				"guild_id":              ctx.GuildID,
+				"modules.points.points": bson.M{"$gt": points},
+			})
+			if err != nil {
+				return err
+			}
+			fields = append(fields, rankField("All time", points, higher))
+
+			for _, v := range []struct {
+				name   string
+				window time.Duration
+			}{{"Last week", time.Hour * 24 * 7}, {"Last month", time.Hour * 24 * 30}} {
+				gained, higher, err := m.windowRank(m.gCtx, ctx.GuildID, member.User.ID, v.window)
+				if err != nil {
+					return err
+				}
+
+				fields = append(fields, rankField(v.name, gained, higher))
+			}
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:  fmt.Sprintf("Rank of %s", member.User),
+					Color:  ctx.Session.State.UserColor(member.User.ID, ctx.ChannelID),
+					Fields: fields,
+				},
+			})
+			return err
+		},
+	}
+}
+
+// windowRank returns the points a user gained within window and how many users gained more.
+func (m *Module) windowRank(ctx context.Context, guildID string, userID string, window time.Duration) (int64, int64, error) {
+	entries := []leaderboardEntry{}
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsLedger).Aggregate(ctx, append(windowPipeline(guildID, window),
+		bson.M{"$match": bson.M{"_id": userID}},
+	))
+	if err == nil {
+		err = cur.All(ctx, &entries)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var points int64
+	if len(entries) != 0 {
+		points = entries[0].Points
+	}
+
+	counts := []struct {
+		Higher int64 `bson:"higher"`
+	}{}
+	cur, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsLedger).Aggregate(ctx, append(windowPipeline(guildID, window),
+		bson.M{"$match": bson.M{"points": bson.M{"$gt": points}}},
+		bson.M{"$count": "higher"},
+	))
+	if err == nil {
+		err = cur.All(ctx, &counts)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var higher int64
+	if len(counts) != 0 {
+		higher = counts[0].Higher
+	}
+
+	return points, higher, nil
+}
+
+// windowPipeline sums up the points every user gained within window, the reset at the end of a season is left out.
+func windowPipeline(guildID string, window time.Duration) bson.A {
+	return bson.A{
+		bson.M{"$match": bson.M{
+			"guild_id":   guildID,
+			"created_at": bson.M{"$gte": time.Now().Add(-window)},
+			"source":     bson.M{"$ne": structures.PointsSourceSeason},
+		}},
+		bson.M{"$group": bson.M{
+			"_id":    "$user_id",
+			"points": bson.M{"$sum": "$delta"},
+		}},
+	}
+}
+
+func leaderboardPage(ctx *command.Context) (int64, error) {
+	if !ctx.Args.Has("page") {
+		return 1, nil
+	}
+
+	page := ctx.Args.Int("page")
+	if page < 1 {
+		return 0, &command.UsageError{Reason: "The page has to be at least 1."}
+	}
+
+	return page, nil
+}
+
+func replyLeaderboard(ctx *command.Context, title string, entries []leaderboardEntry, page int64, total int64) error {
+	pages := (total + leaderboardPageSize - 1) / leaderboardPageSize
+	if len(entries) == 0 {
+		if pages == 0 {
+			return ctx.ReplyError("Nobody has any points yet.")
+		}
+
+		return ctx.ReplyError(fmt.Sprintf("There are only %d pages.", pages))
+	}
+
+	lines := make([]string, len(entries))
+	for i, v := range entries {
+		lines[i] = fmt.Sprintf("**#%d** <@%s> %d points", (page-1)*leaderboardPageSize+int64(i)+1, v.UserID, v.Points)
+	}
+
+	_, err := ctx.ReplyComplex(&discordgo.MessageSend{
+		Embed: &discordgo.MessageEmbed{
+			Title:       title,
+			Description: strings.Join(lines, "\n"),
+			Color:       ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID),
+			Footer: &discordgo.MessageEmbedFooter{
+				Text: fmt.Sprintf("Page %d of %d", page, pages),
+			},
+		},
+	})
+	return err
+}
+
+func rankField(name string, points int64, higher int64) *discordgo.MessageEmbedField {
+	value := "Unranked"
+	if points > 0 {
+		value = fmt.Sprintf("#%d with %d points", higher+1, points)
+	}
+
+	return &discordgo.MessageEmbedField{
+		Name:   name,
+		Value:  value,
+		Inline: true,
+	}
+}
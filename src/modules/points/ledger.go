@@ -0,0 +1,295 @@
+package points
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const historyPageSize = 10
+
+var ErrInsufficientPoints = errors.New("not enough points")
+
+// addPoints adds tx.Delta to the points of a member and records the change in the ledger.
+// points earned by talking also mark the member as active, so that their points do not decay.
+func (m *Module) addPoints(ctx context.Context, tx structures.PointsTransaction) (structures.PointsTransaction, error) {
+	update := bson.M{
+		"$inc": bson.M{
+			"modules.points.points": tx.Delta,
+		},
+	}
+	if tx.Source == structures.PointsSourceMessage || tx.Source == structures.PointsSourceVoice {
+		update["$set"] = bson.M{
+			"modules.points.last_active": time.Now(),
+		}
+	}
+
+	member := structures.Member{}
+	err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).FindOneAndUpdate(ctx, bson.M{
+		"guild_id": tx.GuildID,
+		"user_id":  tx.UserID,
+	}, update, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&member)
+	if err != nil {
+		return tx, err
+	}
+
+	tx.Balance = member.Modules.Points.Points
+	return m.record(ctx, tx)
+}
+
+// takePoints removes -tx.Delta points from a member, the balance is checked by the same update which takes the points.
+func (m *Module) takePoints(ctx context.Context, tx structures.PointsTransaction) (structures.PointsTransaction, error) {
+	member := structures.Member{}
+	err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).FindOneAndUpdate(ctx, bson.M{
+		"guild_id":              tx.GuildID,
+		"user_id":               tx.UserID,
+		"modules.points.points": bson.M{"$gte": -tx.Delta},
+	}, bson.M{
+		"$inc": bson.M{
+			"modules.points.points": tx.Delta,
+		},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&member)
+	if err == mongo.ErrNoDocuments {
+		return tx, ErrInsufficientPoints
+	}
+	if err != nil {
+		return tx, err
+	}
+
+	tx.Balance = member.Modules.Points.Points
+	return m.record(ctx, tx)
+}
+
+// setPoints replaces the points of a member and records the difference in the ledger.
+func (m *Module) setPoints(ctx context.Context, tx structures.PointsTransaction, points int32) (structures.PointsTransaction, error) {
+	old := structures.Member{}
+	err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).FindOneAndUpdate(ctx, bson.M{
+		"guild_id": tx.GuildID,
+		"user_id":  tx.UserID,
+	}, bson.M{
+		"$set": bson.M{
+			"modules.points.points": points,
+		},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)).Decode(&old)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return tx, err
+	}
+
+	tx.Delta = points - old.Modules.Points.Points
+	tx.Balance = points
+	return m.record(ctx, tx)
+}
+
+// record adds a change of the points of a member to the ledger.
+func (m *Module) record(ctx context.Context, tx structures.PointsTransaction) (structures.PointsTransaction, error) {
+	if tx.ID.IsZero() {
+		tx.ID = primitive.NewObjectID()
+	}
+	tx.CreatedAt = time.Now()
+
+	_, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsLedger).InsertOne(ctx, tx)
+	return tx, err
+}
+
+func (m *Module) HistoryCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points history"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "history")
+		},
+		Info:        "Shows the latest changes of the points of a user",
+		ExampleInfo: []string{"points history", "points history Troy", "points history Troy 2"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to look up, defaults to you",
+				Type:        command.ArgMember,
+			},
+			{
+				Name:        "page",
+				Description: "The page of the history, defaults to the latest changes",
+				Type:        command.ArgInteger,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
+			if member == nil {
+				member = ctx.Member
+			}
+
+			page, err := leaderboardPage(ctx)
+			if err != nil {
+				return err
+			}
+
+			filter := bson.M{
+				"guild_id": ctx.GuildID,
+				"user_id":  member.User.ID,
+			}
+
+			total, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsLedger).CountDocuments(m.gCtx, filter)
+			if err != nil {
+				return err
+			}
+
+			cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsLedger).Find(m.gCtx, filter, options.Find().
+				SetSort(bson.D{{Key: "created_at", Value: -1}}).
+				SetSkip((page-1)*historyPageSize).
+				SetLimit(historyPageSize),
+			)
+			if err != nil {
+				return err
+			}
+
+			txs := []structures.PointsTransaction{}
+			if err = cur.All(m.gCtx, &txs); err != nil {
+				return err
+			}
+
+			pages := (total + historyPageSize - 1) / historyPageSize
+			if len(txs) == 0 {
+				if pages == 0 {
+					return ctx.ReplyError(fmt.Sprintf("%s has no points history.", member.User))
+				}
+
+				return ctx.ReplyError(fmt.Sprintf("There are only %d pages.", pages))
+			}
+
+			lines := make([]string, len(txs))
+			for i, v := range txs {
+				lines[i] = formatTransaction(v)
+			}
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:       fmt.Sprintf("Points history of %s", member.User),
+					Description: strings.Join(lines, "\n"),
+					Color:       ctx.Session.State.UserColor(member.User.ID, ctx.ChannelID),
+					Footer: &discordgo.MessageEmbedFooter{
+						Text: fmt.Sprintf("Page %d of %d", page, pages),
+					},
+				},
+			})
+			return err
+		},
+	}
+}
+
+func (m *Module) RevertCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points revert"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "revert")
+		},
+		Info:        "Undo a change of points, the id is shown in the history",
+		ExampleInfo: []string{"points revert 62a1f0c2e4b0a1b2c3d4e5f6"},
+		Args: []command.Arg{
+			{
+				Name:        "transaction",
+				Description: "The id of the change",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+		},
+		Perms: command.RolePermission(func(guildID string) []string {
+			return m.config(guildID).ModeratorRoles
+		}),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			id, err := primitive.ObjectIDFromHex(ctx.Args.String("transaction"))
+			if err != nil {
+				return &command.UsageError{Reason: "The id of the change is not valid."}
+			}
+
+			tx := structures.PointsTransaction{}
+			err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsLedger).FindOne(m.gCtx, bson.M{
+				"_id":      id,
+				"guild_id": ctx.GuildID,
+			}).Decode(&tx)
+			if err == mongo.ErrNoDocuments {
+				return ctx.ReplyError("There is no change with this id.")
+			}
+			if err != nil {
+				return err
+			}
+
+			if tx.Source == structures.PointsSourceRevert {
+				return ctx.ReplyError("A revert can not be reverted.")
+			}
+
+			// the transaction is claimed first so that it can only be reverted once.
+			revertID := primitive.NewObjectID()
+			res, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsLedger).UpdateOne(m.gCtx, bson.M{
+				"_id":         id,
+				"reverted_by": bson.M{"$exists": false},
+			}, bson.M{
+				"$set": bson.M{
+					"reverted_by": revertID,
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if res.MatchedCount == 0 {
+				return ctx.ReplyError("This change was already reverted.")
+			}
+
+			// points which are taken back can not drive the balance below zero.
+			change := m.addPoints
+			if tx.Delta > 0 {
+				change = m.takePoints
+			}
+
+			revert, err := change(m.gCtx, structures.PointsTransaction{
+				ID:       revertID,
+				GuildID:  ctx.GuildID,
+				UserID:   tx.UserID,
+				Source:   structures.PointsSourceRevert,
+				ActorID:  ctx.Author.ID,
+				Delta:    -tx.Delta,
+				RevertOf: tx.ID,
+			})
+			if err != nil {
+				_, _ = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsLedger).UpdateOne(m.gCtx, bson.M{
+					"_id": id,
+				}, bson.M{
+					"$unset": bson.M{
+						"reverted_by": 1,
+					},
+				})
+				if err == ErrInsufficientPoints {
+					return ctx.ReplyError(fmt.Sprintf("<@%s> does not have the %d points anymore which this change gave them.", tx.UserID, tx.Delta))
+				}
+				return err
+			}
+
+			_, err = ctx.Reply(fmt.Sprintf("Reverted the change, <@%s> has %d points now.", tx.UserID, revert.Balance))
+			return err
+		},
+	}
+}
+
+func formatTransaction(tx structures.PointsTransaction) string {
+	line := fmt.Sprintf("`%s` <t:%d:R> **%+d** %s → %d", tx.ID.Hex(), tx.CreatedAt.Unix(), tx.Delta, strings.ReplaceAll(string(tx.Source), "_", " "), tx.Balance)
+	if tx.ActorID != "" {
+		line += fmt.Sprintf(" by <@%s>", tx.ActorID)
+	}
+	if !tx.RevertedBy.IsZero() {
+		line += " (reverted)"
+	}
+
+	return line
+}
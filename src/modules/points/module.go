@@ -2,16 +2,18 @@ package points
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
+	"math"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/AdmiralBulldogTv/DiscordBot/src/configure"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/global"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
-	"github.com/AdmiralBulldogTv/DiscordBot/src/utils"
 	"github.com/bwmarrin/discordgo"
 	"github.com/hashicorp/go-multierror"
 	"github.com/sirupsen/logrus"
@@ -22,10 +24,19 @@ import (
 type Module struct {
 	done chan struct{}
 	gCtx global.Context
+
+	// voiceLocks are the locks of the voice sessions of every guild, voiceMtx guards the map.
+	voiceMtx    sync.Mutex
+	voiceLocks  map[string]*sync.Mutex
+	roleSyncMtx sync.Mutex
 }
 
+var errSyncRunning = errors.New("a sync is already running")
+
 func New() *Module {
-	return &Module{}
+	return &Module{
+		voiceLocks: map[string]*sync.Mutex{},
+	}
 }
 
 func (m *Module) Register(gCtx global.Context) (<-chan struct{}, error) {
@@ -34,20 +45,43 @@ func (m *Module) Register(gCtx global.Context) (<-chan struct{}, error) {
 
 	closeFns := []func(){}
 
-	pointsCmd := m.PointsCmd()
+	if err := m.migrate(); err != nil {
+		return nil, err
+	}
+
+	if err := m.trackActivity(); err != nil {
+		return nil, err
+	}
 
-	err := multierror.Append(nil, gCtx.Inst().Discord.RegisterCommand("points", pointsCmd))
-	err = multierror.Append(err, gCtx.Inst().Discord.RegisterCommand("boints", pointsCmd))
-	err = multierror.Append(err, gCtx.Inst().Discord.RegisterCommand("bank", pointsCmd))
-	err = multierror.Append(err, gCtx.Inst().Discord.RegisterCommand("add-points", m.AddPointsCmd()))
-	err = multierror.Append(err, gCtx.Inst().Discord.RegisterCommand("set-points", m.SetPointsCmd()))
+	var err *multierror.Error
+	for prefix, cmd := range map[string]command.Cmd{
+		"points":     m.PointsCmd(),
+		"add-points": m.AddPointsCmd(),
+		"set-points": m.SetPointsCmd(),
+		"shop":       m.ShopCmd(),
+	} {
+		closeFn, e := command.Register(gCtx.Inst().Discord, prefix, cmd)
+		closeFns = append(closeFns, closeFn)
+		err = multierror.Append(err, e)
+	}
 	closeFns = append(closeFns, gCtx.Inst().Discord.AddHandler(m.onMessage))
+	closeFns = append(closeFns, gCtx.Inst().Discord.AddHandler(m.onVoiceStateUpdate))
+
+	wg := &sync.WaitGroup{}
+	for _, loop := range []func(){m.expireLoop, m.voiceLoop, m.roleSyncLoop, m.decayLoop} {
+		wg.Add(1)
+		go func(loop func()) {
+			defer wg.Done()
+			loop()
+		}(loop)
+	}
 
 	go func() {
 		<-gCtx.Done()
 		for _, fn := range closeFns {
 			fn()
 		}
+		wg.Wait()
 		close(m.done)
 	}()
 
@@ -58,215 +92,248 @@ func (m *Module) Name() string {
 	return "Points"
 }
 
-func (m *Module) onMessage(s *discordgo.Session, msg *discordgo.MessageCreate) {
-	if msg.GuildID != m.gCtx.Config().Discord.GuildID || msg.Author.Bot {
-		return
-	}
-
-	userID := msg.Author.ID
-	ctx, cancel := context.WithTimeout(m.gCtx, time.Second*5)
-	defer cancel()
+func (m *Module) config(guildID string) configure.PointsModule {
+	return m.gCtx.Inst().Guilds.Config(guildID).Modules.Points
+}
 
-	failurePipe := m.gCtx.Inst().Redis.Pipeline(ctx)
-	runFailurePipe := true
+func (m *Module) enabled(guildID string) bool {
+	return m.config(guildID).Enabled
+}
 
-	defer func() {
-		if runFailurePipe {
-			_, _ = failurePipe.Exec(m.gCtx)
-		}
-	}()
+// migrate moves the points which were stored on users before guilds were supported to the members of the primary guild.
+func (m *Module) migrate() error {
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Minute)
+	defer cancel()
 
-	{
-		pipe := m.gCtx.Inst().Redis.Pipeline(ctx)
-		hourlyKey := fmt.Sprintf("message-limits-hourly:%s", userID)
-		hourlyIncrCmd := pipe.IncrBy(ctx, hourlyKey, int64(m.gCtx.Config().Modules.Points.PointsPerMessage))
-		failurePipe.DecrBy(m.gCtx, hourlyKey, int64(m.gCtx.Config().Modules.Points.PointsPerMessage))
-		hourlyTTLCmd := pipe.TTL(ctx, hourlyKey)
-		_, err := pipe.Exec(ctx)
-		if err != nil {
-			logrus.Error("failed to add to daily limit: ", err)
-			return
-		}
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).Find(ctx, bson.M{
+		"modules.points.points": bson.M{"$exists": true},
+	})
+	if err != nil {
+		return err
+	}
 
-		ttl := hourlyTTLCmd.Val()
-		if ttl == -1 {
-			if err = m.gCtx.Inst().Redis.Expire(ctx, hourlyKey, time.Hour); err != nil {
-				logrus.Error("failed to expire key: ", err)
-				return
-			}
-		}
+	users := []struct {
+		Discord structures.UserDiscord `bson:"discord"`
+		Modules struct {
+			Points structures.MemberModulesPoints `bson:"points"`
+		} `bson:"modules"`
+	}{}
+	if err = cur.All(ctx, &users); err != nil {
+		return err
+	}
 
-		hourlyValue := hourlyIncrCmd.Val()
-		if hourlyValue > int64(m.gCtx.Config().Modules.Points.HourlyLimit) {
-			// we dont have to check further since they exceeded the hourly limit
-			return
-		}
+	if len(users) == 0 {
+		return nil
 	}
 
-	{
-		pipe := m.gCtx.Inst().Redis.Pipeline(ctx)
-		dailyKey := fmt.Sprintf("message-limits-daily:%s", userID)
-		dailyIncrCmd := pipe.IncrBy(ctx, dailyKey, int64(m.gCtx.Config().Modules.Points.PointsPerMessage))
-		failurePipe.DecrBy(m.gCtx, dailyKey, int64(m.gCtx.Config().Modules.Points.PointsPerMessage))
-		dailyTTLCmd := pipe.TTL(ctx, dailyKey)
-		_, err := pipe.Exec(ctx)
+	guildID := m.gCtx.Inst().Guilds.Primary()
+	for _, v := range users {
+		_, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).UpdateOne(ctx, bson.M{
+			"guild_id": guildID,
+			"user_id":  v.Discord.ID,
+		}, bson.M{
+			"$setOnInsert": bson.M{
+				"modules.points.points": v.Modules.Points.Points,
+			},
+		}, options.Update().SetUpsert(true))
 		if err != nil {
-			logrus.Error("failed to add to daily limit: ", err)
-			return
-		}
-
-		ttl := dailyTTLCmd.Val()
-		if ttl == -1 {
-			if err = m.gCtx.Inst().Redis.Expire(ctx, dailyKey, time.Hour*24); err != nil {
-				logrus.Error("failed to expire key: ", err)
-				return
-			}
+			return err
 		}
+	}
 
-		dailyValue := dailyIncrCmd.Val()
-		if dailyValue > int64(m.gCtx.Config().Modules.Points.DailyLimit) {
-			// we dont have to check further since they exceeded the daily limit
-			return
-		}
+	_, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).UpdateMany(ctx, bson.M{
+		"modules.points.points": bson.M{"$exists": true},
+	}, bson.M{
+		"$unset": bson.M{
+			"modules.points": 1,
+		},
+	})
+	if err != nil {
+		return err
 	}
 
-	{
-		pipe := m.gCtx.Inst().Redis.Pipeline(ctx)
-		weeklyKey := fmt.Sprintf("message-limits-weekly:%s", userID)
-		weeklyIncrCmd := pipe.IncrBy(ctx, weeklyKey, int64(m.gCtx.Config().Modules.Points.PointsPerMessage))
-		failurePipe.DecrBy(m.gCtx, weeklyKey, int64(m.gCtx.Config().Modules.Points.PointsPerMessage))
-		weeklyTTLCmd := pipe.TTL(ctx, weeklyKey)
-		_, err := pipe.Exec(ctx)
-		if err != nil {
-			logrus.Error("failed to add to daily limit: ", err)
-			return
-		}
+	logrus.Infof("points, migrated %d users to guild %s", len(users), guildID)
 
-		ttl := weeklyTTLCmd.Val()
-		if ttl == -1 {
-			if err = m.gCtx.Inst().Redis.Expire(ctx, weeklyKey, time.Hour*24*7); err != nil {
-				logrus.Error("failed to expire key: ", err)
-				return
-			}
-		}
+	return nil
+}
 
-		weeklyValue := weeklyIncrCmd.Val()
-		if weeklyValue > int64(m.gCtx.Config().Modules.Points.WeeklyLimit) {
-			// we dont have to check further since they exceeded the weekly limit
-			return
-		}
+func (m *Module) onMessage(s *discordgo.Session, msg *discordgo.MessageCreate) {
+	if msg.GuildID == "" || msg.Author.Bot || !m.enabled(msg.GuildID) {
+		return
 	}
 
-	opts := options.FindOneAndUpdate().SetUpsert(true)
+	userID := msg.Author.ID
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Second*5)
+	defer cancel()
 
-	user := structures.User{}
+	points, err := m.messagePoints(ctx, s, msg)
+	if err != nil {
+		logrus.Error("failed to check message: ", err)
+		return
+	}
+	if points <= 0 {
+		return
+	}
 
-	// at this point we know they can get more points
-	res := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).FindOneAndUpdate(ctx, bson.M{
-		"discord.id": msg.Author.ID,
-	}, bson.M{
-		"$set": bson.M{
-			"discord": structures.UserDiscord{
-				ID:            msg.Author.ID,
-				Name:          msg.Author.Username,
-				Discriminator: msg.Author.Discriminator,
-			},
-		},
-		"$inc": bson.M{
-			"modules.points.points": int32(m.gCtx.Config().Modules.Points.PointsPerMessage),
-		},
-	}, opts)
-	err := res.Err()
-	if err == nil {
-		err = res.Decode(&user)
+	ok, release, err := m.reserve(ctx, msg.GuildID, userID, points)
+	if err != nil {
+		logrus.Error("failed to add to limits: ", err)
+		return
 	}
-	if err != nil && err != mongo.ErrNoDocuments {
-		logrus.Error("failed to update user: ", err)
-	} else {
-		runFailurePipe = false
+	if !ok {
+		// they exceeded one of the limits
+		return
+	}
+
+	// at this point we know they can get more points
+	tx, err := m.addPoints(ctx, structures.PointsTransaction{
+		GuildID: msg.GuildID,
+		UserID:  msg.Author.ID,
+		Source:  structures.PointsSourceMessage,
+		Delta:   int32(points),
+	})
+	if err != nil {
+		logrus.Error("failed to update member: ", err)
+		release()
+		return
 	}
 
 	if msg.Member == nil {
-		msg.Member, err = s.GuildMember(m.gCtx.Config().Discord.GuildID, msg.Author.ID)
+		msg.Member, err = s.GuildMember(msg.GuildID, msg.Author.ID)
 		if err != nil {
 			logrus.Errorf("failed to fetch member (%s#%s - %s): %s", msg.Author.Username, msg.Author.Discriminator, msg.Author.ID, err.Error())
 			return
 		}
 	}
 
-	mp := map[string]bool{}
-	for _, v := range msg.Member.Roles {
-		mp[v] = true
+	m.syncRoles(s, msg.GuildID, msg.Author.ID, msg.Member.Roles, tx.Balance)
+}
+
+// reserve adds points to the hourly, daily and weekly limits of a member, it returns false when one of them is exceeded.
+// the returned func takes the points out of the limits again, it has to be called when the points could not be given.
+func (m *Module) reserve(ctx context.Context, guildID string, userID string, points int) (bool, func(), error) {
+	cfg := m.config(guildID)
+
+	failurePipe := m.gCtx.Inst().Redis.Pipeline(ctx)
+	release := func() {
+		_, _ = failurePipe.Exec(m.gCtx)
 	}
 
-	hasRequiredRole := false
-	for _, role := range m.gCtx.Config().Modules.Points.RequiredRoleIDs {
-		hasRequiredRole = mp[role]
-		if hasRequiredRole {
-			break
+	// the keys are still called message limits since every source of points shares them.
+	for _, limit := range []struct {
+		name  string
+		ttl   time.Duration
+		limit int
+	}{
+		{"hourly", time.Hour, cfg.HourlyLimit},
+		{"daily", time.Hour * 24, cfg.DailyLimit},
+		{"weekly", time.Hour * 24 * 7, cfg.WeeklyLimit},
+	} {
+		pipe := m.gCtx.Inst().Redis.Pipeline(ctx)
+		key := fmt.Sprintf("message-limits-%s:%s:%s", limit.name, guildID, userID)
+		incrCmd := pipe.IncrBy(ctx, key, int64(points))
+		failurePipe.DecrBy(m.gCtx, key, int64(points))
+		ttlCmd := pipe.TTL(ctx, key)
+		if _, err := pipe.Exec(ctx); err != nil {
+			release()
+			return false, nil, err
 		}
-	}
 
-	for _, role := range m.gCtx.Config().Modules.Points.Roles {
-		if hasRequiredRole && role.Points <= int(user.Modules.Points.Points)+10 && !mp[role.ID] {
-			if err := s.GuildMemberRoleAdd(m.gCtx.Config().Discord.GuildID, msg.Author.ID, role.ID); err != nil {
-				logrus.Errorf("cannot add role (%s) to user (%s): %s", role.ID, msg.Author.ID, err.Error())
-			}
-		} else if !hasRequiredRole || (role.Points > int(user.Modules.Points.Points)+10 && mp[role.ID]) {
-			if err := s.GuildMemberRoleRemove(m.gCtx.Config().Discord.GuildID, msg.Author.ID, role.ID); err != nil {
-				logrus.Errorf("cannot remove role (%s) from user (%s): %s", role.ID, msg.Author.ID, err.Error())
+		if ttlCmd.Val() == -1 {
+			if err := m.gCtx.Inst().Redis.Expire(ctx, key, limit.ttl); err != nil {
+				release()
+				return false, nil, err
 			}
 		}
+
+		if incrCmd.Val() > int64(limit.limit) {
+			// we dont have to check further since they exceeded this limit
+			release()
+			return false, nil, nil
+		}
 	}
+
+	return true, release, nil
 }
 
 func (m *Module) PointsCmd() command.Cmd {
-	return &command.Command{
+	balance := m.BalanceCmd()
+
+	return &command.CommandGroup{
 		NameCmd: func() string {
 			return "points"
 		},
 		MatchCmd: func(path []string) bool {
-			return len(path) != 0 && (strings.EqualFold(path[0], "points") || strings.EqualFold(path[0], "boints"))
+			return len(path) != 0 && strings.EqualFold(path[0], "points")
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			guild, err := s.State.Guild(msg.GuildID)
-			if err != nil {
-				return err
-			}
-
-			search := strings.TrimSpace(strings.ToLower(strings.Join(path, " ")))
-			member := utils.FindMember(s, guild, msg.Message, search)
-
-			if member == nil && search == "" {
-				msg.Member.User = msg.Author
-				member = msg.Member
-			}
+		AliasNames:  []string{"boints", "bank"},
+		EnabledCmd:  m.enabled,
+		Info:        "Shows how many points a user has",
+		UsageInfo:   "[user] | <command>",
+		ExampleInfo: []string{"points", "points Troy", "points top", "points give Troy 100"},
+		Commands: map[string]command.Cmd{
+			"balance":     balance,
+			"top":         m.TopCmd(),
+			"weekly":      m.WindowTopCmd("weekly", "week", time.Hour*24*7),
+			"monthly":     m.WindowTopCmd("monthly", "month", time.Hour*24*30),
+			"rank":        m.RankCmd(),
+			"history":     m.HistoryCmd(),
+			"revert":      m.RevertCmd(),
+			"give":        m.GiveCmd(),
+			"sync":        m.SyncCmd("sync", false),
+			"sync-report": m.SyncCmd("sync-report", true),
+			"season":      m.SeasonCmd(),
+			"import":      m.ImportCmd(),
+			"export":      m.ExportCmd(),
+		},
+		DefaultComnmnd: balance,
+	}
+}
 
+func (m *Module) BalanceCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points balance"
+		},
+		MatchCmd: func(path []string) bool {
+			// it is also the default command of points, so everything after points is the user.
+			return true
+		},
+		Info:        "Shows how many points a user has",
+		ExampleInfo: []string{"points balance", "points balance Troy"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to look up, defaults to you",
+				Type:        command.ArgMember,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
 			if member == nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
+				member = ctx.Member
 			}
 
-			user := structures.User{}
-			res := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).FindOne(m.gCtx, bson.M{
-				"discord.id": member.User.ID,
+			result := structures.Member{}
+			res := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).FindOne(m.gCtx, bson.M{
+				"guild_id": ctx.GuildID,
+				"user_id":  member.User.ID,
 			})
-			err = res.Err()
+			err := res.Err()
 			if err == nil {
-				err = res.Decode(&user)
+				err = res.Decode(&result)
 			}
 
 			if err != nil {
 				if err == mongo.ErrNoDocuments {
-					_, err := s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("%s has 0 points.", member.User), msg.Reference())
+					_, err := ctx.Reply(fmt.Sprintf("%s has 0 points.", member.User))
 					return err
 				}
 
 				return err
 			}
 
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("%s has %d points.", member.User, user.Modules.Points.Points), msg.Reference())
+			_, err = ctx.Reply(fmt.Sprintf("%s has %d points.", member.User, result.Modules.Points.Points))
 			return err
 		},
 	}
@@ -280,79 +347,48 @@ func (m *Module) AddPointsCmd() command.Cmd {
 		MatchCmd: func(path []string) bool {
 			return len(path) != 0 && strings.EqualFold(path[0], "add-points")
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			perms, err := s.State.MessagePermissions(msg.Message)
-			if err != nil {
-				return err
-			}
-
-			if perms&discordgo.PermissionAdministrator == 0 {
-				mp := map[string]bool{}
-				for _, v := range msg.Member.Roles {
-					mp[v] = true
-				}
-				found := false
-				for _, v := range m.gCtx.Config().Discord.AdminRoles {
-					if mp[v] {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return nil
-				}
-			}
-
-			if len(path) < 2 {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Invalid usage: `!add-points <user ...> <points>`\nExample: `!add-points Troy 100`", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
-			}
-
-			value, err := strconv.Atoi(path[len(path)-1])
-			if err != nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Invalid usage: `!add-points <user ...> <points>`\nExample: `!add-points Troy 100`", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
+		Info:        "Adds points to a user",
+		ExampleInfo: []string{"add-points Troy 100", "add-points Troy -50"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to give points to",
+				Type:        command.ArgMember,
+				Required:    true,
+			},
+			{
+				Name:        "points",
+				Description: "The amount of points to add, can be negative",
+				Type:        command.ArgInteger,
+				Required:    true,
+			},
+		},
+		EnabledCmd: m.enabled,
+		Perms: command.RolePermission(func(guildID string) []string {
+			return m.config(guildID).ModeratorRoles
+		}),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
+			value := ctx.Args.Int("points")
+
+			if value > math.MaxInt32 || value < math.MinInt32 {
+				return &command.UsageError{Reason: "The points are too large."}
 			}
 
-			path = path[:len(path)-1]
-
-			guild, err := s.State.Guild(msg.GuildID)
+			tx, err := m.addPoints(m.gCtx, structures.PointsTransaction{
+				GuildID: ctx.GuildID,
+				UserID:  member.User.ID,
+				Source:  structures.PointsSourceAdminAdd,
+				ActorID: ctx.Author.ID,
+				Delta:   int32(value),
+			})
 			if err != nil {
 				return err
 			}
 
-			search := strings.TrimSpace(strings.ToLower(strings.Join(path, " ")))
-			member := utils.FindMember(s, guild, msg.Message, search)
-
-			if member == nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
-			}
-
-			opts := options.Update().SetUpsert(true)
-			_, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).UpdateOne(m.gCtx, bson.M{
-				"discord.id": member.User.ID,
-			}, bson.M{
-				"$set": bson.M{
-					"discord": structures.UserDiscord{
-						ID:            member.User.ID,
-						Name:          member.User.Username,
-						Discriminator: member.User.Discriminator,
-					},
-				},
-				"$inc": bson.M{
-					"modules.points.points": int32(value),
-				},
-			}, opts)
-
-			if err != nil {
-				return err
-			}
+			m.syncRoles(ctx.Session, ctx.GuildID, member.User.ID, member.Roles, tx.Balance)
 
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("Added %d points to %s.", value, member.User.Username), msg.Reference())
+			_, err = ctx.Reply(fmt.Sprintf("Added %d points to %s.", value, member.User.Username))
 			return err
 		},
 	}
@@ -366,77 +402,47 @@ func (m *Module) SetPointsCmd() command.Cmd {
 		MatchCmd: func(path []string) bool {
 			return len(path) != 0 && strings.EqualFold(path[0], "set-points")
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			perms, err := s.State.MessagePermissions(msg.Message)
-			if err != nil {
-				return err
-			}
-
-			if perms&discordgo.PermissionAdministrator == 0 {
-				mp := map[string]bool{}
-				for _, v := range msg.Member.Roles {
-					mp[v] = true
-				}
-				found := false
-				for _, v := range m.gCtx.Config().Discord.AdminRoles {
-					if mp[v] {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return nil
-				}
-			}
-
-			if len(path) < 2 {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Invalid usage: `!set-points <user ...> <points>`\nExample: `!set-points Troy 100`", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
-			}
-
-			value, err := strconv.Atoi(path[len(path)-1])
-			if err != nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Invalid usage: `!set-points <user ...> <points>`\nExample: `!set-points Troy 100`", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
+		Info:        "Sets the points of a user",
+		ExampleInfo: []string{"set-points Troy 100"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to set the points of",
+				Type:        command.ArgMember,
+				Required:    true,
+			},
+			{
+				Name:        "points",
+				Description: "The new amount of points",
+				Type:        command.ArgInteger,
+				Required:    true,
+			},
+		},
+		EnabledCmd: m.enabled,
+		Perms: command.RolePermission(func(guildID string) []string {
+			return m.config(guildID).ModeratorRoles
+		}),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
+			value := ctx.Args.Int("points")
+
+			if value < 0 || value > math.MaxInt32 {
+				return &command.UsageError{Reason: "The points have to be a positive number."}
 			}
 
-			path = path[:len(path)-1]
-
-			guild, err := s.State.Guild(msg.GuildID)
+			tx, err := m.setPoints(m.gCtx, structures.PointsTransaction{
+				GuildID: ctx.GuildID,
+				UserID:  member.User.ID,
+				Source:  structures.PointsSourceAdminSet,
+				ActorID: ctx.Author.ID,
+			}, int32(value))
 			if err != nil {
 				return err
 			}
 
-			search := strings.TrimSpace(strings.ToLower(strings.Join(path, " ")))
-			member := utils.FindMember(s, guild, msg.Message, search)
-
-			if member == nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
-			}
-
-			opts := options.Update().SetUpsert(true)
-			_, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameUsers).UpdateOne(m.gCtx, bson.M{
-				"discord.id": member.User.ID,
-			}, bson.M{
-				"$set": bson.M{
-					"discord": structures.UserDiscord{
-						ID:            member.User.ID,
-						Name:          member.User.Username,
-						Discriminator: member.User.Discriminator,
-					},
-					"modules.points.points": int32(value),
-				},
-			}, opts)
-
-			if err != nil {
-				return err
-			}
+			m.syncRoles(ctx.Session, ctx.GuildID, member.User.ID, member.Roles, tx.Balance)
 
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, fmt.Sprintf("Set %s points to %d.", member.User.Username, value), msg.Reference())
+			_, err = ctx.Reply(fmt.Sprintf("Set %s points to %d.", member.User.Username, value))
 			return err
 		},
 	}
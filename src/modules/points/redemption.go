@@ -0,0 +1,193 @@
+package points
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errNotPending = errors.New("redemption is not pending")
+
+// grant gives a member what they bought, the redemption is claimed first so that it is only granted once.
+func (m *Module) grant(ctx context.Context, r *structures.ShopRedemption, actorID string) error {
+	res, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).UpdateOne(ctx, bson.M{
+		"_id":    r.ID,
+		"status": structures.ShopRedemptionStatusPending,
+	}, bson.M{
+		"$set": bson.M{
+			"status":     structures.ShopRedemptionStatusCompleted,
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errNotPending
+	}
+
+	if err = m.apply(r); err != nil {
+		_, _ = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).UpdateOne(ctx, bson.M{
+			"_id": r.ID,
+		}, bson.M{
+			"$set": bson.M{
+				"status": structures.ShopRedemptionStatusPending,
+			},
+		})
+		return err
+	}
+
+	r.Status = structures.ShopRedemptionStatusCompleted
+	if r.Item.Duration > 0 && r.Item.Type != structures.ShopItemTypeRequest {
+		r.Status = structures.ShopRedemptionStatusActive
+		r.ExpiresAt = time.Now().Add(r.Item.Duration)
+	}
+	r.ResolvedBy = actorID
+	r.UpdatedAt = time.Now()
+
+	_, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).UpdateOne(ctx, bson.M{
+		"_id": r.ID,
+	}, bson.M{
+		"$set": bson.M{
+			"status":      r.Status,
+			"expires_at":  r.ExpiresAt,
+			"previous":    r.Previous,
+			"resolved_by": r.ResolvedBy,
+			"updated_at":  r.UpdatedAt,
+		},
+	})
+	return err
+}
+
+// apply makes the changes in discord for a redemption.
+func (m *Module) apply(r *structures.ShopRedemption) error {
+	s := m.gCtx.Inst().Discord.Session()
+
+	switch r.Item.Type {
+	case structures.ShopItemTypeRoleColor:
+		role, err := s.State.Role(r.GuildID, r.Item.RoleID)
+		if err != nil {
+			return err
+		}
+
+		color, _ := strconv.ParseInt(r.Input[1:], 16, 32)
+		if _, err = s.GuildRoleEdit(r.GuildID, role.ID, role.Name, int(color), role.Hoist, role.Permissions, role.Mentionable); err != nil {
+			return err
+		}
+
+		return s.GuildMemberRoleAdd(r.GuildID, r.UserID, r.Item.RoleID)
+	case structures.ShopItemTypeRole:
+		return s.GuildMemberRoleAdd(r.GuildID, r.UserID, r.Item.RoleID)
+	case structures.ShopItemTypeNickname:
+		member, err := m.gCtx.Inst().Discord.Member(r.GuildID, r.UserID)
+		if err != nil {
+			return err
+		}
+
+		r.Previous = member.Nick
+		return s.GuildMemberNickname(r.GuildID, r.UserID, r.Input)
+	}
+
+	return nil
+}
+
+// revoke undoes a grant which expired.
+func (m *Module) revoke(ctx context.Context, r structures.ShopRedemption) error {
+	s := m.gCtx.Inst().Discord.Session()
+
+	switch r.Item.Type {
+	case structures.ShopItemTypeRole, structures.ShopItemTypeRoleColor:
+		// the role is kept when the member has another grant of it which lasts longer.
+		others, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).CountDocuments(ctx, bson.M{
+			"_id":          bson.M{"$ne": r.ID},
+			"guild_id":     r.GuildID,
+			"user_id":      r.UserID,
+			"item.role_id": r.Item.RoleID,
+			"item.type":    bson.M{"$in": bson.A{structures.ShopItemTypeRole, structures.ShopItemTypeRoleColor}},
+			"$or": bson.A{
+				bson.M{"status": structures.ShopRedemptionStatusActive, "expires_at": bson.M{"$gt": r.ExpiresAt}},
+				bson.M{"status": structures.ShopRedemptionStatusCompleted},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if others != 0 {
+			return nil
+		}
+
+		return s.GuildMemberRoleRemove(r.GuildID, r.UserID, r.Item.RoleID)
+	case structures.ShopItemTypeNickname:
+		member, err := m.gCtx.Inst().Discord.Member(r.GuildID, r.UserID)
+		if err != nil {
+			return err
+		}
+
+		// a nickname which was changed since then is not reset.
+		if member.Nick != r.Input {
+			return nil
+		}
+
+		return s.GuildMemberNickname(r.GuildID, r.UserID, r.Previous)
+	}
+
+	return nil
+}
+
+// reject takes a redemption out of the queue and gives the points back.
+func (m *Module) reject(id primitive.ObjectID, guildID string, actorID string, reason string) (structures.ShopRedemption, error) {
+	r := structures.ShopRedemption{}
+	err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).FindOneAndUpdate(m.gCtx, bson.M{
+		"_id":      id,
+		"guild_id": guildID,
+		"status":   structures.ShopRedemptionStatusPending,
+	}, bson.M{
+		"$set": bson.M{
+			"status":      structures.ShopRedemptionStatusRejected,
+			"resolved_by": actorID,
+			"reason":      reason,
+			"updated_at":  time.Now(),
+		},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&r)
+	if err == mongo.ErrNoDocuments {
+		return r, errNotPending
+	}
+	if err != nil {
+		return r, err
+	}
+
+	_, err = m.refund(r, actorID)
+	return r, err
+}
+
+// refund gives the points of a redemption back and puts the item back in stock.
+func (m *Module) refund(r structures.ShopRedemption, actorID string) (structures.PointsTransaction, error) {
+	m.restock(r.Item.ID)
+
+	return m.addPoints(m.gCtx, structures.PointsTransaction{
+		GuildID: r.GuildID,
+		UserID:  r.UserID,
+		Source:  structures.PointsSourceShopRefund,
+		ActorID: actorID,
+		Delta:   r.Item.Price,
+	})
+}
+
+// restock adds one to the stock of an item unless its stock is unlimited.
+func (m *Module) restock(itemID primitive.ObjectID) {
+	_, _ = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopItems).UpdateOne(m.gCtx, bson.M{
+		"_id":   itemID,
+		"stock": bson.M{"$gte": 0},
+	}, bson.M{
+		"$inc": bson.M{
+			"stock": 1,
+		},
+	})
+}
@@ -0,0 +1,320 @@
+package points
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/configure"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"github.com/hashicorp/go-multierror"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	roleSyncInterval = time.Hour * 6
+	// roleSyncDelay is waited between role changes of a full sync, so that messages are not slowed down by the rate limits.
+	roleSyncDelay     = time.Millisecond * 250
+	roleSyncPageSize  = 1000
+	roleSyncMaxReport = 4000
+)
+
+// roleChange are the points roles a member is missing and the ones they should not have.
+type roleChange struct {
+	UserID string
+	Add    []string
+	Remove []string
+}
+
+// roleDiff compares the roles of a member to the points roles they should have.
+// members without one of the required roles should have none of them.
+func roleDiff(cfg configure.PointsModule, userID string, roles []string, points int32) roleChange {
+	mp := map[string]bool{}
+	for _, v := range roles {
+		mp[v] = true
+	}
+
+	hasRequiredRole := false
+	for _, role := range cfg.RequiredRoleIDs {
+		hasRequiredRole = mp[role]
+		if hasRequiredRole {
+			break
+		}
+	}
+
+	change := roleChange{UserID: userID}
+	for _, role := range cfg.Roles {
+		should := hasRequiredRole && role.Points <= int(points)+10
+		if should && !mp[role.ID] {
+			change.Add = append(change.Add, role.ID)
+		} else if !should && mp[role.ID] {
+			change.Remove = append(change.Remove, role.ID)
+		}
+	}
+
+	return change
+}
+
+func (c roleChange) empty() bool {
+	return len(c.Add) == 0 && len(c.Remove) == 0
+}
+
+// apply makes a role change in discord, delay is waited before every request.
+func (c roleChange) apply(ctx context.Context, s *discordgo.Session, guildID string, delay time.Duration) error {
+	var err *multierror.Error
+	for _, ids := range []struct {
+		ids []string
+		fn  func(guildID string, userID string, roleID string) error
+	}{{c.Add, s.GuildMemberRoleAdd}, {c.Remove, s.GuildMemberRoleRemove}} {
+		for _, id := range ids.ids {
+			if delay > 0 {
+				select {
+				case <-ctx.Done():
+					return multierror.Append(err, ctx.Err())
+				case <-time.After(delay):
+				}
+			}
+
+			if e := ids.fn(guildID, c.UserID, id); e != nil {
+				err = multierror.Append(err, fmt.Errorf("role %s of user %s: %w", id, c.UserID, e))
+			}
+		}
+	}
+
+	return err.ErrorOrNil()
+}
+
+// syncRoles gives a member the points roles they reached and removes the ones they fell below.
+func (m *Module) syncRoles(s *discordgo.Session, guildID string, userID string, roles []string, points int32) {
+	if err := roleDiff(m.config(guildID), userID, roles, points).apply(m.gCtx, s, guildID, 0); err != nil {
+		logrus.Error("failed to sync points roles: ", err)
+	}
+}
+
+// syncMember syncs the points roles of a member with their points in mongo.
+func (m *Module) syncMember(ctx context.Context, guildID string, userID string, dryRun bool) (roleChange, error) {
+	member, err := m.gCtx.Inst().Discord.Member(guildID, userID)
+	if err != nil {
+		return roleChange{}, err
+	}
+
+	result := structures.Member{}
+	err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).FindOne(ctx, bson.M{
+		"guild_id": guildID,
+		"user_id":  userID,
+	}).Decode(&result)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return roleChange{}, err
+	}
+
+	change := roleDiff(m.config(guildID), userID, member.Roles, result.Modules.Points.Points)
+	if dryRun {
+		return change, nil
+	}
+
+	return change, change.apply(ctx, m.gCtx.Inst().Discord.Session(), guildID, 0)
+}
+
+// syncGuild walks every member of a guild and syncs their points roles, only one guild is synced at a time.
+func (m *Module) syncGuild(ctx context.Context, guildID string, dryRun bool) ([]roleChange, error) {
+	if !m.roleSyncMtx.TryLock() {
+		return nil, errSyncRunning
+	}
+	defer m.roleSyncMtx.Unlock()
+
+	cfg := m.config(guildID)
+	s := m.gCtx.Inst().Discord.Session()
+
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).Find(ctx, bson.M{
+		"guild_id":              guildID,
+		"modules.points.points": bson.M{"$gt": 0},
+	}, options.Find().SetProjection(bson.M{"user_id": 1, "modules.points.points": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	members := []structures.Member{}
+	if err = cur.All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	points := map[string]int32{}
+	for _, v := range members {
+		points[v.UserID] = v.Modules.Points.Points
+	}
+
+	changes := []roleChange{}
+	var errs *multierror.Error
+	after := ""
+	for {
+		page, err := s.GuildMembers(guildID, after, roleSyncPageSize)
+		if err != nil {
+			return changes, multierror.Append(errs, err).ErrorOrNil()
+		}
+
+		for _, v := range page {
+			if v.User.Bot {
+				continue
+			}
+
+			change := roleDiff(cfg, v.User.ID, v.Roles, points[v.User.ID])
+			if change.empty() {
+				continue
+			}
+
+			changes = append(changes, change)
+			if !dryRun {
+				if err := change.apply(ctx, s, guildID, roleSyncDelay); err != nil {
+					errs = multierror.Append(errs, err)
+				}
+			}
+		}
+
+		if len(page) < roleSyncPageSize || ctx.Err() != nil {
+			break
+		}
+		after = page[len(page)-1].User.ID
+	}
+
+	return changes, errs.ErrorOrNil()
+}
+
+// roleSyncLoop syncs the points roles of every guild, so that changes to the config or the points are applied
+// to members who do not send messages.
+func (m *Module) roleSyncLoop() {
+	tick := time.NewTicker(roleSyncInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-m.gCtx.Done():
+			return
+		case <-tick.C:
+		}
+
+		s := m.gCtx.Inst().Discord.Session()
+
+		s.State.RLock()
+		guildIDs := make([]string, len(s.State.Guilds))
+		for i, g := range s.State.Guilds {
+			guildIDs[i] = g.ID
+		}
+		s.State.RUnlock()
+
+		for _, guildID := range guildIDs {
+			if cfg := m.config(guildID); !cfg.Enabled || len(cfg.Roles) == 0 {
+				continue
+			}
+
+			changes, err := m.syncGuild(m.gCtx, guildID, false)
+			if err != nil {
+				logrus.WithField("guild_id", guildID).Error("failed to sync points roles: ", err)
+			}
+			if len(changes) != 0 {
+				logrus.WithField("guild_id", guildID).Infof("points, synced the roles of %d members", len(changes))
+			}
+		}
+	}
+}
+
+func (m *Module) SyncCmd(name string, dryRun bool) command.Cmd {
+	info := "Gives every member the points roles they should have, or only one member"
+	if dryRun {
+		info = "Shows which points roles a sync would change without changing them"
+	}
+
+	return &command.Command{
+		NameCmd: func() string {
+			return "points " + name
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], name)
+		},
+		Info:        info,
+		ExampleInfo: []string{"points " + name, fmt.Sprintf("points %s Troy", name)},
+		Perms:       command.AdminPermission,
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The member to sync, defaults to every member",
+				Type:        command.ArgMember,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			if err := ctx.Defer(); err != nil {
+				return err
+			}
+
+			var (
+				changes []roleChange
+				err     error
+			)
+			if member := ctx.Args.Member("user"); member != nil {
+				var change roleChange
+				change, err = m.syncMember(m.gCtx, ctx.GuildID, member.User.ID, dryRun)
+				if !change.empty() {
+					changes = append(changes, change)
+				}
+			} else {
+				changes, err = m.syncGuild(m.gCtx, ctx.GuildID, dryRun)
+			}
+			if err == errSyncRunning {
+				return ctx.ReplyError("A sync is already running, try again later.")
+			}
+			if err != nil {
+				logrus.WithField("guild_id", ctx.GuildID).Error("failed to sync points roles: ", err)
+			}
+
+			title := fmt.Sprintf("Synced the points roles of %d members", len(changes))
+			if dryRun {
+				title = fmt.Sprintf("A sync would change the points roles of %d members", len(changes))
+			}
+			if err != nil {
+				title += ", some changes failed"
+			}
+
+			lines := make([]string, 0, len(changes))
+			for _, c := range changes {
+				line := fmt.Sprintf("<@%s>", c.UserID)
+				for _, id := range c.Add {
+					line += fmt.Sprintf(" +<@&%s>", id)
+				}
+				for _, id := range c.Remove {
+					line += fmt.Sprintf(" -<@&%s>", id)
+				}
+
+				lines = append(lines, line)
+			}
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:       title,
+					Description: reportLines(lines, roleSyncMaxReport),
+					Color:       ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID),
+				},
+			})
+			return err
+		},
+	}
+}
+
+// reportLines joins as many lines as fit into max characters and says how many were left out.
+func reportLines(lines []string, max int) string {
+	buf := strings.Builder{}
+	for i, v := range lines {
+		if buf.Len()+len(v)+1 > max-32 {
+			buf.WriteString(fmt.Sprintf("and %d more", len(lines)-i))
+			break
+		}
+
+		buf.WriteString(v + "\n")
+	}
+
+	return strings.TrimSpace(buf.String())
+}
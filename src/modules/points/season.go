@@ -0,0 +1,350 @@
+package points
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"github.com/hashicorp/go-multierror"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	seasonConfirmTimeout = time.Second * 30
+	seasonCloseLockTTL   = time.Minute * 10
+	seasonListLimit      = 20
+	standingsBatchSize   = 1000
+)
+
+func (m *Module) SeasonCmd() command.Cmd {
+	list := m.SeasonListCmd()
+
+	return &command.CommandGroup{
+		NameCmd: func() string {
+			return "points season"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "season")
+		},
+		Info:        "Shows past seasons and closes the current one",
+		UsageInfo:   "[command]",
+		ExampleInfo: []string{"points season", "points season show 1", "points season close"},
+		Commands: map[string]command.Cmd{
+			"list":  list,
+			"show":  m.SeasonShowCmd(),
+			"close": m.SeasonCloseCmd(),
+		},
+		DefaultComnmnd: list,
+	}
+}
+
+func (m *Module) SeasonListCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points season list"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) == 0 || strings.EqualFold(path[0], "list")
+		},
+		Info:        "Shows the past seasons",
+		ExampleInfo: []string{"points season list"},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsSeasons).Find(m.gCtx, bson.M{
+				"guild_id": ctx.GuildID,
+			}, options.Find().SetSort(bson.D{{Key: "number", Value: -1}}).SetLimit(seasonListLimit))
+			if err != nil {
+				return err
+			}
+
+			seasons := []structures.PointsSeason{}
+			if err = cur.All(m.gCtx, &seasons); err != nil {
+				return err
+			}
+
+			if len(seasons) == 0 {
+				return ctx.ReplyError("No season was closed yet.")
+			}
+
+			lines := make([]string, len(seasons))
+			for i, v := range seasons {
+				lines[i] = fmt.Sprintf("**Season %d** ended <t:%d:D>, %d members", v.Number, v.EndedAt.Unix(), v.Members)
+				if len(v.Champions) != 0 {
+					lines[i] += ", champions " + mentions(v.Champions)
+				}
+			}
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:       "Seasons",
+					Description: strings.Join(lines, "\n"),
+					Color:       ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID),
+					Footer: &discordgo.MessageEmbedFooter{
+						Text: fmt.Sprintf("Use %spoints season show <season> to see its leaderboard", ctx.Prefix),
+					},
+				},
+			})
+			return err
+		},
+	}
+}
+
+func (m *Module) SeasonShowCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points season show"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "show")
+		},
+		Info:        "Shows the leaderboard at the end of a season",
+		ExampleInfo: []string{"points season show", "points season show 1 2"},
+		Args: []command.Arg{
+			{
+				Name:        "season",
+				Description: "The number of the season, defaults to the last one",
+				Type:        command.ArgInteger,
+			},
+			pageArg,
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			page, err := leaderboardPage(ctx)
+			if err != nil {
+				return err
+			}
+
+			filter := bson.M{"guild_id": ctx.GuildID}
+			if ctx.Args.Has("season") {
+				filter["number"] = ctx.Args.Int("season")
+			}
+
+			season := structures.PointsSeason{}
+			err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsSeasons).FindOne(m.gCtx, filter, options.FindOne().
+				SetSort(bson.D{{Key: "number", Value: -1}}),
+			).Decode(&season)
+			if err == mongo.ErrNoDocuments {
+				return ctx.ReplyError("There is no such season.")
+			}
+			if err != nil {
+				return err
+			}
+
+			cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameSeasonStandings).Find(m.gCtx, bson.M{
+				"season_id": season.ID,
+			}, options.Find().
+				SetSort(bson.D{{Key: "rank", Value: 1}}).
+				SetSkip((page-1)*leaderboardPageSize).
+				SetLimit(leaderboardPageSize),
+			)
+			if err != nil {
+				return err
+			}
+
+			standings := []structures.PointsSeasonStanding{}
+			if err = cur.All(m.gCtx, &standings); err != nil {
+				return err
+			}
+
+			entries := make([]leaderboardEntry, len(standings))
+			for i, v := range standings {
+				entries[i] = leaderboardEntry{UserID: v.UserID, Points: int64(v.Points)}
+			}
+
+			return replyLeaderboard(ctx, fmt.Sprintf("Leaderboard of season %d", season.Number), entries, page, season.Members)
+		},
+	}
+}
+
+func (m *Module) SeasonCloseCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points season close"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "close")
+		},
+		Info:        "Ends the season, the points are archived and reset or partly carried over to the next one",
+		ExampleInfo: []string{"points season close"},
+		Perms:       command.AdminPermission,
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			cfg := m.config(ctx.GuildID)
+
+			reset := "reset"
+			if cfg.SeasonCarryOver != 0 {
+				reset = fmt.Sprintf("reduced to %d%%", cfg.SeasonCarryOver)
+			}
+
+			confirmed, err := ctx.Confirm(fmt.Sprintf("Close the season? The points of every member are archived and %s.", reset), seasonConfirmTimeout)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				_, err = ctx.Send("The season was not closed.")
+				return err
+			}
+
+			lockKey := fmt.Sprintf("points-season-close:%s", ctx.GuildID)
+			lockToken := primitive.NewObjectID().Hex()
+			ok, err := m.gCtx.Inst().Redis.SetNX(m.gCtx, lockKey, lockToken, seasonCloseLockTTL)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ctx.ReplyError("The season is already being closed.")
+			}
+			defer func() {
+				if _, err := m.gCtx.Inst().Redis.DelIfEquals(m.gCtx, lockKey, lockToken); err != nil {
+					logrus.Error("failed to release season lock: ", err)
+				}
+			}()
+
+			season, err := m.closeSeason(m.gCtx, ctx.GuildID, ctx.Author.ID)
+			if err != nil && season.ID.IsZero() {
+				return err
+			}
+			if err != nil {
+				logrus.WithField("guild_id", ctx.GuildID).Error("failed to close season: ", err)
+			}
+
+			// the points roles are synced with the new points right away.
+			go func() {
+				if _, err := m.syncGuild(m.gCtx, ctx.GuildID, false); err != nil {
+					logrus.WithField("guild_id", ctx.GuildID).Error("failed to sync points roles: ", err)
+				}
+			}()
+
+			msg := fmt.Sprintf("Closed season %d with %d members.", season.Number, season.Members)
+			if len(season.Champions) != 0 {
+				msg += " Congratulations to the champions " + mentions(season.Champions) + "!"
+			}
+			if err != nil {
+				msg += " Some changes failed, check the logs."
+			}
+
+			_, err = ctx.Send(msg)
+			return err
+		},
+	}
+}
+
+// closeSeason archives the points of every member of a guild, gives the champion role to the best members and resets the points.
+func (m *Module) closeSeason(ctx context.Context, guildID string, actorID string) (structures.PointsSeason, error) {
+	cfg := m.config(guildID)
+
+	prev := structures.PointsSeason{}
+	err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsSeasons).FindOne(ctx, bson.M{
+		"guild_id": guildID,
+	}, options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}})).Decode(&prev)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return structures.PointsSeason{}, err
+	}
+
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameMembers).Find(ctx, bson.M{
+		"guild_id":              guildID,
+		"modules.points.points": bson.M{"$ne": 0},
+	}, options.Find().SetSort(bson.D{{Key: "modules.points.points", Value: -1}, {Key: "user_id", Value: 1}}))
+	if err != nil {
+		return structures.PointsSeason{}, err
+	}
+
+	members := []structures.Member{}
+	if err = cur.All(ctx, &members); err != nil {
+		return structures.PointsSeason{}, err
+	}
+
+	season := structures.PointsSeason{
+		ID:        primitive.NewObjectID(),
+		GuildID:   guildID,
+		Number:    prev.Number + 1,
+		StartedAt: prev.EndedAt,
+		EndedAt:   time.Now(),
+		ClosedBy:  actorID,
+		Members:   int64(len(members)),
+		Champions: []string{},
+		CarryOver: cfg.SeasonCarryOver,
+	}
+	if cfg.SeasonChampionRoleID != "" {
+		for _, v := range members {
+			if len(season.Champions) >= cfg.SeasonChampions || v.Modules.Points.Points <= 0 {
+				break
+			}
+
+			season.Champions = append(season.Champions, v.UserID)
+		}
+	}
+
+	for i := 0; i < len(members); i += standingsBatchSize {
+		batch := []interface{}{}
+		for j := i; j < len(members) && j < i+standingsBatchSize; j++ {
+			batch = append(batch, structures.PointsSeasonStanding{
+				SeasonID: season.ID,
+				GuildID:  guildID,
+				UserID:   members[j].UserID,
+				Rank:     int64(j + 1),
+				Points:   members[j].Modules.Points.Points,
+			})
+		}
+
+		if _, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameSeasonStandings).InsertMany(ctx, batch); err != nil {
+			return structures.PointsSeason{}, err
+		}
+	}
+
+	if _, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNamePointsSeasons).InsertOne(ctx, season); err != nil {
+		return structures.PointsSeason{}, err
+	}
+
+	// once the season is archived the remaining steps are done even when some of them fail.
+	var errs *multierror.Error
+	if cfg.SeasonChampionRoleID != "" {
+		s := m.gCtx.Inst().Discord.Session()
+		champions := map[string]bool{}
+		for _, v := range season.Champions {
+			champions[v] = true
+		}
+
+		for _, v := range prev.Champions {
+			if !champions[v] {
+				errs = multierror.Append(errs, s.GuildMemberRoleRemove(guildID, v, cfg.SeasonChampionRoleID))
+			}
+		}
+		for _, v := range season.Champions {
+			errs = multierror.Append(errs, s.GuildMemberRoleAdd(guildID, v, cfg.SeasonChampionRoleID))
+		}
+	}
+
+	for _, v := range members {
+		points := int64(v.Modules.Points.Points)
+		delta := int32(points*int64(cfg.SeasonCarryOver)/100 - points)
+		if delta == 0 {
+			continue
+		}
+
+		_, err = m.addPoints(ctx, structures.PointsTransaction{
+			GuildID: guildID,
+			UserID:  v.UserID,
+			Source:  structures.PointsSourceSeason,
+			ActorID: actorID,
+			Delta:   delta,
+		})
+		errs = multierror.Append(errs, err)
+	}
+
+	return season, errs.ErrorOrNil()
+}
+
+func mentions(userIDs []string) string {
+	items := make([]string, len(userIDs))
+	for i, v := range userIDs {
+		items[i] = fmt.Sprintf("<@%s>", v)
+	}
+
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,816 @@
+package points
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	shopExpireInterval = time.Minute
+	shopQueuePageSize  = 10
+	shopBuyLockTTL     = time.Second * 30
+	maxRequestLength   = 500
+)
+
+var (
+	shopNameRegex  = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+	shopColorRegex = regexp.MustCompile(`^#?([0-9a-fA-F]{6})$`)
+	roleIDRegex    = regexp.MustCompile(`^(?:<@&)?(\d+)>?$`)
+
+	shopItemTypes = []structures.ShopItemType{
+		structures.ShopItemTypeRole,
+		structures.ShopItemTypeRoleColor,
+		structures.ShopItemTypeNickname,
+		structures.ShopItemTypeRequest,
+	}
+)
+
+func (m *Module) ShopCmd() command.Cmd {
+	list := m.ShopListCmd()
+
+	return &command.CommandGroup{
+		NameCmd: func() string {
+			return "shop"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "shop")
+		},
+		EnabledCmd:  m.enabled,
+		Info:        "Spend your points on rewards",
+		UsageInfo:   "[command]",
+		ExampleInfo: []string{"shop", "shop buy vip", "shop buy color #e91e63"},
+		Commands: map[string]command.Cmd{
+			"list":    list,
+			"buy":     m.ShopBuyCmd(),
+			"queue":   m.ShopQueueCmd(),
+			"approve": m.ShopApproveCmd(),
+			"reject":  m.ShopRejectCmd(),
+			"add":     m.ShopAddCmd(),
+			"set":     m.ShopSetCmd(),
+			"remove":  m.ShopRemoveCmd(),
+		},
+		DefaultComnmnd: list,
+	}
+}
+
+func (m *Module) moderatorPermission() command.Permission {
+	return command.RolePermission(func(guildID string) []string {
+		return m.config(guildID).ModeratorRoles
+	})
+}
+
+func (m *Module) ShopListCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "shop list"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) == 0 || strings.EqualFold(path[0], "list")
+		},
+		Info:        "Shows the items which can be bought",
+		ExampleInfo: []string{"shop list"},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopItems).Find(m.gCtx, bson.M{
+				"guild_id": ctx.GuildID,
+			}, options.Find().SetSort(bson.D{{Key: "price", Value: 1}, {Key: "name", Value: 1}}))
+			if err != nil {
+				return err
+			}
+
+			items := []structures.ShopItem{}
+			if err = cur.All(m.gCtx, &items); err != nil {
+				return err
+			}
+
+			if len(items) == 0 {
+				return ctx.ReplyError("The shop is empty.")
+			}
+
+			fields := make([]*discordgo.MessageEmbedField, len(items))
+			for i, v := range items {
+				fields[i] = &discordgo.MessageEmbedField{
+					Name:  fmt.Sprintf("%s — %d points", v.Name, v.Price),
+					Value: formatItem(v),
+				}
+			}
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:  "Shop",
+					Color:  ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID),
+					Fields: fields,
+					Footer: &discordgo.MessageEmbedFooter{
+						Text: fmt.Sprintf("Use %sshop buy <item> to buy an item", ctx.Prefix),
+					},
+				},
+			})
+			return err
+		},
+	}
+}
+
+func (m *Module) ShopBuyCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "shop buy"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "buy")
+		},
+		Info:        "Buy an item, colors, nicknames and requests are written after the item",
+		ExampleInfo: []string{"shop buy vip", "shop buy color #e91e63", "shop buy nickname Troy the Great"},
+		Args: []command.Arg{
+			{
+				Name:        "item",
+				Description: "The name of the item",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+			{
+				Name:        "input",
+				Description: "The color, nickname or request the item is bought for",
+				Type:        command.ArgText,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			item, err := m.shopItem(ctx.GuildID, ctx.Args.String("item"))
+			if err == mongo.ErrNoDocuments {
+				return ctx.ReplyError(fmt.Sprintf("There is no item called `%s`, use `%sshop` to see the items.", ctx.Args.String("item"), ctx.Prefix))
+			}
+			if err != nil {
+				return err
+			}
+
+			input, err := shopInput(item, ctx.Args.String("input"))
+			if err != nil {
+				return err
+			}
+
+			if item.RoleID == "" && (item.Type == structures.ShopItemTypeRole || item.Type == structures.ShopItemTypeRoleColor) {
+				return ctx.ReplyError(fmt.Sprintf("`%s` has no role yet.", item.Name))
+			}
+
+			// a member can only buy one item at a time so that the purchase limit can not be bypassed by buying in parallel.
+			lockKey := fmt.Sprintf("shop-buy:%s:%s", ctx.GuildID, ctx.Author.ID)
+			lockToken := primitive.NewObjectID().Hex()
+			ok, err := m.gCtx.Inst().Redis.SetNX(m.gCtx, lockKey, lockToken, shopBuyLockTTL)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ctx.ReplyError("You are already buying something.")
+			}
+			defer func() {
+				if _, err := m.gCtx.Inst().Redis.DelIfEquals(m.gCtx, lockKey, lockToken); err != nil {
+					logrus.Error("failed to release shop lock: ", err)
+				}
+			}()
+
+			if item.UserLimit > 0 {
+				bought, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).CountDocuments(m.gCtx, bson.M{
+					"guild_id": ctx.GuildID,
+					"user_id":  ctx.Author.ID,
+					"item._id": item.ID,
+					"status":   bson.M{"$ne": structures.ShopRedemptionStatusRejected},
+				})
+				if err != nil {
+					return err
+				}
+
+				if bought >= int64(item.UserLimit) {
+					return ctx.ReplyError(fmt.Sprintf("You can only buy `%s` %d times.", item.Name, item.UserLimit))
+				}
+			}
+
+			if item.Stock >= 0 {
+				res, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopItems).UpdateOne(m.gCtx, bson.M{
+					"_id":   item.ID,
+					"stock": bson.M{"$gt": 0},
+				}, bson.M{
+					"$inc": bson.M{
+						"stock": -1,
+					},
+				})
+				if err != nil {
+					return err
+				}
+				if res.MatchedCount == 0 {
+					return ctx.ReplyError(fmt.Sprintf("`%s` is sold out.", item.Name))
+				}
+			}
+
+			tx, err := m.takePoints(m.gCtx, structures.PointsTransaction{
+				GuildID: ctx.GuildID,
+				UserID:  ctx.Author.ID,
+				Source:  structures.PointsSourceShop,
+				Delta:   -item.Price,
+			})
+			if err != nil {
+				m.restock(item.ID)
+				if err == ErrInsufficientPoints {
+					return ctx.ReplyError(fmt.Sprintf("You need %d points to buy `%s`.", item.Price, item.Name))
+				}
+
+				return err
+			}
+
+			now := time.Now()
+			r := structures.ShopRedemption{
+				ID:        primitive.NewObjectID(),
+				GuildID:   ctx.GuildID,
+				UserID:    ctx.Author.ID,
+				Item:      item,
+				Status:    structures.ShopRedemptionStatusPending,
+				Input:     input,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if _, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).InsertOne(m.gCtx, r); err != nil {
+				_, _ = m.refund(r, "")
+				return err
+			}
+
+			if item.Type == structures.ShopItemTypeNickname || item.Type == structures.ShopItemTypeRequest {
+				_, err = ctx.Reply(fmt.Sprintf("Bought `%s` for %d points, you have %d points left. A moderator will look at it soon.", item.Name, item.Price, tx.Balance))
+				return err
+			}
+
+			if err = m.grant(m.gCtx, &r, ""); err != nil {
+				_, _ = m.reject(r.ID, ctx.GuildID, "", "the item could not be given")
+				return err
+			}
+
+			_, err = ctx.Reply(fmt.Sprintf("Bought `%s` for %d points, you have %d points left.%s", item.Name, item.Price, tx.Balance, formatExpiry(r)))
+			return err
+		},
+	}
+}
+
+func (m *Module) ShopQueueCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "shop queue"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "queue")
+		},
+		Info:        "Shows the purchases which wait for a moderator, oldest first",
+		ExampleInfo: []string{"shop queue", "shop queue 2"},
+		Perms:       m.moderatorPermission(),
+		Args: []command.Arg{
+			{
+				Name:        "page",
+				Description: "The page of the queue, defaults to the first one",
+				Type:        command.ArgInteger,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			page, err := leaderboardPage(ctx)
+			if err != nil {
+				return err
+			}
+
+			filter := bson.M{
+				"guild_id": ctx.GuildID,
+				"status":   structures.ShopRedemptionStatusPending,
+			}
+
+			total, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).CountDocuments(m.gCtx, filter)
+			if err != nil {
+				return err
+			}
+
+			cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).Find(m.gCtx, filter, options.Find().
+				SetSort(bson.D{{Key: "created_at", Value: 1}}).
+				SetSkip((page-1)*shopQueuePageSize).
+				SetLimit(shopQueuePageSize),
+			)
+			if err != nil {
+				return err
+			}
+
+			redemptions := []structures.ShopRedemption{}
+			if err = cur.All(m.gCtx, &redemptions); err != nil {
+				return err
+			}
+
+			pages := (total + shopQueuePageSize - 1) / shopQueuePageSize
+			if len(redemptions) == 0 {
+				if pages == 0 {
+					return ctx.ReplyError("Nothing is waiting for a moderator.")
+				}
+
+				return ctx.ReplyError(fmt.Sprintf("There are only %d pages.", pages))
+			}
+
+			lines := make([]string, len(redemptions))
+			for i, v := range redemptions {
+				lines[i] = fmt.Sprintf("`%s` <t:%d:R> <@%s> **%s**: %s", v.ID.Hex(), v.CreatedAt.Unix(), v.UserID, v.Item.Name, v.Input)
+			}
+
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:       "Shop queue",
+					Description: strings.Join(lines, "\n"),
+					Color:       ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID),
+					Footer: &discordgo.MessageEmbedFooter{
+						Text: fmt.Sprintf("Page %d of %d, use %sshop approve <id> or %sshop reject <id>", page, pages, ctx.Prefix, ctx.Prefix),
+					},
+				},
+			})
+			return err
+		},
+	}
+}
+
+var redemptionArg = command.Arg{
+	Name:        "redemption",
+	Description: "The id of the purchase, it is shown in the queue",
+	Type:        command.ArgString,
+	Required:    true,
+}
+
+func (m *Module) ShopApproveCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "shop approve"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "approve")
+		},
+		Info:        "Approve a purchase from the queue, nicknames are changed right away",
+		ExampleInfo: []string{"shop approve 62a1f0c2e4b0a1b2c3d4e5f6"},
+		Perms:       m.moderatorPermission(),
+		Args:        []command.Arg{redemptionArg},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			id, err := primitive.ObjectIDFromHex(ctx.Args.String("redemption"))
+			if err != nil {
+				return &command.UsageError{Reason: "The id of the purchase is not valid."}
+			}
+
+			r := structures.ShopRedemption{}
+			err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).FindOne(m.gCtx, bson.M{
+				"_id":      id,
+				"guild_id": ctx.GuildID,
+				"status":   structures.ShopRedemptionStatusPending,
+			}).Decode(&r)
+			if err == mongo.ErrNoDocuments {
+				return ctx.ReplyError("There is no purchase with this id in the queue.")
+			}
+			if err != nil {
+				return err
+			}
+
+			if err = m.grant(m.gCtx, &r, ctx.Author.ID); err != nil {
+				if err == errNotPending {
+					return ctx.ReplyError("This purchase was already handled.")
+				}
+
+				return err
+			}
+
+			_, err = ctx.Reply(fmt.Sprintf("Approved `%s` for <@%s>.%s", r.Item.Name, r.UserID, formatExpiry(r)))
+			return err
+		},
+	}
+}
+
+func (m *Module) ShopRejectCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "shop reject"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "reject")
+		},
+		Info:        "Reject a purchase from the queue, the points are given back",
+		ExampleInfo: []string{"shop reject 62a1f0c2e4b0a1b2c3d4e5f6 not a nice nickname"},
+		Perms:       m.moderatorPermission(),
+		Args: []command.Arg{
+			redemptionArg,
+			{
+				Name:        "reason",
+				Description: "Why the purchase was rejected",
+				Type:        command.ArgText,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			id, err := primitive.ObjectIDFromHex(ctx.Args.String("redemption"))
+			if err != nil {
+				return &command.UsageError{Reason: "The id of the purchase is not valid."}
+			}
+
+			r, err := m.reject(id, ctx.GuildID, ctx.Author.ID, ctx.Args.String("reason"))
+			if err == errNotPending {
+				return ctx.ReplyError("There is no purchase with this id in the queue.")
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = ctx.Reply(fmt.Sprintf("Rejected `%s` for <@%s>, they got their %d points back.", r.Item.Name, r.UserID, r.Item.Price))
+			return err
+		},
+	}
+}
+
+func (m *Module) ShopAddCmd() command.Cmd {
+	types := make([]string, len(shopItemTypes))
+	for i, v := range shopItemTypes {
+		types[i] = string(v)
+	}
+
+	return &command.Command{
+		NameCmd: func() string {
+			return "shop add"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "add")
+		},
+		Info:        fmt.Sprintf("Add an item to the shop, the types are %s", strings.Join(types, ", ")),
+		ExampleInfo: []string{"shop add vip role 1000 The VIP role for a week", "shop add nickname nickname 500"},
+		Perms:       m.moderatorPermission(),
+		Args: []command.Arg{
+			{
+				Name:        "name",
+				Description: "The name of the item, a single word",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+			{
+				Name:        "type",
+				Description: "What buying the item does",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+			{
+				Name:        "price",
+				Description: "How many points the item costs",
+				Type:        command.ArgInteger,
+				Required:    true,
+			},
+			{
+				Name:        "description",
+				Description: "What the item is",
+				Type:        command.ArgText,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			name := strings.ToLower(ctx.Args.String("name"))
+			if !shopNameRegex.MatchString(name) {
+				return &command.UsageError{Reason: "The name can only contain letters, numbers, - and _."}
+			}
+
+			itemType := structures.ShopItemType(strings.ToLower(ctx.Args.String("type")))
+			valid := false
+			for _, v := range shopItemTypes {
+				valid = valid || v == itemType
+			}
+			if !valid {
+				return &command.UsageError{Reason: fmt.Sprintf("The type has to be one of %s.", strings.Join(types, ", "))}
+			}
+
+			price := ctx.Args.Int("price")
+			if price < 0 || price > math.MaxInt32 {
+				return &command.UsageError{Reason: "The price has to be a positive number."}
+			}
+
+			_, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopItems).InsertOne(m.gCtx, structures.ShopItem{
+				GuildID:     ctx.GuildID,
+				Name:        name,
+				Description: ctx.Args.String("description"),
+				Type:        itemType,
+				Price:       int32(price),
+				Stock:       -1,
+				CreatedAt:   time.Now(),
+			})
+			if mongo.IsDuplicateKeyError(err) {
+				return ctx.ReplyError(fmt.Sprintf("There already is an item called `%s`.", name))
+			}
+			if err != nil {
+				return err
+			}
+
+			msg := fmt.Sprintf("Added `%s` to the shop.", name)
+			if itemType == structures.ShopItemTypeRole || itemType == structures.ShopItemTypeRoleColor {
+				msg += fmt.Sprintf(" Use `%sshop set %s role <role>` to set its role.", ctx.Prefix, name)
+			}
+
+			_, err = ctx.Reply(msg)
+			return err
+		},
+	}
+}
+
+func (m *Module) ShopSetCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "shop set"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "set")
+		},
+		Info:        "Change an item, the fields are price, description, role, duration, stock and limit",
+		ExampleInfo: []string{"shop set vip duration 7d", "shop set vip stock 10", "shop set vip stock unlimited", "shop set vip limit 1"},
+		Perms:       m.moderatorPermission(),
+		Args: []command.Arg{
+			{
+				Name:        "item",
+				Description: "The name of the item",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+			{
+				Name:        "field",
+				Description: "The field to change",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+			{
+				Name:        "value",
+				Description: "The new value, none clears the role, duration and limit",
+				Type:        command.ArgText,
+				Required:    true,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			name := strings.ToLower(ctx.Args.String("item"))
+			field := strings.ToLower(ctx.Args.String("field"))
+
+			key, value, err := shopField(ctx, field, strings.TrimSpace(ctx.Args.String("value")))
+			if err != nil {
+				return err
+			}
+
+			res, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopItems).UpdateOne(m.gCtx, bson.M{
+				"guild_id": ctx.GuildID,
+				"name":     name,
+			}, bson.M{
+				"$set": bson.M{
+					key: value,
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if res.MatchedCount == 0 {
+				return ctx.ReplyError(fmt.Sprintf("There is no item called `%s`.", name))
+			}
+
+			_, err = ctx.Reply(fmt.Sprintf("Changed the %s of `%s`.", field, name))
+			return err
+		},
+	}
+}
+
+func (m *Module) ShopRemoveCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "shop remove"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "remove")
+		},
+		Info:        "Remove an item from the shop, purchases of it are kept",
+		ExampleInfo: []string{"shop remove vip"},
+		Perms:       m.moderatorPermission(),
+		Args: []command.Arg{
+			{
+				Name:        "item",
+				Description: "The name of the item",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			name := strings.ToLower(ctx.Args.String("item"))
+
+			res, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopItems).DeleteOne(m.gCtx, bson.M{
+				"guild_id": ctx.GuildID,
+				"name":     name,
+			})
+			if err != nil {
+				return err
+			}
+			if res.DeletedCount == 0 {
+				return ctx.ReplyError(fmt.Sprintf("There is no item called `%s`.", name))
+			}
+
+			_, err = ctx.Reply(fmt.Sprintf("Removed `%s` from the shop.", name))
+			return err
+		},
+	}
+}
+
+func (m *Module) shopItem(guildID string, name string) (structures.ShopItem, error) {
+	item := structures.ShopItem{}
+	err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopItems).FindOne(m.gCtx, bson.M{
+		"guild_id": guildID,
+		"name":     strings.ToLower(name),
+	}).Decode(&item)
+
+	return item, err
+}
+
+// shopInput checks what a member wrote after the item they buy.
+func shopInput(item structures.ShopItem, input string) (string, error) {
+	input = strings.TrimSpace(input)
+
+	switch item.Type {
+	case structures.ShopItemTypeRoleColor:
+		match := shopColorRegex.FindStringSubmatch(input)
+		if match == nil {
+			return "", &command.UsageError{Reason: "The color has to be written like #e91e63."}
+		}
+
+		return "#" + strings.ToLower(match[1]), nil
+	case structures.ShopItemTypeNickname:
+		if input == "" || utf8.RuneCountInString(input) > 32 {
+			return "", &command.UsageError{Reason: "The nickname has to be 1 to 32 characters long."}
+		}
+	case structures.ShopItemTypeRequest:
+		if input == "" || utf8.RuneCountInString(input) > maxRequestLength {
+			return "", &command.UsageError{Reason: fmt.Sprintf("The request has to be 1 to %d characters long.", maxRequestLength)}
+		}
+	default:
+		return "", nil
+	}
+
+	return input, nil
+}
+
+// shopField parses a value for shop set and returns the key it is stored at.
+func shopField(ctx *command.Context, field string, value string) (string, interface{}, error) {
+	none := strings.EqualFold(value, "none")
+
+	switch field {
+	case "price":
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || n < 0 {
+			return "", nil, &command.UsageError{Reason: "The price has to be a positive number."}
+		}
+
+		return "price", int32(n), nil
+	case "description":
+		return "description", value, nil
+	case "role":
+		if none {
+			return "role_id", "", nil
+		}
+
+		match := roleIDRegex.FindStringSubmatch(value)
+		if match == nil {
+			return "", nil, &command.UsageError{Reason: "The role has to be a mention or id of a role."}
+		}
+		if _, err := ctx.Session.State.Role(ctx.GuildID, match[1]); err != nil {
+			return "", nil, &command.UsageError{Reason: fmt.Sprintf("There is no role with the id `%s`.", match[1])}
+		}
+
+		return "role_id", match[1], nil
+	case "duration":
+		if none {
+			return "duration", time.Duration(0), nil
+		}
+
+		d, err := command.ParseDuration(value)
+		if err != nil || d < 0 {
+			return "", nil, &command.UsageError{Reason: "The duration has to be like 12h or 7d."}
+		}
+
+		return "duration", d, nil
+	case "stock":
+		if strings.EqualFold(value, "unlimited") || none {
+			return "stock", int32(-1), nil
+		}
+
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || n < 0 {
+			return "", nil, &command.UsageError{Reason: "The stock has to be a positive number or unlimited."}
+		}
+
+		return "stock", int32(n), nil
+	case "limit":
+		if none {
+			return "user_limit", int32(0), nil
+		}
+
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || n < 0 {
+			return "", nil, &command.UsageError{Reason: "The limit has to be a positive number or none."}
+		}
+
+		return "user_limit", int32(n), nil
+	}
+
+	return "", nil, &command.UsageError{Reason: "The field has to be price, description, role, duration, stock or limit."}
+}
+
+func formatItem(item structures.ShopItem) string {
+	parts := []string{}
+	switch item.Type {
+	case structures.ShopItemTypeRole:
+		parts = append(parts, fmt.Sprintf("Gives you <@&%s>", item.RoleID))
+	case structures.ShopItemTypeRoleColor:
+		parts = append(parts, fmt.Sprintf("Gives you <@&%s> in a color you pick", item.RoleID))
+	case structures.ShopItemTypeNickname:
+		parts = append(parts, "Changes your nickname")
+	case structures.ShopItemTypeRequest:
+		parts = append(parts, "A request for the stream")
+	}
+
+	if item.Duration > 0 && item.Type != structures.ShopItemTypeRequest {
+		parts[0] += " for " + item.Duration.String()
+	}
+	if item.Stock >= 0 {
+		parts = append(parts, fmt.Sprintf("%d left", item.Stock))
+	}
+	if item.UserLimit > 0 {
+		parts = append(parts, fmt.Sprintf("%d per user", item.UserLimit))
+	}
+
+	value := strings.Join(parts, ", ")
+	if item.Description != "" {
+		value = item.Description + "\n" + value
+	}
+
+	return value
+}
+
+func formatExpiry(r structures.ShopRedemption) string {
+	if r.ExpiresAt.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprintf(" It expires <t:%d:R>.", r.ExpiresAt.Unix())
+}
+
+// expireLoop revokes the grants which expired, they are stored in mongo so that restarts do not skip them.
+func (m *Module) expireLoop() {
+	tick := time.NewTicker(shopExpireInterval)
+	defer tick.Stop()
+
+	for {
+		m.expireRedemptions()
+
+		select {
+		case <-m.gCtx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (m *Module) expireRedemptions() {
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Minute)
+	defer cancel()
+
+	cur, err := m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).Find(ctx, bson.M{
+		"status":     structures.ShopRedemptionStatusActive,
+		"expires_at": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		logrus.Error("failed to find expired shop redemptions: ", err)
+		return
+	}
+
+	redemptions := []structures.ShopRedemption{}
+	if err = cur.All(ctx, &redemptions); err != nil {
+		logrus.Error("failed to find expired shop redemptions: ", err)
+		return
+	}
+
+	for _, r := range redemptions {
+		// the grant is marked as expired even when revoking fails, for example because the member left.
+		if err = m.revoke(ctx, r); err != nil {
+			logrus.WithField("guild_id", r.GuildID).WithField("user_id", r.UserID).Errorf("failed to revoke %s: %s", r.Item.Name, err.Error())
+		}
+
+		_, err = m.gCtx.Inst().Mongo.Collection(mongo.CollectionNameShopRedemptions).UpdateOne(ctx, bson.M{
+			"_id":    r.ID,
+			"status": structures.ShopRedemptionStatusActive,
+		}, bson.M{
+			"$set": bson.M{
+				"status":     structures.ShopRedemptionStatusExpired,
+				"updated_at": time.Now(),
+			},
+		})
+		if err != nil {
+			logrus.Error("failed to expire shop redemption: ", err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package points
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/configure"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// duplicateSimilarity is how similar two messages have to be to count as the same message.
+	duplicateSimilarity = 0.8
+	duplicateHistoryTTL = time.Hour
+)
+
+// messagePoints returns the points a message is worth, 0 when it does not give points.
+// it only uses its own keys so that the limits are not touched for messages which do not count.
+func (m *Module) messagePoints(ctx context.Context, s *discordgo.Session, msg *discordgo.MessageCreate) (int, error) {
+	cfg := m.config(msg.GuildID)
+
+	// a category or the parent channel of a thread applies to every channel in it.
+	channelIDs := []string{msg.ChannelID}
+	if channel, err := s.State.Channel(msg.ChannelID); err == nil && channel.ParentID != "" {
+		channelIDs = append(channelIDs, channel.ParentID)
+	}
+
+	for _, v := range cfg.ExcludedChannels {
+		for _, id := range channelIDs {
+			if v == id {
+				return 0, nil
+			}
+		}
+	}
+
+	content := normalizeMessage(msg.Content)
+	if len([]rune(content)) < cfg.MinMessageLength {
+		return 0, nil
+	}
+
+	if cfg.DuplicateHistory > 0 {
+		duplicate, err := m.isDuplicate(ctx, msg.GuildID, msg.Author.ID, collapseRepeats(content), cfg.DuplicateHistory)
+		if err != nil || duplicate {
+			return 0, err
+		}
+	}
+
+	if cfg.MessageInterval > 0 {
+		ok, err := m.gCtx.Inst().Redis.SetNX(ctx, fmt.Sprintf("message-interval:%s:%s", msg.GuildID, msg.Author.ID), "1", cfg.MessageInterval)
+		if err != nil || !ok {
+			return 0, err
+		}
+	}
+
+	return int(math.Round(float64(cfg.PointsPerMessage) * channelMultiplier(cfg, channelIDs))), nil
+}
+
+// channelMultiplier returns the multiplier of the first channel which has one, channels come before their category.
+func channelMultiplier(cfg configure.PointsModule, channelIDs []string) float64 {
+	for _, id := range channelIDs {
+		for _, v := range cfg.ChannelMultipliers {
+			if v.ID == id {
+				return v.Multiplier
+			}
+		}
+	}
+
+	return 1
+}
+
+// isDuplicate compares a message to the last messages of a member and adds it to them.
+func (m *Module) isDuplicate(ctx context.Context, guildID string, userID string, content string, history int) (bool, error) {
+	key := fmt.Sprintf("message-history:%s:%s", guildID, userID)
+
+	pipe := m.gCtx.Inst().Redis.Pipeline(ctx)
+	recentCmd := pipe.LRange(ctx, key, 0, int64(history-1))
+	pipe.LPush(ctx, key, content)
+	pipe.LTrim(ctx, key, 0, int64(history-1))
+	pipe.Expire(ctx, key, duplicateHistoryTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	for _, v := range recentCmd.Val() {
+		if similarity(v, content) >= duplicateSimilarity {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// normalizeMessage keeps the letters and digits of a message in lower case, so that punctuation, emotes and
+// mentions do not count towards its length.
+func normalizeMessage(content string) string {
+	fields := strings.Fields(content)
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if strings.HasPrefix(field, "<") && strings.HasSuffix(field, ">") {
+			continue
+		}
+
+		word := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, field)
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+
+	return strings.Join(words, "")
+}
+
+// collapseRepeats removes repeated characters, so that "looool" and "lol" are the same message.
+func collapseRepeats(content string) string {
+	b := strings.Builder{}
+	var last rune
+	for i, r := range content {
+		if i == 0 || r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+
+	return b.String()
+}
+
+// similarity is the dice coefficient of the character pairs of two messages, 1 for equal messages.
+func similarity(a string, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	pairsA, pairsB := pairs(a), pairs(b)
+	if len(pairsA) == 0 || len(pairsB) == 0 {
+		return 0
+	}
+
+	counts := map[string]int{}
+	for _, v := range pairsA {
+		counts[v]++
+	}
+
+	shared := 0
+	for _, v := range pairsB {
+		if counts[v] > 0 {
+			counts[v]--
+			shared++
+		}
+	}
+
+	return float64(shared*2) / float64(len(pairsA)+len(pairsB))
+}
+
+func pairs(content string) []string {
+	runes := []rune(content)
+	if len(runes) < 2 {
+		return nil
+	}
+
+	result := make([]string, len(runes)-1)
+	for i := range result {
+		result[i] = string(runes[i : i+2])
+	}
+
+	return result
+}
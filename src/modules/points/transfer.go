@@ -0,0 +1,189 @@
+package points
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis/v8"
+)
+
+const transferConfirmTimeout = time.Second * 30
+
+// transfer moves points from one member to another.
+func (m *Module) transfer(ctx context.Context, guildID string, fromID string, toID string, amount int32) (structures.PointsTransaction, error) {
+	tx, err := m.takePoints(ctx, structures.PointsTransaction{
+		GuildID: guildID,
+		UserID:  fromID,
+		Source:  structures.PointsSourceTransfer,
+		ActorID: fromID,
+		Delta:   -amount,
+	})
+	if err != nil {
+		return tx, err
+	}
+
+	_, err = m.addPoints(ctx, structures.PointsTransaction{
+		GuildID: guildID,
+		UserID:  toID,
+		Source:  structures.PointsSourceTransfer,
+		ActorID: fromID,
+		Delta:   amount,
+	})
+	if err != nil {
+		// the sender gets their points back when the recipient could not be given them.
+		if _, e := m.addPoints(ctx, structures.PointsTransaction{
+			GuildID:  guildID,
+			UserID:   fromID,
+			Source:   structures.PointsSourceRevert,
+			Delta:    amount,
+			RevertOf: tx.ID,
+		}); e != nil {
+			return tx, fmt.Errorf("%w, failed to refund: %s", err, e.Error())
+		}
+
+		return tx, err
+	}
+
+	return tx, nil
+}
+
+// reserveTransfer adds amount to the points a user gave away today, it returns false when that exceeds the daily limit.
+func (m *Module) reserveTransfer(ctx context.Context, key string, amount int64, limit int64) (bool, error) {
+	pipe := m.gCtx.Inst().Redis.Pipeline(ctx)
+	incrCmd := pipe.IncrBy(ctx, key, amount)
+	ttlCmd := pipe.TTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	if ttlCmd.Val() == -1 {
+		if err := m.gCtx.Inst().Redis.Expire(ctx, key, time.Hour*24); err != nil {
+			return false, err
+		}
+	}
+
+	if incrCmd.Val() > limit {
+		m.releaseTransfer(key, amount)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (m *Module) releaseTransfer(key string, amount int64) {
+	pipe := m.gCtx.Inst().Redis.Pipeline(m.gCtx)
+	pipe.DecrBy(m.gCtx, key, amount)
+	_, _ = pipe.Exec(m.gCtx)
+}
+
+func (m *Module) GiveCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "points give"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "give")
+		},
+		Info:        "Give some of your points to another user",
+		ExampleInfo: []string{"points give Troy 100"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to give points to",
+				Type:        command.ArgMember,
+				Required:    true,
+			},
+			{
+				Name:        "amount",
+				Description: "The amount of points to give",
+				Type:        command.ArgInteger,
+				Required:    true,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			cfg := m.config(ctx.GuildID)
+			member := ctx.Args.Member("user")
+			amount := ctx.Args.Int("amount")
+
+			if amount < 1 || amount > math.MaxInt32 {
+				return &command.UsageError{Reason: "The amount has to be a positive number."}
+			}
+
+			if member.User.ID == ctx.Author.ID {
+				return ctx.ReplyError("You can not give points to yourself.")
+			}
+
+			if member.User.Bot {
+				return ctx.ReplyError("You can not give points to bots.")
+			}
+
+			if cfg.TransferDailyLimit == 0 {
+				return ctx.ReplyError("Giving points is disabled on this server.")
+			}
+
+			created, err := discordgo.SnowflakeTimestamp(ctx.Author.ID)
+			if err != nil {
+				return err
+			}
+			if time.Since(created) < cfg.TransferMinAccountAge {
+				return ctx.ReplyError(fmt.Sprintf("Your account has to be older than %s to give points.", cfg.TransferMinAccountAge))
+			}
+
+			key := fmt.Sprintf("points-transfer-daily:%s:%s", ctx.GuildID, ctx.Author.ID)
+			given, err := m.gCtx.Inst().Redis.Get(m.gCtx, key)
+			if err != nil && err != redis.Nil {
+				return err
+			}
+
+			left := int64(cfg.TransferDailyLimit)
+			if given != "" {
+				n, _ := strconv.ParseInt(given, 10, 64)
+				left -= n
+			}
+			if left < 0 {
+				left = 0
+			}
+			if amount > left {
+				return ctx.ReplyError(fmt.Sprintf("You can only give %d more points today.", left))
+			}
+
+			confirmed, err := ctx.Confirm(fmt.Sprintf("%s, do you want to give **%d** points to %s? React with %s to confirm.", ctx.Author.Mention(), amount, member.User, command.ConfirmEmoji), transferConfirmTimeout)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				_, err = ctx.Send("The transfer was cancelled.")
+				return err
+			}
+
+			// the limit is checked again since other transfers could have happened while waiting for the confirmation.
+			ok, err := m.reserveTransfer(m.gCtx, key, amount, int64(cfg.TransferDailyLimit))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ctx.ReplyError("You have given too many points today.")
+			}
+
+			tx, err := m.transfer(m.gCtx, ctx.GuildID, ctx.Author.ID, member.User.ID, int32(amount))
+			if err != nil {
+				m.releaseTransfer(key, amount)
+				if err == ErrInsufficientPoints {
+					return ctx.ReplyError(fmt.Sprintf("You do not have %d points.", amount))
+				}
+
+				return err
+			}
+
+			_, err = ctx.Send(fmt.Sprintf("%s gave %d points to %s, you have %d points left.", ctx.Author.Mention(), amount, member.User.Mention(), tx.Balance))
+			return err
+		},
+	}
+}
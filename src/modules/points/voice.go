@@ -0,0 +1,304 @@
+package points
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	voiceInterval = time.Minute
+	// voiceSessionGap is how long a session can go without being seen before the time in between is not counted,
+	// it covers restarts of the bot.
+	voiceSessionGap = time.Minute * 5
+	voiceSessionTTL = time.Hour
+)
+
+// voiceSession is the time a member spent in voice which was not turned into points yet.
+type voiceSession struct {
+	ChannelID string    `json:"channel_id"`
+	Since     time.Time `json:"since"`
+	Seen      time.Time `json:"seen"`
+}
+
+func voiceSessionKey(guildID string, userID string) string {
+	return fmt.Sprintf("voice-session:%s:%s", guildID, userID)
+}
+
+func voiceSessionsKey(guildID string) string {
+	return fmt.Sprintf("voice-sessions:%s", guildID)
+}
+
+func (m *Module) onVoiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	if v.GuildID == "" {
+		return
+	}
+
+	// joining or leaving can change if the others in the channel are alone, so everyone in both channels is checked.
+	channelIDs := []string{v.ChannelID}
+	if v.BeforeUpdate != nil {
+		channelIDs = append(channelIDs, v.BeforeUpdate.ChannelID)
+	}
+
+	m.syncVoice(s, v.GuildID, append(voiceUsers(s, v.GuildID, channelIDs), v.UserID))
+}
+
+// voiceLoop gives the points of the ongoing sessions every interval, so that they are not lost when the bot stops.
+func (m *Module) voiceLoop() {
+	tick := time.NewTicker(voiceInterval)
+	defer tick.Stop()
+
+	for {
+		s := m.gCtx.Inst().Discord.Session()
+
+		s.State.RLock()
+		guildIDs := make([]string, len(s.State.Guilds))
+		for i, g := range s.State.Guilds {
+			guildIDs[i] = g.ID
+		}
+		s.State.RUnlock()
+
+		for _, guildID := range guildIDs {
+			m.syncVoice(s, guildID, nil)
+		}
+
+		select {
+		case <-m.gCtx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// voiceLock returns the lock of the voice sessions of a guild.
+func (m *Module) voiceLock(guildID string) *sync.Mutex {
+	m.voiceMtx.Lock()
+	defer m.voiceMtx.Unlock()
+
+	if m.voiceLocks[guildID] == nil {
+		m.voiceLocks[guildID] = &sync.Mutex{}
+	}
+
+	return m.voiceLocks[guildID]
+}
+
+// syncVoice starts sessions for the members who earn voice points, ends the ones of members who do not anymore
+// and gives the points of the full minutes of every session. only the sessions of userIDs are checked, every
+// session and every member who earns voice points is checked when it is nil.
+func (m *Module) syncVoice(s *discordgo.Session, guildID string, userIDs []string) {
+	lock := m.voiceLock(guildID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(m.gCtx, time.Minute)
+	defer cancel()
+
+	eligible, err := voiceEligible(s, guildID)
+	if err != nil {
+		// guilds which are not loaded yet keep their sessions until they are.
+		return
+	}
+
+	cfg := m.config(guildID)
+	if !cfg.Enabled || cfg.VoicePointsPerMinute == 0 {
+		eligible = map[string]string{}
+	}
+
+	users := map[string]bool{}
+	for _, v := range userIDs {
+		users[v] = true
+	}
+
+	if userIDs == nil {
+		pipe := m.gCtx.Inst().Redis.Pipeline(ctx)
+		membersCmd := pipe.SMembers(ctx, voiceSessionsKey(guildID))
+		if _, err = pipe.Exec(ctx); err != nil && err != redis.Nil {
+			logrus.Error("failed to get voice sessions: ", err)
+			return
+		}
+
+		for _, v := range membersCmd.Val() {
+			users[v] = true
+		}
+		for userID := range eligible {
+			users[userID] = true
+		}
+	}
+
+	for userID := range users {
+		if err = m.syncVoiceSession(ctx, s, guildID, userID, eligible); err != nil {
+			logrus.WithField("guild_id", guildID).WithField("user_id", userID).Error("failed to sync voice session: ", err)
+		}
+	}
+}
+
+func (m *Module) syncVoiceSession(ctx context.Context, s *discordgo.Session, guildID string, userID string, eligible map[string]string) error {
+	now := time.Now()
+	key := voiceSessionKey(guildID, userID)
+
+	session := voiceSession{}
+	raw, err := m.gCtx.Inst().Redis.Get(ctx, key)
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	found := raw != ""
+	if found {
+		if err = json.Unmarshal([]byte(raw), &session); err != nil {
+			return err
+		}
+
+		until := now
+		if now.Sub(session.Seen) > voiceSessionGap {
+			until = session.Seen
+		}
+
+		session = m.settleVoice(ctx, s, guildID, userID, session, until)
+		if until != now {
+			session.Since = now
+		}
+	}
+
+	channelID, ok := eligible[userID]
+	if !ok {
+		pipe := m.gCtx.Inst().Redis.Pipeline(ctx)
+		pipe.Del(ctx, key)
+		pipe.SRem(ctx, voiceSessionsKey(guildID), userID)
+		_, err = pipe.Exec(ctx)
+		return err
+	}
+
+	if !found {
+		session.Since = now
+	}
+	session.ChannelID = channelID
+	session.Seen = now
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	if err = m.gCtx.Inst().Redis.SetEX(ctx, key, string(data), voiceSessionTTL); err != nil {
+		return err
+	}
+
+	return m.gCtx.Inst().Redis.SAdd(ctx, voiceSessionsKey(guildID), userID)
+}
+
+// settleVoice gives the points for the full minutes of a session until a time, the rest of a minute is kept in the session.
+func (m *Module) settleVoice(ctx context.Context, s *discordgo.Session, guildID string, userID string, session voiceSession, until time.Time) voiceSession {
+	minutes := int(until.Sub(session.Since) / time.Minute)
+	if minutes <= 0 {
+		return session
+	}
+	session.Since = session.Since.Add(time.Duration(minutes) * time.Minute)
+
+	cfg := m.config(guildID)
+	points := minutes * cfg.VoicePointsPerMinute
+	if !cfg.Enabled || points <= 0 {
+		return session
+	}
+
+	// the time is used up even when the limits are reached, like messages which are sent over the limits.
+	ok, release, err := m.reserve(ctx, guildID, userID, points)
+	if err != nil {
+		logrus.Error("failed to add to limits: ", err)
+		return session
+	}
+	if !ok {
+		return session
+	}
+
+	tx, err := m.addPoints(ctx, structures.PointsTransaction{
+		GuildID: guildID,
+		UserID:  userID,
+		Source:  structures.PointsSourceVoice,
+		Delta:   int32(points),
+	})
+	if err != nil {
+		logrus.Error("failed to update member: ", err)
+		release()
+		return session
+	}
+
+	member, err := s.State.Member(guildID, userID)
+	if err != nil {
+		member, err = m.gCtx.Inst().Discord.Member(guildID, userID)
+	}
+	if err == nil {
+		m.syncRoles(s, guildID, userID, member.Roles, tx.Balance)
+	}
+
+	return session
+}
+
+// voiceUsers returns the users in the voice channels.
+func voiceUsers(s *discordgo.Session, guildID string, channelIDs []string) []string {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return nil
+	}
+
+	s.State.RLock()
+	defer s.State.RUnlock()
+
+	userIDs := []string{}
+	for _, v := range guild.VoiceStates {
+		for _, channelID := range channelIDs {
+			if channelID != "" && v.ChannelID == channelID {
+				userIDs = append(userIDs, v.UserID)
+			}
+		}
+	}
+
+	return userIDs
+}
+
+// voiceEligible returns the channels of the members who earn voice points, they have to be in a channel other than
+// the afk channel with at least one other person and can not be muted or deafened by themselves.
+func voiceEligible(s *discordgo.Session, guildID string) (map[string]string, error) {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.State.RLock()
+	defer s.State.RUnlock()
+
+	bots := map[string]bool{}
+	for _, v := range guild.Members {
+		if v.User != nil && v.User.Bot {
+			bots[v.User.ID] = true
+		}
+	}
+
+	people := map[string]int{}
+	for _, v := range guild.VoiceStates {
+		if v.ChannelID != "" && !bots[v.UserID] {
+			people[v.ChannelID]++
+		}
+	}
+
+	eligible := map[string]string{}
+	for _, v := range guild.VoiceStates {
+		if v.ChannelID == "" || v.ChannelID == guild.AfkChannelID || bots[v.UserID] {
+			continue
+		}
+
+		if v.SelfMute || v.SelfDeaf || people[v.ChannelID] < 2 {
+			continue
+		}
+
+		eligible[v.UserID] = v.ChannelID
+	}
+
+	return eligible, nil
+}
@@ -70,12 +70,15 @@ type Module struct {
 	Games      *steam.Client
 	Main       *steam.Client
 
+	// dotaMtx guards DotaClient for other modules, which use it through Dota.
+	dotaMtx sync.RWMutex
+
 	mainFriends sync.Map
 	gameFriends sync.Map
 
-	gamesOnce sync.Once
-	mainOnce  sync.Once
-	wg        sync.WaitGroup
+	gamesOnce *sync.Once
+	mainOnce  *sync.Once
+	wg        *sync.WaitGroup
 }
 
 func New() *Module {
@@ -86,6 +89,24 @@ func (m *Module) Name() string {
 	return "Tracker"
 }
 
+// Dota returns the dota client when it is connected to the game coordinator, nil otherwise.
+func (m *Module) Dota() *dota2.DotaClient {
+	m.dotaMtx.RLock()
+	defer m.dotaMtx.RUnlock()
+
+	if m.DotaClient == nil || !m.DotaClient.Ready() {
+		return nil
+	}
+
+	select {
+	case <-m.DotaClient.Done():
+		return nil
+	default:
+	}
+
+	return m.DotaClient
+}
+
 type APIMatch struct {
 	MatchID int64 `json:"match_id"`
 }
@@ -95,13 +116,20 @@ func (m *Module) Register(gCtx global.Context) (<-chan struct{}, error) {
 
 	m.Ctx = gCtx
 
+	// a restarted module waits for the friends of the new clients.
+	m.gamesOnce = &sync.Once{}
+	m.mainOnce = &sync.Once{}
+	m.wg = &sync.WaitGroup{}
 	m.wg.Add(2)
 
-	m.DotaClient = dota2.New(gCtx, steam.AccDetails{
+	dotaClient := dota2.New(gCtx, steam.AccDetails{
 		TotpSecret: gCtx.Config().Modules.Tracker.Steam.Dota.TotpSecret,
 		Username:   gCtx.Config().Modules.Tracker.Steam.Dota.Username,
 		Password:   gCtx.Config().Modules.Tracker.Steam.Dota.Password,
 	})
+	m.dotaMtx.Lock()
+	m.DotaClient = dotaClient
+	m.dotaMtx.Unlock()
 	m.Games = steam.NewClient(gCtx, &steam.Config{
 		Details: steam.AccDetails{
 			TotpSecret: gCtx.Config().Modules.Tracker.Steam.Games.TotpSecret,
@@ -178,9 +206,14 @@ func (m *Module) Register(gCtx global.Context) (<-chan struct{}, error) {
 		},
 	}
 
+	go func() {
+		<-gCtx.Done()
+		_ = srv.Shutdown()
+	}()
+
 	go func() {
 		if err := srv.ListenAndServe(gCtx.Config().Modules.Tracker.HTTP.Bind); err != nil {
-			logrus.Fatal("failed to listen http: ", err)
+			logrus.Error("failed to listen http: ", err)
 		}
 
 		<-m.DotaClient.Done()
@@ -191,12 +224,17 @@ func (m *Module) Register(gCtx global.Context) (<-chan struct{}, error) {
 	}()
 
 	go m.autoQueryStats()
+	wg := m.wg
 	go func() {
-		m.wg.Wait()
+		wg.Wait()
 		m.autoAdjustNicknames()
 	}()
 
-	err := gCtx.Inst().Discord.RegisterCommand("dotagames-manage", m.CommandGroup())
+	closeFn, err := command.Register(gCtx.Inst().Discord, "dotagames-manage", m.CommandGroup())
+	go func() {
+		<-gCtx.Done()
+		closeFn()
+	}()
 
 	return done, err
 }
@@ -209,6 +247,12 @@ func (m *Module) CommandGroup() command.Cmd {
 		MatchCmd: func(path []string) bool {
 			return len(path) != 0 && strings.EqualFold(path[0], "dotagames-manage")
 		},
+		Info:        "Manage the dota games tracker",
+		UsageInfo:   "<command>",
+		ExampleInfo: []string{"dotagames-manage query 6543210987"},
+		EnabledCmd: func(guildID string) bool {
+			return m.Ctx.Inst().Guilds.Config(guildID).Modules.Tracker.Enabled
+		},
 		Commands: map[string]command.Cmd{
 			"query":          m.QueryCmd(),
 			"force-nickname": m.ForceNickname(),
@@ -224,34 +268,29 @@ func (m *Module) QueryCmd() command.Cmd {
 		NameCmd: func() string {
 			return "dotagames-manage query"
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			perms, err := s.State.MessagePermissions(msg.Message)
-			if err != nil {
+		Info:        "Query dota matches by their match ids",
+		ExampleInfo: []string{"dotagames-manage query 6543210987 6543210988"},
+		Args: []command.Arg{
+			{
+				Name:        "match-ids",
+				Description: "The match ids to query separated by spaces",
+				Type:        command.ArgMatchID,
+				Required:    true,
+				Variadic:    true,
+			},
+		},
+		Perms: command.RolePermission(func(guildID string) []string {
+			return m.Ctx.Inst().Guilds.Config(guildID).Modules.Tracker.ModeratorRoles
+		}),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			// querying matches can take longer than discord allows interactions to wait for a response
+			if err := ctx.Defer(); err != nil {
 				return err
 			}
 
-			if perms&discordgo.PermissionAdministrator == 0 {
-				mp := map[string]bool{}
-				for _, v := range msg.Member.Roles {
-					mp[v] = true
-				}
-				found := false
-				for _, v := range m.Ctx.Config().Discord.AdminRoles {
-					if mp[v] {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return nil
-				}
-			}
-
 			matchIDs := []string{}
-			for _, v := range path {
-				if _, err := strconv.Atoi(v); err == nil {
-					matchIDs = append(matchIDs, v)
-				}
+			for _, v := range ctx.Args.MatchIDs("match-ids") {
+				matchIDs = append(matchIDs, strconv.FormatUint(v, 10))
 			}
 
 			matches := m.queryMatchIDs(matchIDs)
@@ -261,7 +300,7 @@ func (m *Module) QueryCmd() command.Cmd {
 				buf.WriteString(v.Game.GameID + "\n")
 			}
 
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, buf.String(), msg.Reference())
+			_, err := ctx.Reply(buf.String())
 			return err
 		},
 	}
@@ -275,55 +314,33 @@ func (m *Module) ForceNickname() command.Cmd {
 		NameCmd: func() string {
 			return "dotagames-manage force-nickname"
 		},
-		ExecuteCmd: func(s *discordgo.Session, msg *discordgo.MessageCreate, path []string) error {
-			perms, err := s.State.MessagePermissions(msg.Message)
-			if err != nil {
-				return err
-			}
-
-			if perms&discordgo.PermissionAdministrator == 0 {
-				mp := map[string]bool{}
-				for _, v := range msg.Member.Roles {
-					mp[v] = true
-				}
-				found := false
-				for _, v := range m.Ctx.Config().Discord.AdminRoles {
-					if mp[v] {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return nil
-				}
-			}
-
-			guild, err := s.State.Guild(msg.GuildID)
-			if err != nil {
-				return err
-			}
-
-			search := strings.TrimSpace(strings.ToLower(strings.Join(path, " ")))
-			member := utils.FindMember(s, guild, msg.Message, search)
-			if member == nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
-			}
+		Info:        "Force the steam nicknames of a user to be adjusted",
+		ExampleInfo: []string{"dotagames-manage force-nickname Troy"},
+		Args: []command.Arg{
+			{
+				Name:        "user",
+				Description: "The user to adjust",
+				Type:        command.ArgMember,
+				Required:    true,
+			},
+		},
+		Perms: command.RolePermission(func(guildID string) []string {
+			return m.Ctx.Inst().Guilds.Config(guildID).Modules.Tracker.ModeratorRoles
+		}),
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			member := ctx.Args.Member("user")
 
 			res := m.Ctx.Inst().Mongo.Collection(mongo.CollectionNameUsers).FindOne(context.Background(), bson.M{
 				"discord.id": member.User.ID,
 			})
 			user := structures.User{}
-			err = res.Err()
+			err := res.Err()
 			if err == nil {
 				err = res.Decode(&user)
 			}
 			if err != nil {
 				if err == mongo.ErrNoDocuments {
-					st, err := s.ChannelMessageSendReply(msg.ChannelID, "Couldn't find that user", msg.Reference())
-					utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-					return err
+					return ctx.ReplyError("Couldn't find that user")
 				}
 
 				return err
@@ -331,12 +348,10 @@ func (m *Module) ForceNickname() command.Cmd {
 
 			err = m.adjustNickname(context.Background(), user, sectionGames|sectionMain|forceNickname)
 			if err != nil {
-				st, err := s.ChannelMessageSendReply(msg.ChannelID, "Failed to adjust user's nickname", msg.Reference())
-				utils.CleanUpMessageDeny(s, st, time.Second*10, msg.ID)
-				return err
+				return ctx.ReplyError("Failed to adjust user's nickname")
 			}
 
-			_, err = s.ChannelMessageSendReply(msg.ChannelID, "User's nickname has been adjusted", msg.Reference())
+			_, err = ctx.Reply("User's nickname has been adjusted")
 			return err
 		},
 	}
@@ -1026,7 +1041,11 @@ const (
 )
 
 func (m *Module) adjustNickname(ctx context.Context, user structures.User, flags int) error {
-	member, err := m.Ctx.Inst().Discord.Member(m.Ctx.Config().Discord.GuildID, user.Discord.ID)
+	// nicknames are based on the roles in the primary guild.
+	guildID := m.Ctx.Inst().Guilds.Primary()
+	cfg := m.Ctx.Inst().Guilds.Config(guildID).Modules.Tracker
+
+	member, err := m.Ctx.Inst().Discord.Member(guildID, user.Discord.ID)
 	if err != nil {
 		logrus.Error("unable to get member of user: ", err)
 		return err
@@ -1039,14 +1058,14 @@ func (m *Module) adjustNickname(ctx context.Context, user structures.User, flags
 
 	isSpecial := false
 	isSub := false
-	for _, r := range m.Ctx.Config().Modules.Tracker.SpecialRoles {
+	for _, r := range cfg.SpecialRoles {
 		if roleMp[r] {
 			isSpecial = true
 			break
 		}
 	}
 	if !isSpecial {
-		for _, r := range m.Ctx.Config().Modules.Tracker.SubRoles {
+		for _, r := range cfg.SubRoles {
 			if roleMp[r] {
 				isSub = true
 				break
@@ -0,0 +1,17 @@
+package structures
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CommandAlias struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty"`
+	GuildID string             `bson:"guild_id"`
+	Alias   string             `bson:"alias"`
+	// Command is the path the alias expands to, for example "inhouse join".
+	Command   string    `bson:"command"`
+	CreatedBy string    `bson:"created_by"`
+	CreatedAt time.Time `bson:"created_at"`
+}
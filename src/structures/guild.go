@@ -0,0 +1,16 @@
+package structures
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Guild struct {
+	// ID is the id of the discord guild.
+	ID       string    `bson:"_id"`
+	Name     string    `bson:"name"`
+	JoinedAt time.Time `bson:"joined_at"`
+	// Config are the overrides of the yaml config, only the fields which were changed are stored.
+	Config bson.Raw `bson:"config,omitempty"`
+}
@@ -0,0 +1,98 @@
+package structures
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type InhouseTeam string
+
+const (
+	InhouseTeamRadiant InhouseTeam = "radiant"
+	InhouseTeamDire    InhouseTeam = "dire"
+)
+
+type InhouseMatchStatus string
+
+const (
+	InhouseMatchStatusPlaying   InhouseMatchStatus = "playing"
+	InhouseMatchStatusFinished  InhouseMatchStatus = "finished"
+	InhouseMatchStatusCancelled InhouseMatchStatus = "cancelled"
+)
+
+// InhouseMatch is a match of the inhouse league, it is created once every player of a popped queue is ready.
+type InhouseMatch struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty"`
+	GuildID string             `bson:"guild_id"`
+	// Number counts the matches of a guild, it is how matches are referred to in commands.
+	Number  int32              `bson:"number"`
+	Status  InhouseMatchStatus `bson:"status"`
+	Radiant []InhousePlayer    `bson:"radiant"`
+	Dire    []InhousePlayer    `bson:"dire"`
+	Winner  InhouseTeam        `bson:"winner,omitempty"`
+	// Reports are the winners the captains reported, the match finishes once both reported the same one.
+	Reports map[string]InhouseTeam `bson:"reports,omitempty"`
+	// ReportedBy is the moderator or the last captain who reported the winner.
+	ReportedBy string `bson:"reported_by,omitempty"`
+	// VoiceChannels are the temporary voice channels of the teams, they are deleted once the match is over.
+	VoiceChannels map[InhouseTeam]string `bson:"voice_channels,omitempty"`
+	// Lobby is the dota lobby of the match when it is hosted by the bot.
+	Lobby *InhouseLobby `bson:"lobby,omitempty"`
+
+	CreatedAt  time.Time `bson:"created_at"`
+	FinishedAt time.Time `bson:"finished_at,omitempty"`
+}
+
+// InhouseLobby is a practice lobby the players are invited to, MatchID is the id of the dota match once the game
+// started, its result is then reported automatically.
+type InhouseLobby struct {
+	Name     string `bson:"name"`
+	Password string `bson:"password"`
+	MatchID  string `bson:"match_id,omitempty"`
+}
+
+// InhousePlayer is a player of a match, Rating is their rating when it started until it finishes,
+// then it is the rating the result was calculated with and Delta how much it changed.
+type InhousePlayer struct {
+	UserID  string `bson:"user_id"`
+	Rating  int32  `bson:"rating"`
+	Delta   int32  `bson:"delta,omitempty"`
+	Captain bool   `bson:"captain,omitempty"`
+}
+
+// Team returns the team of a user in the match and their player, the team is empty when they did not play.
+func (m InhouseMatch) Team(userID string) (InhouseTeam, InhousePlayer) {
+	for _, v := range m.Radiant {
+		if v.UserID == userID {
+			return InhouseTeamRadiant, v
+		}
+	}
+	for _, v := range m.Dire {
+		if v.UserID == userID {
+			return InhouseTeamDire, v
+		}
+	}
+
+	return "", InhousePlayer{}
+}
+
+// Players returns the players of a team.
+func (m InhouseMatch) Players(team InhouseTeam) []InhousePlayer {
+	if team == InhouseTeamDire {
+		return m.Dire
+	}
+
+	return m.Radiant
+}
+
+// Captain returns the captain of a team.
+func (m InhouseMatch) Captain(team InhouseTeam) InhousePlayer {
+	for _, v := range m.Players(team) {
+		if v.Captain {
+			return v
+		}
+	}
+
+	return InhousePlayer{}
+}
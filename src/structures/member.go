@@ -0,0 +1,27 @@
+package structures
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Member is the data of a user which is scoped to a guild.
+type Member struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty"`
+	GuildID string             `bson:"guild_id"`
+	UserID  string             `bson:"user_id"`
+
+	Modules MemberModules `bson:"modules"`
+}
+
+type MemberModules struct {
+	Points MemberModulesPoints `bson:"points"`
+}
+
+type MemberModulesPoints struct {
+	Points int32 `bson:"points"`
+	// LastActive is the last time the member earned points by talking, LastDecay the last time their points decayed.
+	LastActive time.Time `bson:"last_active,omitempty"`
+	LastDecay  time.Time `bson:"last_decay,omitempty"`
+}
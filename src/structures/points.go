@@ -0,0 +1,43 @@
+package structures
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PointsSource string
+
+const (
+	PointsSourceMessage  PointsSource = "message"
+	PointsSourceVoice    PointsSource = "voice"
+	PointsSourceAdminAdd PointsSource = "admin_add"
+	PointsSourceAdminSet PointsSource = "admin_set"
+	PointsSourceTransfer PointsSource = "transfer"
+	PointsSourceDecay    PointsSource = "decay"
+	// PointsSourceSeason is the reset of the points at the end of a season.
+	PointsSourceSeason PointsSource = "season"
+	PointsSourceRevert PointsSource = "revert"
+	PointsSourceShop   PointsSource = "shop"
+	// PointsSourceShopRefund gives the points of a rejected shop redemption back.
+	PointsSourceShopRefund PointsSource = "shop_refund"
+	// PointsSourceImport is a change made by importing a csv file.
+	PointsSourceImport PointsSource = "import"
+)
+
+// PointsTransaction is an entry of the points ledger, every change of the points of a member has one.
+type PointsTransaction struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty"`
+	GuildID string             `bson:"guild_id"`
+	UserID  string             `bson:"user_id"`
+	Source  PointsSource       `bson:"source"`
+	// ActorID is the user who made the change, it is empty for changes made by the bot.
+	ActorID string `bson:"actor_id,omitempty"`
+	Delta   int32  `bson:"delta"`
+	// Balance is the points of the member after the change.
+	Balance int32 `bson:"balance"`
+	// RevertOf is the transaction a revert undoes, RevertedBy is the revert of a transaction.
+	RevertOf   primitive.ObjectID `bson:"revert_of,omitempty"`
+	RevertedBy primitive.ObjectID `bson:"reverted_by,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at"`
+}
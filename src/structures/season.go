@@ -0,0 +1,33 @@
+package structures
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PointsSeason is a closed season of the points, the balances at its end are kept as its standings.
+type PointsSeason struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	GuildID   string             `bson:"guild_id"`
+	Number    int32              `bson:"number"`
+	StartedAt time.Time          `bson:"started_at,omitempty"`
+	EndedAt   time.Time          `bson:"ended_at"`
+	ClosedBy  string             `bson:"closed_by"`
+	// Members is how many members had points at the end of the season.
+	Members int64 `bson:"members"`
+	// Champions are the members who were given the champion role.
+	Champions []string `bson:"champions"`
+	// CarryOver is the percentage of the points which was kept for the next season.
+	CarryOver int `bson:"carry_over"`
+}
+
+// PointsSeasonStanding is the place of a member at the end of a season.
+type PointsSeasonStanding struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	SeasonID primitive.ObjectID `bson:"season_id"`
+	GuildID  string             `bson:"guild_id"`
+	UserID   string             `bson:"user_id"`
+	Rank     int64              `bson:"rank"`
+	Points   int32              `bson:"points"`
+}
@@ -0,0 +1,69 @@
+package structures
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ShopItemType string
+
+const (
+	// ShopItemTypeRole gives the buyer a role, it is removed again after the duration of the item.
+	ShopItemTypeRole ShopItemType = "role"
+	// ShopItemTypeRoleColor changes the color of the role of the item to one picked by the buyer and gives them the role.
+	ShopItemTypeRoleColor ShopItemType = "role_color"
+	// ShopItemTypeNickname changes the nickname of the buyer once a moderator approves it.
+	ShopItemTypeNickname ShopItemType = "nickname"
+	// ShopItemTypeRequest is a request for the stream which moderators handle by hand.
+	ShopItemTypeRequest ShopItemType = "request"
+)
+
+// ShopItem is something members can buy with their points, names are unique per guild.
+type ShopItem struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	GuildID     string             `bson:"guild_id"`
+	Name        string             `bson:"name"`
+	Description string             `bson:"description"`
+	Type        ShopItemType       `bson:"type"`
+	Price       int32              `bson:"price"`
+	RoleID      string             `bson:"role_id,omitempty"`
+	// Duration is how long a grant lasts, 0 keeps it forever.
+	Duration time.Duration `bson:"duration"`
+	// Stock is how many times the item can still be bought, a negative stock is unlimited.
+	Stock int32 `bson:"stock"`
+	// UserLimit is how many times a member can buy the item, 0 is unlimited.
+	UserLimit int32     `bson:"user_limit"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+type ShopRedemptionStatus string
+
+const (
+	// ShopRedemptionStatusPending waits for a moderator.
+	ShopRedemptionStatusPending ShopRedemptionStatus = "pending"
+	// ShopRedemptionStatusActive is granted and will be revoked at ExpiresAt.
+	ShopRedemptionStatusActive    ShopRedemptionStatus = "active"
+	ShopRedemptionStatusCompleted ShopRedemptionStatus = "completed"
+	ShopRedemptionStatusExpired   ShopRedemptionStatus = "expired"
+	// ShopRedemptionStatusRejected was refunded.
+	ShopRedemptionStatusRejected ShopRedemptionStatus = "rejected"
+)
+
+// ShopRedemption is a purchase of a shop item, the item is copied so that later changes to it do not affect the purchase.
+type ShopRedemption struct {
+	ID      primitive.ObjectID   `bson:"_id,omitempty"`
+	GuildID string               `bson:"guild_id"`
+	UserID  string               `bson:"user_id"`
+	Item    ShopItem             `bson:"item"`
+	Status  ShopRedemptionStatus `bson:"status"`
+	// Input is what the buyer asked for, the color, nickname or request.
+	Input string `bson:"input,omitempty"`
+	// Previous is the nickname the buyer had before, it is restored once the grant expires.
+	Previous   string    `bson:"previous,omitempty"`
+	ExpiresAt  time.Time `bson:"expires_at,omitempty"`
+	ResolvedBy string    `bson:"resolved_by,omitempty"`
+	Reason     string    `bson:"reason,omitempty"`
+	CreatedAt  time.Time `bson:"created_at"`
+	UpdatedAt  time.Time `bson:"updated_at"`
+}
@@ -1,6 +1,10 @@
 package structures
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type User struct {
 	ID primitive.ObjectID `bson:"_id,omitempty"`
@@ -28,10 +32,15 @@ type UserTwitch struct {
 	Name string `bson:"name,omitempty"`
 }
 
+// UserModules is the data of modules which is shared between guilds, guild scoped data is stored in Member.
 type UserModules struct {
-	Points UserModulesPoints `bson:"points"`
+	Inhouse UserModulesInhouse `bson:"inhouse,omitempty"`
 }
 
-type UserModulesPoints struct {
-	Points int32 `bson:"points"`
+type UserModulesInhouse struct {
+	// Rating is the elo of the user in inhouse matches, it is 0 until they played a match.
+	Rating    int32     `bson:"rating,omitempty"`
+	Wins      int32     `bson:"wins,omitempty"`
+	Losses    int32     `bson:"losses,omitempty"`
+	LastMatch time.Time `bson:"last_match,omitempty"`
 }
@@ -0,0 +1,242 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// loadAliases loads the aliases which were added at runtime.
+func (d *discordInstsnce) loadAliases() error {
+	if d.gCtx.Inst().Mongo == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(d.gCtx, time.Second*10)
+	defer cancel()
+
+	cur, err := d.gCtx.Inst().Mongo.Collection(mongo.CollectionNameCommandAliases).Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+
+	aliases := []structures.CommandAlias{}
+	if err = cur.All(ctx, &aliases); err != nil {
+		return err
+	}
+
+	d.cmdsMtx.Lock()
+	defer d.cmdsMtx.Unlock()
+
+	for _, v := range aliases {
+		if d.customAliases[v.GuildID] == nil {
+			d.customAliases[v.GuildID] = map[string][]string{}
+		}
+		d.customAliases[v.GuildID][v.Alias] = strings.Fields(v.Command)
+	}
+
+	return nil
+}
+
+// aliasesOf returns every alias of a guild which expands to exactly path.
+func (d *discordInstsnce) aliasesOf(guildID string, path []string) []string {
+	d.cmdsMtx.Lock()
+	defer d.cmdsMtx.Unlock()
+
+	target := strings.Join(path, " ")
+	aliases := []string{}
+	for _, mp := range []map[string][]string{d.aliases, d.customAliases[guildID]} {
+		for alias, v := range mp {
+			if strings.Join(v, " ") == target {
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+	sort.Strings(aliases)
+
+	return aliases
+}
+
+func (d *discordInstsnce) aliasCmd() command.Cmd {
+	return &command.CommandGroup{
+		NameCmd: func() string {
+			return "alias"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "alias")
+		},
+		Info:        "Manage the aliases of commands",
+		UsageInfo:   "<command>",
+		ExampleInfo: []string{"alias add join inhouse join"},
+		Perms:       command.AdminPermission,
+		Commands: map[string]command.Cmd{
+			"add":    d.aliasAddCmd(),
+			"remove": d.aliasRemoveCmd(),
+			"list":   d.aliasListCmd(),
+		},
+	}
+}
+
+func (d *discordInstsnce) aliasAddCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "alias add"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "add")
+		},
+		Info:        "Add an alias for a command",
+		ExampleInfo: []string{"alias add join inhouse join", "alias add pts points"},
+		Args: []command.Arg{
+			{
+				Name:        "alias",
+				Description: "The name of the alias",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+			{
+				Name:        "command",
+				Description: "The command the alias runs, can be a sub command",
+				Type:        command.ArgText,
+				Required:    true,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			alias := strings.ToLower(ctx.Args.String("alias"))
+			target := strings.Fields(strings.ToLower(ctx.Args.String("command")))
+
+			d.cmdsMtx.Lock()
+			_, isCmd := d.cmds[alias]
+			_, isAlias := d.aliases[alias]
+			_, isCustom := d.customAliases[ctx.GuildID][alias]
+			cmd := d.cmds[target[0]]
+			d.cmdsMtx.Unlock()
+
+			if isCmd || isAlias || isCustom {
+				return ctx.ReplyError(fmt.Sprintf("`%s` is already a command or alias.", alias))
+			}
+
+			// every part of the target has to be a command so that arguments are not baked into aliases.
+			if cmd == nil || command.Lookup(cmd, target[1:]) == nil {
+				return ctx.ReplyError(fmt.Sprintf("`%s` is not a command.", strings.Join(target, " ")))
+			}
+
+			_, err := d.gCtx.Inst().Mongo.Collection(mongo.CollectionNameCommandAliases).InsertOne(d.gCtx, structures.CommandAlias{
+				GuildID:   ctx.GuildID,
+				Alias:     alias,
+				Command:   strings.Join(target, " "),
+				CreatedBy: ctx.Author.ID,
+				CreatedAt: time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+
+			d.cmdsMtx.Lock()
+			if d.customAliases[ctx.GuildID] == nil {
+				d.customAliases[ctx.GuildID] = map[string][]string{}
+			}
+			d.customAliases[ctx.GuildID][alias] = target
+			d.cmdsMtx.Unlock()
+
+			_, err = ctx.Reply(fmt.Sprintf("Added `%s%s` as an alias for `%s%s`.", ctx.Prefix, alias, ctx.Prefix, strings.Join(target, " ")))
+			return err
+		},
+	}
+}
+
+func (d *discordInstsnce) aliasRemoveCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "alias remove"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "remove")
+		},
+		Info:        "Remove an alias which was added with alias add",
+		ExampleInfo: []string{"alias remove join"},
+		Args: []command.Arg{
+			{
+				Name:        "alias",
+				Description: "The name of the alias",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			alias := strings.ToLower(ctx.Args.String("alias"))
+
+			d.cmdsMtx.Lock()
+			_, ok := d.customAliases[ctx.GuildID][alias]
+			d.cmdsMtx.Unlock()
+			if !ok {
+				return ctx.ReplyError(fmt.Sprintf("`%s` is not an alias which can be removed.", alias))
+			}
+
+			_, err := d.gCtx.Inst().Mongo.Collection(mongo.CollectionNameCommandAliases).DeleteOne(d.gCtx, bson.M{
+				"guild_id": ctx.GuildID,
+				"alias":    alias,
+			})
+			if err != nil {
+				return err
+			}
+
+			d.cmdsMtx.Lock()
+			delete(d.customAliases[ctx.GuildID], alias)
+			d.cmdsMtx.Unlock()
+
+			_, err = ctx.Reply(fmt.Sprintf("Removed the alias `%s%s`.", ctx.Prefix, alias))
+			return err
+		},
+	}
+}
+
+func (d *discordInstsnce) aliasListCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "alias list"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "list")
+		},
+		Info:        "List the aliases of all commands",
+		ExampleInfo: []string{"alias list"},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			lines := []string{}
+
+			d.cmdsMtx.Lock()
+			for _, mp := range []map[string][]string{d.aliases, d.customAliases[ctx.GuildID]} {
+				for alias, v := range mp {
+					line := fmt.Sprintf("`%s%s` → `%s%s`", ctx.Prefix, alias, ctx.Prefix, strings.Join(v, " "))
+					if _, ok := d.customAliases[ctx.GuildID][alias]; !ok {
+						line += " (built in)"
+					}
+					lines = append(lines, line)
+				}
+			}
+			d.cmdsMtx.Unlock()
+
+			sort.Strings(lines)
+			if len(lines) == 0 {
+				lines = append(lines, "There are no aliases.")
+			}
+
+			_, err := ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:       "Aliases",
+					Description: truncateLines(lines, 4096),
+					Color:       ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID),
+				},
+			})
+			return err
+		},
+	}
+}
@@ -0,0 +1,522 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type ArgType int
+
+const (
+	// ArgString is a single word, quotes have to be used for values with spaces.
+	ArgString ArgType = iota
+	// ArgText takes all remaining words.
+	ArgText
+	// ArgMember is a mention, id or name of a member, it takes every word which the arguments after it cannot use.
+	ArgMember
+	ArgRole
+	ArgChannel
+	ArgInteger
+	// ArgDuration is a go duration which also allows days and weeks, for example 1d12h.
+	ArgDuration
+	// ArgMatchID is a dota match id or a link to a match.
+	ArgMatchID
+	// ArgAttachment is a file, text commands take the attachments of the message in order instead of words.
+	ArgAttachment
+)
+
+// Arg is a typed argument of a command, text and slash commands are parsed into the same Args.
+type Arg struct {
+	Name        string
+	Description string
+	Type        ArgType
+	Required    bool
+	// Variadic takes all remaining words and parses each of them, it has to be the last argument.
+	Variadic bool
+}
+
+// UsageError is returned when the arguments of a command are invalid, the command handler replies with the usage of the command.
+type UsageError struct {
+	Reason string
+}
+
+func (e *UsageError) Error() string {
+	if e.Reason == "" {
+		return "invalid usage"
+	}
+
+	return "invalid usage: " + e.Reason
+}
+
+func usageErrorf(format string, a ...interface{}) error {
+	return &UsageError{Reason: fmt.Sprintf(format, a...)}
+}
+
+// Args are the parsed arguments of a command.
+type Args struct {
+	values map[string]interface{}
+}
+
+func (a Args) Has(name string) bool {
+	_, ok := a.values[name]
+	return ok
+}
+
+func (a Args) String(name string) string {
+	v, _ := a.values[name].(string)
+	return v
+}
+
+func (a Args) Int(name string) int64 {
+	v, _ := a.values[name].(int64)
+	return v
+}
+
+func (a Args) Duration(name string) time.Duration {
+	v, _ := a.values[name].(time.Duration)
+	return v
+}
+
+func (a Args) MatchID(name string) uint64 {
+	v, _ := a.values[name].(uint64)
+	return v
+}
+
+func (a Args) Member(name string) *discordgo.Member {
+	v, _ := a.values[name].(*discordgo.Member)
+	return v
+}
+
+func (a Args) Role(name string) *discordgo.Role {
+	v, _ := a.values[name].(*discordgo.Role)
+	return v
+}
+
+func (a Args) Channel(name string) *discordgo.Channel {
+	v, _ := a.values[name].(*discordgo.Channel)
+	return v
+}
+
+func (a Args) Attachment(name string) *discordgo.MessageAttachment {
+	v, _ := a.values[name].(*discordgo.MessageAttachment)
+	return v
+}
+
+// List returns the values of a variadic argument.
+func (a Args) List(name string) []interface{} {
+	v, _ := a.values[name].([]interface{})
+	return v
+}
+
+func (a Args) MatchIDs(name string) []uint64 {
+	list := a.List(name)
+	ids := make([]uint64, 0, len(list))
+	for _, v := range list {
+		if id, ok := v.(uint64); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// Split splits the content of a message into words, words in double quotes are kept together.
+func Split(content string) []string {
+	words := []string{}
+	buf := strings.Builder{}
+	quoted := false
+	started := false
+
+	for _, r := range content {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case unicode.IsSpace(r) && !quoted:
+			if started {
+				words = append(words, buf.String())
+				buf.Reset()
+				started = false
+			}
+		default:
+			buf.WriteRune(r)
+			started = true
+		}
+	}
+
+	if started {
+		words = append(words, buf.String())
+	}
+
+	return words
+}
+
+// Usage formats args the way they are shown in help, for example `<user> <points>`.
+func Usage(args []Arg) string {
+	parts := make([]string, len(args))
+	for i, v := range args {
+		name := v.Name
+		if v.Variadic || v.Type == ArgText {
+			name += " ..."
+		}
+
+		if v.Required {
+			parts[i] = "<" + name + ">"
+		} else {
+			parts[i] = "[" + name + "]"
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// ParseArgs parses path into args, interactions are parsed from their options instead.
+func ParseArgs(ctx *Context, args []Arg, path []string) (Args, error) {
+	var raw map[string]string
+	if ctx.Interaction != nil {
+		raw = interactionValues(ctx.Interaction.ApplicationCommandData().Options)
+	} else {
+		raw = assignWords(args, path)
+		assignAttachments(ctx, args, raw)
+	}
+
+	parsed := Args{values: map[string]interface{}{}}
+	for _, arg := range args {
+		value, ok := raw[arg.Name]
+		if !ok || strings.TrimSpace(value) == "" {
+			if arg.Required {
+				return parsed, usageErrorf("`%s` is missing", arg.Name)
+			}
+			continue
+		}
+
+		if !arg.Variadic {
+			v, err := parseArg(ctx, arg, value)
+			if err != nil {
+				return parsed, err
+			}
+			parsed.values[arg.Name] = v
+			continue
+		}
+
+		list := []interface{}{}
+		for _, word := range Split(value) {
+			v, err := parseArg(ctx, arg, word)
+			if err != nil {
+				return parsed, err
+			}
+			list = append(list, v)
+		}
+		parsed.values[arg.Name] = list
+	}
+
+	return parsed, nil
+}
+
+// assignWords hands out the words to args, members and text take the words which are not needed by the args after them.
+func assignWords(args []Arg, words []string) map[string]string {
+	raw := map[string]string{}
+	for i, arg := range args {
+		if len(words) == 0 {
+			break
+		}
+		if arg.Type == ArgAttachment {
+			continue
+		}
+
+		n := 1
+		switch {
+		case arg.Variadic || arg.Type == ArgText:
+			n = len(words)
+		case arg.Type == ArgMember:
+			// the args after a member take the last words, optional ones only when the word fits their type.
+			rest := words[1:]
+			for j := len(args) - 1; j > i && len(rest) != 0; j-- {
+				next := args[j]
+				if next.Type == ArgAttachment || (!next.Required && !fitsWord(next, rest[len(rest)-1])) {
+					continue
+				}
+
+				raw[next.Name] = rest[len(rest)-1]
+				rest = rest[:len(rest)-1]
+			}
+
+			raw[arg.Name] = strings.Join(words[:len(rest)+1], " ")
+			return raw
+		}
+
+		raw[arg.Name] = strings.Join(words[:n], " ")
+		words = words[n:]
+	}
+
+	return raw
+}
+
+// assignAttachments hands out the attachments of a message to the attachment args by their id.
+func assignAttachments(ctx *Context, args []Arg, raw map[string]string) {
+	if ctx.Message == nil {
+		return
+	}
+
+	attachments := ctx.Message.Attachments
+	for _, arg := range args {
+		if arg.Type != ArgAttachment || len(attachments) == 0 {
+			continue
+		}
+
+		raw[arg.Name] = attachments[0].ID
+		attachments = attachments[1:]
+	}
+}
+
+// fitsWord is true when word could be a value of arg, names of roles and channels only fit when they are mentions or ids.
+func fitsWord(arg Arg, word string) bool {
+	switch arg.Type {
+	case ArgInteger:
+		_, err := strconv.ParseInt(word, 10, 64)
+		return err == nil
+	case ArgDuration:
+		d, err := ParseDuration(word)
+		return err == nil && d > 0
+	case ArgMatchID:
+		return matchIDRegex.MatchString(word)
+	case ArgRole:
+		return roleMentionRegex.MatchString(word) || snowflakeRegex.MatchString(word)
+	case ArgChannel:
+		return channelMentionRegex.MatchString(word) || snowflakeRegex.MatchString(word)
+	}
+
+	return true
+}
+
+func interactionValues(opts []*discordgo.ApplicationCommandInteractionDataOption) map[string]string {
+	raw := map[string]string{}
+	for _, v := range opts {
+		switch v.Type {
+		case discordgo.ApplicationCommandOptionSubCommand, discordgo.ApplicationCommandOptionSubCommandGroup:
+			return interactionValues(v.Options)
+		case discordgo.ApplicationCommandOptionInteger:
+			raw[v.Name] = strconv.FormatInt(v.IntValue(), 10)
+		default:
+			raw[v.Name] = fmt.Sprint(v.Value)
+		}
+	}
+
+	return raw
+}
+
+const memberChoiceTimeout = time.Second * 30
+
+var (
+	userMentionRegex    = regexp.MustCompile(`^<@!?(\d+)>$`)
+	roleMentionRegex    = regexp.MustCompile(`^<@&(\d+)>$`)
+	channelMentionRegex = regexp.MustCompile(`^<#(\d+)>$`)
+	matchIDRegex        = regexp.MustCompile(`^(?:https?://\S+/matches/)?(\d+)/?$`)
+	durationRegex       = regexp.MustCompile(`(\d+)([dw])`)
+)
+
+func parseArg(ctx *Context, arg Arg, value string) (interface{}, error) {
+	value = strings.TrimSpace(value)
+
+	switch arg.Type {
+	case ArgInteger:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, usageErrorf("`%s` has to be a number", arg.Name)
+		}
+		return v, nil
+	case ArgDuration:
+		v, err := ParseDuration(value)
+		if err != nil || v <= 0 {
+			return nil, usageErrorf("`%s` has to be a duration like 30m, 12h or 7d", arg.Name)
+		}
+		return v, nil
+	case ArgMatchID:
+		match := matchIDRegex.FindStringSubmatch(value)
+		if match == nil {
+			return nil, usageErrorf("`%s` is not a valid match id", value)
+		}
+		v, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, usageErrorf("`%s` is not a valid match id", value)
+		}
+		return v, nil
+	case ArgMember:
+		return chooseMember(ctx, value)
+	case ArgRole:
+		if role := findRole(ctx, value); role != nil {
+			return role, nil
+		}
+		return nil, usageErrorf("Couldn't find a role matching `%s`", value)
+	case ArgChannel:
+		if channel := findChannel(ctx, value); channel != nil {
+			return channel, nil
+		}
+		return nil, usageErrorf("Couldn't find a channel matching `%s`", value)
+	case ArgAttachment:
+		if attachment := findAttachment(ctx, value); attachment != nil {
+			return attachment, nil
+		}
+		return nil, usageErrorf("`%s` has to be a file", arg.Name)
+	}
+
+	return value, nil
+}
+
+// ParseDuration parses a go duration which can also contain days and weeks.
+func ParseDuration(value string) (time.Duration, error) {
+	var extra time.Duration
+	value = durationRegex.ReplaceAllStringFunc(strings.ToLower(value), func(s string) string {
+		match := durationRegex.FindStringSubmatch(s)
+		n, _ := strconv.Atoi(match[1])
+		switch match[2] {
+		case "d":
+			extra += time.Duration(n) * time.Hour * 24
+		case "w":
+			extra += time.Duration(n) * time.Hour * 24 * 7
+		}
+		return ""
+	})
+
+	if value == "" {
+		return extra, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	return d + extra, err
+}
+
+// chooseMember resolves value to a member, the invoker is asked which one they meant when it matches several.
+func chooseMember(ctx *Context, value string) (*discordgo.Member, error) {
+	members, err := ResolveMembers(ctx.Session, ctx.GuildID, value)
+	if err != nil {
+		Log(ctx).Warn("failed to search members: ", err)
+	}
+
+	switch {
+	case len(members) == 0:
+		return nil, usageErrorf("Couldn't find a member matching `%s`", value)
+	case len(members) == 1:
+		return members[0], nil
+	case len(members) > len(ChoiceEmojis):
+		return nil, usageErrorf("`%s` matches %d members, be more specific", value, len(members))
+	}
+
+	choices := make([]string, len(members))
+	for i, v := range members {
+		choices[i] = MemberLabel(v)
+	}
+
+	choice, err := ctx.Choose(fmt.Sprintf("`%s` matches several members, which one do you mean?", value), choices, memberChoiceTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if choice == -1 {
+		return nil, usageErrorf("No member was chosen for `%s`", value)
+	}
+
+	return members[choice], nil
+}
+
+func findRole(ctx *Context, value string) *discordgo.Role {
+	if match := roleMentionRegex.FindStringSubmatch(value); match != nil {
+		value = match[1]
+	}
+
+	guild, err := ctx.Session.State.Guild(ctx.GuildID)
+	if err != nil {
+		return nil
+	}
+
+	for _, v := range guild.Roles {
+		if v.ID == value || strings.EqualFold(v.Name, value) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func findChannel(ctx *Context, value string) *discordgo.Channel {
+	if match := channelMentionRegex.FindStringSubmatch(value); match != nil {
+		value = match[1]
+	}
+
+	guild, err := ctx.Session.State.Guild(ctx.GuildID)
+	if err != nil {
+		return nil
+	}
+
+	value = strings.TrimPrefix(value, "#")
+	for _, v := range guild.Channels {
+		if v.ID == value || strings.EqualFold(v.Name, value) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func findAttachment(ctx *Context, id string) *discordgo.MessageAttachment {
+	if ctx.Interaction != nil {
+		resolved := ctx.Interaction.ApplicationCommandData().Resolved
+		if resolved == nil {
+			return nil
+		}
+		return resolved.Attachments[id]
+	}
+
+	for _, v := range ctx.Message.Attachments {
+		if v.ID == id {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// option converts arg into the option of a slash command.
+func (a Arg) option() *discordgo.ApplicationCommandOption {
+	opt := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        a.Name,
+		Description: truncateDescription(a.Name, a.Description),
+		Required:    a.Required,
+	}
+
+	if a.Variadic {
+		// multiple values are passed space separated in a single string.
+		return opt
+	}
+
+	switch a.Type {
+	case ArgMember:
+		// members are searched the same way as text commands instead of using the user option.
+		opt.Autocomplete = true
+	case ArgRole:
+		opt.Type = discordgo.ApplicationCommandOptionRole
+	case ArgChannel:
+		opt.Type = discordgo.ApplicationCommandOptionChannel
+	case ArgInteger:
+		opt.Type = discordgo.ApplicationCommandOptionInteger
+	case ArgAttachment:
+		opt.Type = discordgo.ApplicationCommandOptionAttachment
+	}
+
+	return opt
+}
+
+func options(args []Arg) []*discordgo.ApplicationCommandOption {
+	opts := make([]*discordgo.ApplicationCommandOption, len(args))
+	for i, v := range args {
+		opts[i] = v.option()
+	}
+
+	return opts
+}
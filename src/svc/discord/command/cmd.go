@@ -2,6 +2,7 @@ package command
 
 import (
 	"errors"
+	"sort"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -14,26 +15,109 @@ var (
 
 type Cmd interface {
 	Name() string
+	Aliases() []string
+	Description() string
+	Usage() string
+	Examples() []string
+	Options() []*discordgo.ApplicationCommandOption
+	Permission() Permission
+	Cooldown(guildID string) Cooldown
+	Enabled(guildID string) bool
 	Match(path []string) bool
-	Execute(s *discordgo.Session, m *discordgo.MessageCreate, path []string) error
+	Execute(ctx *Context, path []string) error
+}
+
+// FormatUsage formats how cmd is invoked, for example `!add-points <user> <points>`.
+func FormatUsage(prefix string, cmd Cmd) string {
+	usage := prefix + cmd.Name()
+	if cmd.Usage() != "" {
+		usage += " " + cmd.Usage()
+	}
+
+	return usage
 }
 
 type Command struct {
 	NameCmd    func() string
 	MatchCmd   func(path []string) bool
-	ExecuteCmd func(s *discordgo.Session, m *discordgo.MessageCreate, path []string) error
+	ExecuteCmd func(ctx *Context, path []string) error
+
+	// AliasNames are other names a top level command can be invoked with, slash commands only use the name.
+	AliasNames []string
+	// Info is shown as the description of the slash command and in help.
+	Info string
+	// UsageInfo overrides the usage generated from Args.
+	UsageInfo string
+	// ExampleInfo are invocations of the command without the prefix, for example `add-points Troy 100`.
+	ExampleInfo []string
+	// Args are parsed into ctx.Args before the command is executed, they are also the options of the slash command.
+	Args []Arg
+	// Perms is checked before the command is executed.
+	Perms Permission
+	// Cooldowns limit how often the command can be used.
+	Cooldowns Cooldown
+	// CooldownsCmd overrides Cooldowns for cooldowns which are configured per guild.
+	CooldownsCmd func(guildID string) Cooldown
+	// EnabledCmd reports if the command can be used in a guild, commands without it can be used everywhere.
+	EnabledCmd func(guildID string) bool
 }
 
 func (c *Command) Name() string {
 	return c.NameCmd()
 }
 
+func (c *Command) Aliases() []string {
+	return c.AliasNames
+}
+
+func (c *Command) Description() string {
+	return c.Info
+}
+
+func (c *Command) Usage() string {
+	if c.UsageInfo != "" {
+		return c.UsageInfo
+	}
+
+	return Usage(c.Args)
+}
+
+func (c *Command) Examples() []string {
+	return c.ExampleInfo
+}
+
+func (c *Command) Options() []*discordgo.ApplicationCommandOption {
+	return options(c.Args)
+}
+
+func (c *Command) Permission() Permission {
+	return c.Perms
+}
+
+func (c *Command) Cooldown(guildID string) Cooldown {
+	if c.CooldownsCmd != nil {
+		return c.CooldownsCmd(guildID)
+	}
+
+	return c.Cooldowns
+}
+
+func (c *Command) Enabled(guildID string) bool {
+	return c.EnabledCmd == nil || c.EnabledCmd(guildID)
+}
+
 func (c *Command) Match(path []string) bool {
 	return c.MatchCmd(path)
 }
 
-func (c *Command) Execute(s *discordgo.Session, m *discordgo.MessageCreate, path []string) error {
-	return c.ExecuteCmd(s, m, path)
+func (c *Command) Execute(ctx *Context, path []string) error {
+	args, err := ParseArgs(ctx, c.Args, path)
+	if err != nil {
+		return err
+	}
+
+	ctx.Args = args
+	return c.ExecuteCmd(ctx, path)
 }
 
 type CommandGroup struct {
@@ -41,17 +125,84 @@ type CommandGroup struct {
 	DefaultComnmnd Cmd
 	MatchCmd       func(path []string) bool
 	NameCmd        func() string
+
+	AliasNames  []string
+	Info        string
+	UsageInfo   string
+	ExampleInfo []string
+	Perms       Permission
+	// Cooldowns of a group are not enforced, only the ones of the executed command are.
+	Cooldowns  Cooldown
+	EnabledCmd func(guildID string) bool
 }
 
 func (c *CommandGroup) Name() string {
 	return c.NameCmd()
 }
 
+func (c *CommandGroup) Aliases() []string {
+	return c.AliasNames
+}
+
+func (c *CommandGroup) Description() string {
+	return c.Info
+}
+
+func (c *CommandGroup) Usage() string {
+	return c.UsageInfo
+}
+
+func (c *CommandGroup) Examples() []string {
+	return c.ExampleInfo
+}
+
+func (c *CommandGroup) Options() []*discordgo.ApplicationCommandOption {
+	names := make([]string, 0, len(c.Commands))
+	for name := range c.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	opts := make([]*discordgo.ApplicationCommandOption, 0, len(names))
+	for _, name := range names {
+		cmd := c.Commands[name]
+		if cmd == nil {
+			continue
+		}
+
+		typ := discordgo.ApplicationCommandOptionSubCommand
+		if _, ok := cmd.(*CommandGroup); ok {
+			typ = discordgo.ApplicationCommandOptionSubCommandGroup
+		}
+
+		opts = append(opts, &discordgo.ApplicationCommandOption{
+			Type:        typ,
+			Name:        name,
+			Description: description(name, cmd),
+			Options:     cmd.Options(),
+		})
+	}
+
+	return opts
+}
+
+func (c *CommandGroup) Permission() Permission {
+	return c.Perms
+}
+
+func (c *CommandGroup) Cooldown(guildID string) Cooldown {
+	return c.Cooldowns
+}
+
+func (c *CommandGroup) Enabled(guildID string) bool {
+	return c.EnabledCmd == nil || c.EnabledCmd(guildID)
+}
+
 func (c *CommandGroup) Match(path []string) bool {
 	return c.MatchCmd(path)
 }
 
-func (c *CommandGroup) Execute(s *discordgo.Session, m *discordgo.MessageCreate, path []string) error {
+func (c *CommandGroup) Execute(ctx *Context, path []string) error {
 	next := ""
 	if len(path) != 0 {
 		next = path[0]
@@ -62,16 +213,31 @@ func (c *CommandGroup) Execute(s *discordgo.Session, m *discordgo.MessageCreate,
 			if len(path) != 0 {
 				path = path[1:]
 			}
-			return cmd.Execute(s, m, path)
+			return cmd.Execute(ctx, path)
 		}
 	} else if c.DefaultComnmnd != nil {
+		// the default command gets the whole path, it is made of its arguments.
 		if c.DefaultComnmnd.Match(path) {
-			if len(path) != 0 {
-				path = path[1:]
-			}
-			return cmd.Execute(s, m, path)
+			return c.DefaultComnmnd.Execute(ctx, path)
 		}
 	}
 
 	return nil
 }
+
+// Registry is where commands are registered, instance.Discord implements it.
+type Registry interface {
+	RegisterCommand(prefix string, cmd Cmd) error
+	DeregisterCommand(prefix string, cmd Cmd) error
+}
+
+// Register registers cmd and returns a func which deregisters it again, like the func returned by AddHandler.
+func Register(registry Registry, prefix string, cmd Cmd) (func(), error) {
+	if err := registry.RegisterCommand(prefix, cmd); err != nil {
+		return func() {}, err
+	}
+
+	return func() {
+		_ = registry.DeregisterCommand(prefix, cmd)
+	}, nil
+}
@@ -0,0 +1,121 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	ConfirmEmoji = "✅"
+	CancelEmoji  = "❌"
+)
+
+// ChoiceEmojis are the reactions of the choices of Choose, so there can be at most 9 choices.
+var ChoiceEmojis = []string{"1️⃣", "2️⃣", "3️⃣", "4️⃣", "5️⃣", "6️⃣", "7️⃣", "8️⃣", "9️⃣"}
+
+// Confirm replies with prompt and waits for the invoker to react to it.
+// it returns false when the invoker cancels or does not react within timeout.
+func (c *Context) Confirm(prompt string, timeout time.Duration) (bool, error) {
+	msg, err := c.Reply(prompt)
+	if err != nil {
+		return false, err
+	}
+
+	reactions := make(chan string, 1)
+	remove := c.Session.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+		if r.MessageID != msg.ID || r.UserID != c.Author.ID {
+			return
+		}
+
+		if r.Emoji.Name == ConfirmEmoji || r.Emoji.Name == CancelEmoji {
+			select {
+			case reactions <- r.Emoji.Name:
+			default:
+			}
+		}
+	})
+	defer remove()
+
+	for _, emoji := range []string{ConfirmEmoji, CancelEmoji} {
+		if err = c.Session.MessageReactionAdd(msg.ChannelID, msg.ID, emoji); err != nil {
+			return false, err
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	confirmed := false
+	select {
+	case emoji := <-reactions:
+		confirmed = emoji == ConfirmEmoji
+	case <-timer.C:
+	}
+
+	_ = c.Session.MessageReactionsRemoveAll(msg.ChannelID, msg.ID)
+
+	return confirmed, nil
+}
+
+// Choose replies with prompt followed by the numbered choices and waits for the invoker to react with one of them.
+// it returns the index of the choice, or -1 when the invoker cancels or does not react within timeout.
+func (c *Context) Choose(prompt string, choices []string, timeout time.Duration) (int, error) {
+	if len(choices) > len(ChoiceEmojis) {
+		choices = choices[:len(ChoiceEmojis)]
+	}
+
+	lines := []string{prompt}
+	for i, v := range choices {
+		lines = append(lines, fmt.Sprintf("%s %s", ChoiceEmojis[i], v))
+	}
+
+	msg, err := c.Reply(strings.Join(lines, "\n"))
+	if err != nil {
+		return -1, err
+	}
+
+	emojis := append(append([]string{}, ChoiceEmojis[:len(choices)]...), CancelEmoji)
+	reactions := make(chan int, 1)
+	remove := c.Session.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+		if r.MessageID != msg.ID || r.UserID != c.Author.ID {
+			return
+		}
+
+		for i, v := range emojis {
+			if r.Emoji.Name != v {
+				continue
+			}
+
+			if v == CancelEmoji {
+				i = -1
+			}
+			select {
+			case reactions <- i:
+			default:
+			}
+		}
+	})
+	defer remove()
+
+	for _, emoji := range emojis {
+		if err = c.Session.MessageReactionAdd(msg.ChannelID, msg.ID, emoji); err != nil {
+			return -1, err
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	choice := -1
+	select {
+	case choice = <-reactions:
+	case <-timer.C:
+	}
+
+	_ = c.Session.MessageReactionsRemoveAll(msg.ChannelID, msg.ID)
+
+	return choice, nil
+}
@@ -0,0 +1,259 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/utils"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Context is what a command is executed with, it is either backed by a text message or a slash command interaction.
+type Context struct {
+	Session     *discordgo.Session
+	Message     *discordgo.Message
+	Interaction *discordgo.Interaction
+
+	GuildID   string
+	ChannelID string
+	Author    *discordgo.User
+	Member    *discordgo.Member
+
+	// Prefix is what the command was invoked with, slash commands use "/".
+	Prefix string
+	// ID is the correlation id of the execution, it is part of every log line and shown to the invoker on errors.
+	ID string
+	// Chain is the command followed by the sub commands which are being executed.
+	Chain []Cmd
+	// Command is the command or sub command which is being executed, the last entry of Chain.
+	Command Cmd
+	// Args are the parsed arguments of the command.
+	Args Args
+
+	mtx      sync.Mutex
+	replied  bool
+	deferred bool
+}
+
+func NewMessageContext(s *discordgo.Session, m *discordgo.MessageCreate, prefix string) *Context {
+	if m.Member != nil && m.Member.User == nil {
+		m.Member.User = m.Author
+	}
+
+	return &Context{
+		Session:   s,
+		Message:   m.Message,
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		Author:    m.Author,
+		Member:    m.Member,
+		Prefix:    prefix,
+		ID:        newID(),
+	}
+}
+
+func NewInteractionContext(s *discordgo.Session, i *discordgo.InteractionCreate) *Context {
+	ctx := &Context{
+		Session:     s,
+		Interaction: i.Interaction,
+		GuildID:     i.GuildID,
+		ChannelID:   i.ChannelID,
+		Member:      i.Member,
+		Author:      i.User,
+		Prefix:      "/",
+		ID:          newID(),
+	}
+	if i.Member != nil {
+		ctx.Author = i.Member.User
+	}
+
+	return ctx
+}
+
+func newID() string {
+	return strconv.FormatInt(utils.NewID(), 36)
+}
+
+func (c *Context) IsInteraction() bool {
+	return c.Interaction != nil
+}
+
+// Mentions are the users mentioned in a text command, interactions pass users as ids instead.
+func (c *Context) Mentions() []*discordgo.User {
+	if c.Message == nil {
+		return nil
+	}
+
+	return c.Message.Mentions
+}
+
+// Permissions are the permissions of the invoker in the channel the command was used in.
+func (c *Context) Permissions() (int64, error) {
+	if c.Interaction != nil {
+		if c.Member == nil {
+			return 0, discordgo.ErrMessageIncompletePermissions
+		}
+
+		return c.Member.Permissions, nil
+	}
+
+	return c.Session.State.MessagePermissions(c.Message)
+}
+
+func (c *Context) Replied() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.replied
+}
+
+// Defer acknowledges an interaction so that commands which take longer than 3 seconds can still reply.
+func (c *Context) Defer() error {
+	if c.Interaction == nil {
+		return nil
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.replied || c.deferred {
+		return nil
+	}
+
+	if err := c.Session.InteractionRespond(c.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	}); err != nil {
+		return err
+	}
+
+	c.deferred = true
+	return nil
+}
+
+func (c *Context) Reply(content string) (*discordgo.Message, error) {
+	return c.ReplyComplex(&discordgo.MessageSend{
+		Content: content,
+	})
+}
+
+func (c *Context) ReplyComplex(data *discordgo.MessageSend) (*discordgo.Message, error) {
+	if c.Interaction != nil {
+		return c.respond(data, 0)
+	}
+
+	if data.Reference == nil {
+		data.Reference = c.Message.Reference()
+	}
+
+	return c.Session.ChannelMessageSendComplex(c.ChannelID, data)
+}
+
+// Send posts a message to the channel without replying to the invoker.
+func (c *Context) Send(content string) (*discordgo.Message, error) {
+	if c.Interaction != nil {
+		return c.respond(&discordgo.MessageSend{
+			Content: content,
+		}, 0)
+	}
+
+	return c.Session.ChannelMessageSend(c.ChannelID, content)
+}
+
+// ReplyEphemeral replies with a message only the invoker can see, text commands have no such messages and reply normally.
+func (c *Context) ReplyEphemeral(data *discordgo.MessageSend) (*discordgo.Message, error) {
+	if c.Interaction != nil {
+		return c.respond(data, uint64(discordgo.MessageFlagsEphemeral))
+	}
+
+	return c.ReplyComplex(data)
+}
+
+// ReplyError replies with a message only the invoker can see, text commands have the reply cleaned up after 10 seconds.
+func (c *Context) ReplyError(content string) error {
+	if c.Interaction != nil {
+		_, err := c.respond(&discordgo.MessageSend{
+			Content: content,
+		}, uint64(discordgo.MessageFlagsEphemeral))
+		return err
+	}
+
+	st, err := c.Session.ChannelMessageSendReply(c.ChannelID, content, c.Message.Reference())
+	utils.CleanUpMessageDeny(c.Session, st, time.Second*10, c.Message.ID)
+	return err
+}
+
+// ReplyUsage tells the invoker how the command they tried to run is used, reason is what was wrong with the arguments.
+func (c *Context) ReplyUsage(reason string) error {
+	msg := "Invalid usage"
+	if c.Command != nil {
+		msg = fmt.Sprintf("Invalid usage: `%s`", FormatUsage(c.Prefix, c.Command))
+	}
+
+	if reason != "" {
+		msg += "\n" + reason
+	}
+
+	if c.Command != nil && len(c.Command.Examples()) != 0 {
+		msg += fmt.Sprintf("\nExample: `%s%s`", c.Prefix, c.Command.Examples()[0])
+	}
+
+	return c.ReplyError(msg)
+}
+
+func (c *Context) respond(data *discordgo.MessageSend, flags uint64) (*discordgo.Message, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	embeds := data.Embeds
+	if data.Embed != nil {
+		embeds = append([]*discordgo.MessageEmbed{data.Embed}, embeds...)
+	}
+
+	files := data.Files
+	if data.File != nil {
+		files = append([]*discordgo.File{data.File}, files...)
+	}
+
+	appID := c.Session.State.User.ID
+
+	switch {
+	case !c.replied && !c.deferred:
+		if err := c.Session.InteractionRespond(c.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content:         data.Content,
+				Embeds:          embeds,
+				Components:      data.Components,
+				Files:           files,
+				AllowedMentions: data.AllowedMentions,
+				Flags:           flags,
+			},
+		}); err != nil {
+			return nil, err
+		}
+
+		c.replied = true
+		return c.Session.InteractionResponse(appID, c.Interaction)
+	case !c.replied:
+		// the deferred response has to be edited, the ephemeral flag was decided when it was deferred.
+		c.replied = true
+		return c.Session.InteractionResponseEdit(appID, c.Interaction, &discordgo.WebhookEdit{
+			Content:         data.Content,
+			Embeds:          embeds,
+			Components:      data.Components,
+			Files:           files,
+			AllowedMentions: data.AllowedMentions,
+		})
+	default:
+		return c.Session.FollowupMessageCreate(appID, c.Interaction, true, &discordgo.WebhookParams{
+			Content:         data.Content,
+			Embeds:          embeds,
+			Components:      data.Components,
+			Files:           files,
+			AllowedMentions: data.AllowedMentions,
+			Flags:           flags,
+		})
+	}
+}
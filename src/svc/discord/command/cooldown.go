@@ -0,0 +1,140 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/utils"
+)
+
+// Cooldown limits how often a command can be used, every non zero duration is its own cooldown.
+type Cooldown struct {
+	// Global is shared by everyone.
+	Global time.Duration
+	// User is per invoker.
+	User time.Duration
+	// Channel is per channel the command is used in.
+	Channel time.Duration
+	// Roles override User for members with one of the roles, the shortest one applies.
+	Roles map[string]time.Duration
+	// Bypass are the roles which are not affected by the cooldown.
+	Bypass func(guildID string) []string
+}
+
+func (c Cooldown) empty() bool {
+	return c.Global == 0 && c.User == 0 && c.Channel == 0 && len(c.Roles) == 0
+}
+
+// CooldownStore is where cooldowns are kept, instance.Redis implements it.
+type CooldownStore interface {
+	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Del(ctx context.Context, key string) (int, error)
+}
+
+// CooldownMiddleware enforces the cooldown of ctx.Command, a cooldown is released again when the command fails.
+func CooldownMiddleware(store func() CooldownStore) Middleware {
+	return func(ctx *Context, next func() error) error {
+		if ctx.Command == nil {
+			return next()
+		}
+
+		cooldown := ctx.Command.Cooldown(ctx.GuildID)
+		if cooldown.empty() || cooldown.bypassed(ctx) {
+			return next()
+		}
+
+		keys, wait, err := cooldown.acquire(ctx, store(), fmt.Sprintf("cooldown:%s:%s", ctx.GuildID, ctx.Command.Name()))
+		if err != nil {
+			return err
+		}
+
+		if wait != 0 {
+			return ctx.ReplyError(fmt.Sprintf("This command is on cooldown, try again in %s.", wait.Round(time.Second)))
+		}
+
+		if err = next(); err != nil {
+			release(store(), keys)
+		}
+
+		return err
+	}
+}
+
+func (c Cooldown) bypassed(ctx *Context) bool {
+	if c.Bypass == nil || ctx.Member == nil {
+		return false
+	}
+
+	for _, v := range c.Bypass(ctx.GuildID) {
+		if utils.Contains(ctx.Member.Roles, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// durations returns the key suffix and duration of every cooldown which applies to the invoker.
+func (c Cooldown) durations(ctx *Context) map[string]time.Duration {
+	durations := map[string]time.Duration{}
+	if c.Global != 0 {
+		durations["global"] = c.Global
+	}
+	if c.Channel != 0 {
+		durations["channel:"+ctx.ChannelID] = c.Channel
+	}
+
+	user := c.User
+	if ctx.Member != nil {
+		for role, d := range c.Roles {
+			if utils.Contains(ctx.Member.Roles, role) && (user == 0 || d < user) {
+				user = d
+			}
+		}
+	}
+	if user != 0 {
+		durations["user:"+ctx.Author.ID] = user
+	}
+
+	return durations
+}
+
+// acquire sets every cooldown key, when one is already set the ones set so far are released and the remaining time is returned.
+func (c Cooldown) acquire(ctx *Context, store CooldownStore, prefix string) ([]string, time.Duration, error) {
+	keys := []string{}
+	for suffix, d := range c.durations(ctx) {
+		key := prefix + ":" + suffix
+
+		set, err := store.SetNX(context.Background(), key, "1", d)
+		if err != nil {
+			release(store, keys)
+			return nil, 0, err
+		}
+
+		if !set {
+			release(store, keys)
+
+			ttl, err := store.TTL(context.Background(), key)
+			if err != nil {
+				return nil, 0, err
+			}
+			if ttl < time.Second {
+				ttl = time.Second
+			}
+
+			return nil, ttl, nil
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, 0, nil
+}
+
+func release(store CooldownStore, keys []string) {
+	for _, v := range keys {
+		_, _ = store.Del(context.Background(), v)
+	}
+}
@@ -0,0 +1,130 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ApplicationCommand builds the slash command definition of a command registered under prefix.
+func ApplicationCommand(prefix string, cmd Cmd) *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Type:        discordgo.ChatApplicationCommand,
+		Name:        prefix,
+		Description: description(prefix, cmd),
+		Options:     cmd.Options(),
+	}
+}
+
+// InteractionPath converts the options of a slash command into the path a text command would have produced.
+// Values are ordered the way the command declared them, not the order discord sent them in.
+func InteractionPath(cmd Cmd, data discordgo.ApplicationCommandInteractionData) []string {
+	return append([]string{data.Name}, interactionPath(cmd.Options(), data.Options)...)
+}
+
+func interactionPath(defs []*discordgo.ApplicationCommandOption, opts []*discordgo.ApplicationCommandInteractionDataOption) []string {
+	values := map[string]*discordgo.ApplicationCommandInteractionDataOption{}
+	for _, v := range opts {
+		values[v.Name] = v
+	}
+
+	path := []string{}
+	for _, def := range defs {
+		v, ok := values[def.Name]
+		if !ok {
+			continue
+		}
+
+		switch v.Type {
+		case discordgo.ApplicationCommandOptionSubCommand, discordgo.ApplicationCommandOptionSubCommandGroup:
+			return append([]string{def.Name}, interactionPath(def.Options, v.Options)...)
+		case discordgo.ApplicationCommandOptionInteger:
+			path = append(path, strconv.FormatInt(v.IntValue(), 10))
+		case discordgo.ApplicationCommandOptionBoolean:
+			path = append(path, strconv.FormatBool(v.BoolValue()))
+		default:
+			path = append(path, fmt.Sprint(v.Value))
+		}
+	}
+
+	return path
+}
+
+// FocusedOption returns the option the user is currently typing in during autocomplete.
+func FocusedOption(opts []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, v := range opts {
+		if v.Focused {
+			return v
+		}
+		if focused := FocusedOption(v.Options); focused != nil {
+			return focused
+		}
+	}
+
+	return nil
+}
+
+// MemberChoices returns up to 25 members of the guild whose name or nickname contains search.
+func MemberChoices(s *discordgo.Session, guildID string, search string) []*discordgo.ApplicationCommandOptionChoice {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return []*discordgo.ApplicationCommandOptionChoice{}
+	}
+
+	search = strings.ToLower(strings.TrimSpace(search))
+
+	s.State.RLock()
+	members := []*discordgo.Member{}
+	for _, v := range guild.Members {
+		if v.User == nil || v.User.Bot {
+			continue
+		}
+
+		if search == "" || strings.Contains(strings.ToLower(v.User.Username+"#"+v.User.Discriminator), search) || (v.Nick != "" && strings.Contains(strings.ToLower(v.Nick), search)) {
+			members = append(members, v)
+		}
+	}
+	s.State.RUnlock()
+
+	sort.Slice(members, func(i, j int) bool {
+		return len(members[i].User.Username) < len(members[j].User.Username)
+	})
+	if len(members) > 25 {
+		members = members[:25]
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(members))
+	for i, v := range members {
+		name := v.User.String()
+		if v.Nick != "" {
+			name = fmt.Sprintf("%s (%s)", v.Nick, name)
+		}
+
+		choices[i] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: v.User.ID,
+		}
+	}
+
+	return choices
+}
+
+func description(name string, cmd Cmd) string {
+	return truncateDescription(name, cmd.Description())
+}
+
+// truncateDescription is desc or else name, cut to the 100 characters discord allows for descriptions.
+// it is cut by runes since discord rejects descriptions with a broken character.
+func truncateDescription(name string, desc string) string {
+	if desc == "" {
+		desc = name
+	}
+	if runes := []rune(desc); len(runes) > 100 {
+		desc = string(runes[:100])
+	}
+
+	return desc
+}
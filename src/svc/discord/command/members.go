@@ -0,0 +1,232 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const memberSearchLimit = 25
+
+var (
+	snowflakeRegex = regexp.MustCompile(`^\d{17,20}$`)
+	userTagRegex   = regexp.MustCompile(`^(.+)#(\d{4})$`)
+)
+
+// memberCandidate is a member with the global display name, which discordgo does not decode.
+type memberCandidate struct {
+	Member     *discordgo.Member
+	GlobalName string
+}
+
+// searchMember is a member returned by the member search of discord.
+type searchMember struct {
+	discordgo.Member
+	User *struct {
+		discordgo.User
+		GlobalName string `json:"global_name"`
+	} `json:"user"`
+}
+
+// ResolveMembers returns the members value could refer to, the best matches first.
+// it accepts mentions, ids, name#discriminator, usernames, display names and nicknames. exact matches are preferred
+// over members whose names start with or contain value, members missing from the state are searched through the api.
+func ResolveMembers(s *discordgo.Session, guildID string, value string) ([]*discordgo.Member, error) {
+	value = strings.TrimSpace(value)
+	if match := userMentionRegex.FindStringSubmatch(value); match != nil {
+		value = match[1]
+	}
+
+	if snowflakeRegex.MatchString(value) {
+		member, err := s.State.Member(guildID, value)
+		if err != nil {
+			member, err = s.GuildMember(guildID, value)
+		}
+		if err == nil {
+			return []*discordgo.Member{member}, nil
+		}
+	}
+
+	candidates := stateMembers(s, guildID)
+	members, exact := matchMembers(candidates, value)
+	if exact {
+		return members, nil
+	}
+
+	// the state only has the members discord sent, which is not every member of large guilds.
+	query := value
+	if match := userTagRegex.FindStringSubmatch(value); match != nil {
+		query = match[1]
+	}
+
+	found, err := searchMembers(s, guildID, query, memberSearchLimit)
+	if err != nil {
+		if len(members) != 0 {
+			return members, nil
+		}
+		return nil, err
+	}
+
+	// the members found by the search replace the ones from the state, they also have the display name.
+	seen := map[string]bool{}
+	for _, v := range found {
+		seen[v.Member.User.ID] = true
+	}
+	for _, v := range candidates {
+		if !seen[v.Member.User.ID] {
+			found = append(found, v)
+		}
+	}
+
+	members, _ = matchMembers(found, value)
+	return members, nil
+}
+
+// searchMembers searches the members of a guild whose username or nickname start with query.
+func searchMembers(s *discordgo.Session, guildID string, query string, limit int) ([]memberCandidate, error) {
+	uri := discordgo.EndpointGuildMembers(guildID) + "/search?" + url.Values{
+		"query": {query},
+		"limit": {strconv.Itoa(limit)},
+	}.Encode()
+
+	body, err := s.RequestWithBucketID("GET", uri, nil, discordgo.EndpointGuildMembers(guildID)+"/search")
+	if err != nil {
+		return nil, err
+	}
+
+	result := []searchMember{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]memberCandidate, 0, len(result))
+	for _, v := range result {
+		if v.User == nil {
+			continue
+		}
+
+		member := v.Member
+		user := v.User.User
+		member.User = &user
+		member.GuildID = guildID
+		if s.StateEnabled && s.State.TrackMembers {
+			_ = s.State.MemberAdd(&member)
+		}
+
+		candidates = append(candidates, memberCandidate{Member: &member, GlobalName: v.User.GlobalName})
+	}
+
+	return candidates, nil
+}
+
+func stateMembers(s *discordgo.Session, guildID string) []memberCandidate {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return nil
+	}
+
+	s.State.RLock()
+	defer s.State.RUnlock()
+
+	candidates := make([]memberCandidate, 0, len(guild.Members))
+	for _, v := range guild.Members {
+		if v.User != nil {
+			candidates = append(candidates, memberCandidate{Member: v})
+		}
+	}
+
+	return candidates
+}
+
+// matchMembers returns the candidates with a name equal to value, or when there are none the ones whose names start
+// with value and then the ones whose names contain it. exact is true when the members are exact matches.
+func matchMembers(candidates []memberCandidate, value string) (members []*discordgo.Member, exact bool) {
+	value = strings.ToLower(value)
+	if value == "" {
+		return nil, false
+	}
+
+	tiers := [3][]memberCandidate{}
+	for _, v := range candidates {
+		if tier := v.matchTier(value); tier != -1 {
+			tiers[tier] = append(tiers[tier], v)
+		}
+	}
+
+	for n, tier := range tiers {
+		if len(tier) == 0 {
+			continue
+		}
+
+		sort.SliceStable(tier, func(i, j int) bool {
+			return len(tier[i].Member.User.Username) < len(tier[j].Member.User.Username)
+		})
+
+		members = make([]*discordgo.Member, len(tier))
+		for j, v := range tier {
+			members[j] = v.Member
+		}
+		return members, n == 0
+	}
+
+	return nil, false
+}
+
+// matchTier is 0 when a name of the candidate equals value, 1 when one starts with it, 2 when one contains it and -1 otherwise.
+func (c memberCandidate) matchTier(value string) int {
+	tier := -1
+	for _, name := range c.names() {
+		t := -1
+		switch {
+		case name == value:
+			t = 0
+		case strings.HasPrefix(name, value):
+			t = 1
+		case strings.Contains(name, value):
+			t = 2
+		}
+
+		if t != -1 && (tier == -1 || t < tier) {
+			tier = t
+		}
+	}
+
+	return tier
+}
+
+// names are the lower case names a member can be referred to by.
+func (c memberCandidate) names() []string {
+	user := c.Member.User
+	names := []string{strings.ToLower(user.Username)}
+	if user.Discriminator != "" && user.Discriminator != "0" {
+		names = append(names, strings.ToLower(user.Username+"#"+user.Discriminator))
+	}
+	if c.GlobalName != "" {
+		names = append(names, strings.ToLower(c.GlobalName))
+	}
+	if c.Member.Nick != "" {
+		names = append(names, strings.ToLower(c.Member.Nick))
+	}
+
+	return names
+}
+
+// MemberLabel is how a member is shown in lists, the nickname followed by the username.
+func MemberLabel(member *discordgo.Member) string {
+	name := member.User.Username
+	if member.User.Discriminator != "" && member.User.Discriminator != "0" {
+		name = member.User.String()
+	}
+
+	if member.Nick != "" {
+		return fmt.Sprintf("%s (%s)", member.Nick, name)
+	}
+
+	return name
+}
@@ -0,0 +1,201 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrCommandTimeout = errors.New("command timed out")
+	ErrCommandPanic   = errors.New("command panicked")
+)
+
+// Middleware wraps the execution of a command, next executes the rest of the chain.
+type Middleware func(ctx *Context, next func() error) error
+
+// Run executes exec wrapped by middlewares, the first middleware is the outermost.
+func Run(ctx *Context, middlewares []Middleware, exec func() error) error {
+	next := exec
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		mw, inner := middlewares[i], next
+		next = func() error {
+			return mw(ctx, inner)
+		}
+	}
+
+	return next()
+}
+
+// Log returns a logger with the fields of the command execution, the correlation id is shown to the invoker on errors.
+func Log(ctx *Context) *logrus.Entry {
+	fields := logrus.Fields{
+		"correlation_id": ctx.ID,
+		"guild_id":       ctx.GuildID,
+		"channel_id":     ctx.ChannelID,
+	}
+	if ctx.Author != nil {
+		fields["user_id"] = ctx.Author.ID
+		fields["user"] = ctx.Author.String()
+	}
+	if ctx.Command != nil {
+		fields["command"] = ctx.Command.Name()
+	}
+
+	return logrus.WithFields(fields)
+}
+
+// LoggingMiddleware logs every execution with its duration and error.
+func LoggingMiddleware() Middleware {
+	return func(ctx *Context, next func() error) error {
+		start := time.Now()
+		err := next()
+
+		log := Log(ctx).WithField("duration", time.Since(start)/time.Millisecond)
+		switch err.(type) {
+		case nil:
+			log.Debug("command executed")
+		case *UsageError:
+			log.Info("command used incorrectly: ", err)
+		default:
+			log.Error("command failed: ", err)
+		}
+
+		return err
+	}
+}
+
+// MetricsMiddleware counts the executions of every command and observes how long they took.
+func MetricsMiddleware(executions func() *prometheus.CounterVec, duration func() *prometheus.HistogramVec) Middleware {
+	return func(ctx *Context, next func() error) error {
+		start := time.Now()
+		err := next()
+
+		name := "unknown"
+		if ctx.Command != nil {
+			name = ctx.Command.Name()
+		}
+
+		status := "success"
+		switch err.(type) {
+		case nil:
+		case *UsageError:
+			status = "invalid_usage"
+		default:
+			status = "error"
+		}
+
+		executions().WithLabelValues(name, status).Inc()
+		duration().WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// DeferMiddleware defers interactions which were not answered after the given time, so that commands which are slow
+// because of lookups or their work still reply instead of discord dropping the interaction.
+func DeferMiddleware(after time.Duration) Middleware {
+	return func(ctx *Context, next func() error) error {
+		if !ctx.IsInteraction() {
+			return next()
+		}
+
+		timer := time.AfterFunc(after, func() {
+			if err := ctx.Defer(); err != nil {
+				Log(ctx).Error("failed to defer interaction: ", err)
+			}
+		})
+		defer timer.Stop()
+
+		return next()
+	}
+}
+
+// TimeoutMiddleware stops waiting for a command after timeout, the command itself keeps running in the background.
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(ctx *Context, next func() error) error {
+		done := make(chan error, 1)
+		go func() {
+			start := time.Now()
+			err := next()
+			if time.Since(start) > timeout {
+				Log(ctx).WithField("duration", time.Since(start)/time.Millisecond).Warn("command finished after timing out: ", err)
+			}
+			done <- err
+		}()
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case err := <-done:
+			return err
+		case <-timer.C:
+			return ErrCommandTimeout
+		}
+	}
+}
+
+// RecoverMiddleware turns a panic of a command into an error, it has to run in the same goroutine as the command.
+func RecoverMiddleware() Middleware {
+	return func(ctx *Context, next func() error) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				Log(ctx).WithField("stack", string(debug.Stack())).Error("command panicked: ", r)
+				err = fmt.Errorf("%w: %v", ErrCommandPanic, r)
+			}
+		}()
+
+		return next()
+	}
+}
+
+// PermissionMiddleware checks the permissions of every command in ctx.Chain and audits restricted commands.
+func PermissionMiddleware(adminRoles func(guildID string) []string) Middleware {
+	return func(ctx *Context, next func() error) error {
+		restricted := false
+		for _, v := range ctx.Chain {
+			perm := v.Permission()
+			restricted = restricted || perm.restricted()
+
+			ok, err := perm.Allowed(ctx, adminRoles(ctx.GuildID))
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				Log(ctx).Info("audit: command denied")
+				return ctx.ReplyError("You are not allowed to use this command.")
+			}
+		}
+
+		err := next()
+		if err == nil && restricted {
+			Log(ctx).Info("audit: command executed")
+		}
+
+		return err
+	}
+}
+
+// ErrorEmbed is the reply to a command which failed, id is the correlation id of the log line.
+func ErrorEmbed(id string, err error) *discordgo.MessageEmbed {
+	description := "Something went wrong while running this command."
+	if errors.Is(err, ErrCommandTimeout) {
+		description = "This command took too long to respond, it might still finish in the background."
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "Command failed",
+		Description: description,
+		Color:       0xe74c3c,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Error ID: %s", id),
+		},
+	}
+}
@@ -0,0 +1,141 @@
+package command
+
+import (
+	"github.com/AdmiralBulldogTv/DiscordBot/src/utils"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Permission is the requirement a member has to meet to run a command.
+// A member is allowed when they are an administrator and Administrator is set, or when they have one of the Roles.
+// A permission without Administrator and Roles allows everyone.
+type Permission struct {
+	// Administrator allows members with the administrator permission or one of the admin roles.
+	Administrator bool
+	// Roles allows members with one of the returned roles, it is a func so that roles are read from the config of the guild.
+	Roles func(guildID string) []string
+	// Channels limits the command to the returned channels, administrators are not limited.
+	Channels func(guildID string) []string
+}
+
+// AdminPermission is the permission of commands only administrators can run.
+var AdminPermission = Permission{Administrator: true}
+
+// RolePermission allows administrators and members with one of the roles returned by roles.
+func RolePermission(roles func(guildID string) []string) Permission {
+	return Permission{
+		Administrator: true,
+		Roles:         roles,
+	}
+}
+
+func (p Permission) restricted() bool {
+	return p.Administrator || p.Roles != nil || p.Channels != nil
+}
+
+// Allowed checks if the invoker of ctx meets the permission, adminRoles are the roles which count as administrator.
+func (p Permission) Allowed(ctx *Context, adminRoles []string) (bool, error) {
+	if !p.restricted() {
+		return true, nil
+	}
+
+	roles := []string{}
+	if ctx.Member != nil {
+		roles = ctx.Member.Roles
+	}
+
+	mp := map[string]bool{}
+	for _, v := range roles {
+		mp[v] = true
+	}
+
+	perms, err := ctx.Permissions()
+	if err != nil {
+		return false, err
+	}
+
+	isAdmin := perms&discordgo.PermissionAdministrator != 0
+	for _, v := range adminRoles {
+		if isAdmin {
+			break
+		}
+		isAdmin = mp[v]
+	}
+
+	if p.Channels != nil && !isAdmin && !utils.Contains(p.Channels(ctx.GuildID), ctx.ChannelID) {
+		return false, nil
+	}
+
+	if !p.Administrator && p.Roles == nil {
+		return true, nil
+	}
+
+	if p.Administrator && isAdmin {
+		return true, nil
+	}
+
+	if p.Roles != nil {
+		for _, v := range p.Roles(ctx.GuildID) {
+			if mp[v] {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// Allowed checks the permission of cmd and of every sub command along path.
+func Allowed(ctx *Context, cmd Cmd, path []string, adminRoles []string) (bool, error) {
+	for _, v := range Chain(cmd, path) {
+		ok, err := v.Permission().Allowed(ctx, adminRoles)
+		if err != nil || !ok {
+			return ok, err
+		}
+	}
+
+	return true, nil
+}
+
+// Chain returns cmd followed by the sub commands path resolves to, the same way CommandGroup executes them.
+func Chain(cmd Cmd, path []string) []Cmd {
+	chain := []Cmd{cmd}
+	for {
+		group, ok := cmd.(*CommandGroup)
+		if !ok {
+			return chain
+		}
+
+		next := ""
+		if len(path) != 0 {
+			next = path[0]
+		}
+
+		if sub, ok := group.Commands[next]; ok && sub != nil {
+			if !sub.Match(path) {
+				return chain
+			}
+			cmd = sub
+			path = path[1:]
+		} else if group.DefaultComnmnd != nil && group.DefaultComnmnd.Match(path) {
+			cmd = group.DefaultComnmnd
+		} else {
+			return chain
+		}
+
+		chain = append(chain, cmd)
+	}
+}
+
+// Lookup returns the sub command of cmd which path names, it is nil when a part of path is not a sub command.
+func Lookup(cmd Cmd, path []string) Cmd {
+	for _, name := range path {
+		group, ok := cmd.(*CommandGroup)
+		if !ok || group.Commands[name] == nil {
+			return nil
+		}
+
+		cmd = group.Commands[name]
+	}
+
+	return cmd
+}
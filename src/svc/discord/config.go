@@ -0,0 +1,280 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/configure"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/bwmarrin/discordgo"
+)
+
+// configCmd edits the config of the guild it is used in, the yaml config provides the defaults.
+func (d *discordInstsnce) configCmd() command.Cmd {
+	return &command.CommandGroup{
+		NameCmd: func() string {
+			return "config"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "config")
+		},
+		Info:        "Manage the settings of this server",
+		UsageInfo:   "<command>",
+		ExampleInfo: []string{"config show modules.points", "config set modules.points.hourly_limit 100"},
+		Perms:       command.AdminPermission,
+		Commands: map[string]command.Cmd{
+			"show":   d.configShowCmd(),
+			"set":    d.configSetCmd(),
+			"reset":  d.configResetCmd(),
+			"reload": d.configReloadCmd(),
+		},
+	}
+}
+
+func (d *discordInstsnce) configShowCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "config show"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "show")
+		},
+		Info:        "Show the settings, settings marked with * are changed from the defaults",
+		ExampleInfo: []string{"config show", "config show modules.inhouse"},
+		Args: []command.Arg{
+			{
+				Name:        "key",
+				Description: "The setting or the group of settings to show",
+				Type:        command.ArgString,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			prefix := strings.ToLower(ctx.Args.String("key"))
+			cfg := d.gCtx.Inst().Guilds.Config(ctx.GuildID)
+
+			lines := []string{}
+			for _, key := range configure.GuildKeys() {
+				if key != prefix && !strings.HasPrefix(key, prefix) {
+					continue
+				}
+
+				value, err := cfg.Value(key)
+				if err != nil {
+					return err
+				}
+
+				marker := ""
+				if d.gCtx.Inst().Guilds.Overridden(ctx.GuildID, key) {
+					marker = "*"
+				}
+
+				lines = append(lines, fmt.Sprintf("`%s`%s: %s", key, marker, formatValue(key, value)))
+			}
+
+			if len(lines) == 0 {
+				return ctx.ReplyError(fmt.Sprintf("Unknown setting `%s`.", prefix))
+			}
+
+			_, err := ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: &discordgo.MessageEmbed{
+					Title:       "Settings",
+					Description: truncateLines(lines, 4096),
+					Color:       ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID),
+					Footer: &discordgo.MessageEmbedFooter{
+						Text: fmt.Sprintf("Use %sconfig show <group> to see fewer settings", ctx.Prefix),
+					},
+				},
+			})
+			return err
+		},
+	}
+}
+
+func (d *discordInstsnce) configSetCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "config set"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "set")
+		},
+		Info: "Change a setting, lists are separated by commas and can be cleared with none",
+		ExampleInfo: []string{
+			"config set modules.points.hourly_limit 100",
+			"config set modules.inhouse.moderator_roles @Mods, @Admins",
+			"config set modules.points.roles 111772771016515584:500, 111772771016515585:1000",
+			"config set modules.common.dank_cooldown 30m",
+		},
+		Args: []command.Arg{
+			{
+				Name:        "key",
+				Description: "The setting to change",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+			{
+				Name:        "value",
+				Description: "The new value of the setting",
+				Type:        command.ArgText,
+				Required:    true,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			key := strings.ToLower(ctx.Args.String("key"))
+
+			value, err := configure.ParseValue(key, ctx.Args.String("value"))
+			if err == nil {
+				err = d.gCtx.Inst().Guilds.Set(d.gCtx, ctx.GuildID, key, value)
+			}
+			if err != nil {
+				return d.configError(ctx, key, err)
+			}
+
+			d.configChanged(key)
+
+			_, err = ctx.Reply(fmt.Sprintf("Set `%s` to %s.", key, formatValue(key, value)))
+			return err
+		},
+	}
+}
+
+func (d *discordInstsnce) configResetCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "config reset"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "reset")
+		},
+		Info:        "Change a setting back to its default",
+		ExampleInfo: []string{"config reset modules.points.hourly_limit"},
+		Args: []command.Arg{
+			{
+				Name:        "key",
+				Description: "The setting to reset",
+				Type:        command.ArgString,
+				Required:    true,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			key := strings.ToLower(ctx.Args.String("key"))
+
+			if err := d.gCtx.Inst().Guilds.Reset(d.gCtx, ctx.GuildID, key); err != nil {
+				return d.configError(ctx, key, err)
+			}
+
+			d.configChanged(key)
+
+			value, _ := d.gCtx.Inst().Guilds.Config(ctx.GuildID).Value(key)
+			_, err := ctx.Reply(fmt.Sprintf("Reset `%s` to %s.", key, formatValue(key, value)))
+			return err
+		},
+	}
+}
+
+func (d *discordInstsnce) configReloadCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "config reload"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "reload")
+		},
+		Info:        "Load the settings of every server from the database again",
+		ExampleInfo: []string{"config reload"},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			if err := d.gCtx.Inst().Guilds.Reload(d.gCtx); err != nil {
+				return err
+			}
+
+			d.configChanged("")
+
+			_, err := ctx.Reply("Reloaded the settings.")
+			return err
+		},
+	}
+}
+
+// configError replies to the errors caused by the invoker and returns every other error.
+func (d *discordInstsnce) configError(ctx *command.Context, key string, err error) error {
+	if errors.Is(err, configure.ErrUnknownKey) {
+		return ctx.ReplyError(fmt.Sprintf("Unknown setting `%s`, use `%sconfig show` to see the settings.", key, ctx.Prefix))
+	}
+
+	var validationErr *configure.ValidationError
+	if errors.As(err, &validationErr) {
+		return ctx.ReplyError(fmt.Sprintf("Invalid value for `%s`, %s.", validationErr.Key, validationErr.Reason))
+	}
+
+	return err
+}
+
+// configChanged syncs the slash commands when a change can enable or disable commands, an empty key is any change.
+func (d *discordInstsnce) configChanged(key string) {
+	if key != "" && !strings.HasSuffix(key, ".enabled") {
+		return
+	}
+
+	d.cmdsMtx.Lock()
+	d.scheduleSync()
+	d.cmdsMtx.Unlock()
+}
+
+// formatValue renders a setting for a message, roles are rendered as mentions.
+func formatValue(key string, value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return "none"
+		}
+		if configure.IsRoleKey(key) {
+			return fmt.Sprintf("<@&%s>", v)
+		}
+		if configure.IsChannelKey(key) {
+			return fmt.Sprintf("<#%s>", v)
+		}
+
+		return fmt.Sprintf("`%s`", v)
+	case time.Duration:
+		return v.String()
+	case []configure.ChannelMultiplier:
+		items := make([]string, len(v))
+		for i, c := range v {
+			items[i] = fmt.Sprintf("<#%s> x%g", c.ID, c.Multiplier)
+		}
+		if len(items) == 0 {
+			return "none"
+		}
+
+		return strings.Join(items, ", ")
+	case []configure.PointsRole:
+		items := make([]string, len(v))
+		for i, r := range v {
+			items[i] = fmt.Sprintf("<@&%s> at %d", r.ID, r.Points)
+		}
+		if len(items) == 0 {
+			return "none"
+		}
+
+		return strings.Join(items, ", ")
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice {
+		return fmt.Sprint(value)
+	}
+
+	if rv.Len() == 0 {
+		return "none"
+	}
+
+	items := make([]string, rv.Len())
+	for i := range items {
+		items[i] = formatValue(key, rv.Index(i).Interface())
+	}
+
+	return strings.Join(items, ", ")
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/textproto"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,16 +15,32 @@ import (
 	"github.com/AdmiralBulldogTv/DiscordBot/src/instance"
 	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
 	"github.com/bwmarrin/discordgo"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/writer"
 )
 
+const (
+	// commandTimeout is how long a command is waited for before the invoker is told it failed.
+	commandTimeout = time.Minute * 2
+	// deferAfter is how long a slash command can take before it is deferred, discord waits 3 seconds for a response.
+	deferAfter = time.Second * 2
+)
+
 type discordInstsnce struct {
 	discord *discordgo.Session
 	gCtx    global.Context
 	done    chan struct{}
 	cmdsMtx sync.Mutex
 	cmds    map[string]command.Cmd
+	syncer  *time.Timer
+
+	// aliases are declared by the commands, customAliases are added at runtime and stored in mongo per guild.
+	// both map the alias to the path it expands to.
+	aliases       map[string][]string
+	customAliases map[string]map[string][]string
+
+	middlewares []command.Middleware
 }
 
 func New(gCtx global.Context) instance.Discord {
@@ -37,11 +54,37 @@ func New(gCtx global.Context) instance.Discord {
 		gCtx:    gCtx,
 		done:    make(chan struct{}),
 		cmds:    map[string]command.Cmd{},
+
+		aliases:       map[string][]string{},
+		customAliases: map[string]map[string][]string{},
+	}
+	d.cmds["help"] = d.helpCmd()
+	d.cmds["alias"] = d.aliasCmd()
+	d.cmds["config"] = d.configCmd()
+	// recover has to be inside of timeout since the command runs in its own goroutine after it.
+	d.middlewares = []command.Middleware{
+		command.LoggingMiddleware(),
+		command.MetricsMiddleware(func() *prometheus.CounterVec {
+			return gCtx.Inst().Prometheus.CommandExecutions()
+		}, func() *prometheus.HistogramVec {
+			return gCtx.Inst().Prometheus.CommandDuration()
+		}),
+		command.DeferMiddleware(deferAfter),
+		command.TimeoutMiddleware(commandTimeout),
+		command.RecoverMiddleware(),
+		command.PermissionMiddleware(func(guildID string) []string {
+			return gCtx.Inst().Guilds.Config(guildID).AdminRoles
+		}),
+		command.CooldownMiddleware(func() command.CooldownStore {
+			return gCtx.Inst().Redis
+		}),
 	}
 
 	discord.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsAll)
 
 	discord.AddHandler(d.messageCreate)
+	discord.AddHandler(d.interactionCreate)
+	discord.AddHandler(d.guildCreate)
 
 	if err := discord.Open(); err != nil {
 		logrus.Fatal("failed to open discord bot: ", err)
@@ -57,6 +100,10 @@ func New(gCtx global.Context) instance.Discord {
 
 	d.initLogger()
 
+	if err := d.loadAliases(); err != nil {
+		logrus.Error("failed to load command aliases: ", err)
+	}
+
 	return d
 }
 
@@ -73,8 +120,28 @@ func (d *discordInstsnce) RegisterCommand(prefix string, cmd command.Cmd) error
 	if _, ok := d.cmds[prefix]; ok {
 		return command.ErrCommandAlreadyExists
 	}
+	if _, ok := d.aliases[prefix]; ok {
+		return command.ErrCommandAlreadyExists
+	}
+
+	aliases := []string{}
+	for _, v := range cmd.Aliases() {
+		v = strings.ToLower(v)
+		if _, ok := d.cmds[v]; ok {
+			return command.ErrCommandAlreadyExists
+		}
+		if _, ok := d.aliases[v]; ok {
+			return command.ErrCommandAlreadyExists
+		}
+		aliases = append(aliases, v)
+	}
 
 	d.cmds[prefix] = cmd
+	for _, v := range aliases {
+		d.aliases[v] = []string{prefix}
+	}
+
+	d.scheduleSync()
 	return nil
 }
 
@@ -89,9 +156,73 @@ func (d *discordInstsnce) DeregisterCommand(prefix string, cmd command.Cmd) erro
 	}
 
 	delete(d.cmds, prefix)
+	for k, v := range d.aliases {
+		if v[0] == prefix {
+			delete(d.aliases, k)
+		}
+	}
+
+	d.scheduleSync()
 	return nil
 }
 
+// scheduleSync debounces syncing the slash commands since modules register their commands one after another.
+// cmdsMtx must be held when calling this.
+func (d *discordInstsnce) scheduleSync() {
+	if d.syncer != nil {
+		d.syncer.Stop()
+	}
+
+	d.syncer = time.AfterFunc(time.Second*5, d.syncCommands)
+}
+
+func (d *discordInstsnce) syncCommands() {
+	d.discord.State.RLock()
+	guildIDs := make([]string, len(d.discord.State.Guilds))
+	for i, v := range d.discord.State.Guilds {
+		guildIDs[i] = v.ID
+	}
+	d.discord.State.RUnlock()
+
+	for _, v := range guildIDs {
+		d.syncGuildCommands(v)
+	}
+}
+
+// syncGuildCommands registers the commands which are enabled in a guild as its slash commands.
+func (d *discordInstsnce) syncGuildCommands(guildID string) {
+	d.cmdsMtx.Lock()
+	cmds := make([]*discordgo.ApplicationCommand, 0, len(d.cmds))
+	for prefix, cmd := range d.cmds {
+		if cmd.Enabled(guildID) {
+			cmds = append(cmds, command.ApplicationCommand(prefix, cmd))
+		}
+	}
+	d.cmdsMtx.Unlock()
+
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+
+	if _, err := d.discord.ApplicationCommandBulkOverwrite(d.discord.State.User.ID, guildID, cmds); err != nil {
+		logrus.WithField("guild_id", guildID).Error("failed to sync application commands: ", err)
+		return
+	}
+
+	logrus.WithField("guild_id", guildID).Infof("synced %d application commands", len(cmds))
+}
+
+func (d *discordInstsnce) guildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	if err := d.gCtx.Inst().Guilds.Join(d.gCtx, g.Guild); err != nil {
+		logrus.WithField("guild_id", g.ID).Error("failed to store guild: ", err)
+	}
+
+	// guilds are created one after another on startup, so they share a single sync.
+	d.cmdsMtx.Lock()
+	d.scheduleSync()
+	d.cmdsMtx.Unlock()
+}
+
 func (d *discordInstsnce) SendMessage(channelID string, msg *discordgo.MessageSend) (*discordgo.Message, error) {
 	return d.discord.ChannelMessageSendComplex(channelID, msg)
 }
@@ -110,34 +241,158 @@ func (d *discordInstsnce) Member(guildID string, userID string) (*discordgo.Memb
 	return d.discord.GuildMember(guildID, userID)
 }
 
+func (d *discordInstsnce) Session() *discordgo.Session {
+	return d.discord
+}
+
 func (d *discordInstsnce) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.ID == s.State.User.ID || m.Author.Bot || m.GuildID != d.gCtx.Config().Discord.GuildID {
+	if m.Author.ID == s.State.User.ID || m.Author.Bot || m.GuildID == "" {
 		return
 	}
 
-	if !strings.HasPrefix(m.Content, "!") {
+	prefix := d.matchPrefix(s, m.GuildID, m.Content)
+	if prefix == "" {
 		return
 	}
 
-	path := strings.Split(m.Content[1:], " ")
+	path := command.Split(m.Content[len(prefix):])
+	if len(path) == 0 {
+		return
+	}
+
+	// commands are executed without holding the lock since they might look up other commands.
+	cmd, path := d.resolve(m.GuildID, path)
+	if cmd == nil || !cmd.Enabled(m.GuildID) || !cmd.Match(path) {
+		return
+	}
+
+	// mentions need a space after them when they are shown in usages.
+	if strings.HasPrefix(prefix, "<@") {
+		prefix += " "
+	}
+
+	_ = d.execute(command.NewMessageContext(s, m, prefix), cmd, path)
+}
+
+// matchPrefix returns the prefix content starts with, the longest prefix wins so that prefixes can share a start.
+func (d *discordInstsnce) matchPrefix(s *discordgo.Session, guildID string, content string) string {
+	cfg := d.gCtx.Inst().Guilds.Config(guildID)
+
+	prefixes := append([]string{}, cfg.Prefixes...)
+	if len(prefixes) == 0 {
+		prefixes = []string{"!"}
+	}
+	if cfg.MentionPrefix {
+		prefixes = append(prefixes, fmt.Sprintf("<@%s>", s.State.User.ID), fmt.Sprintf("<@!%s>", s.State.User.ID))
+	}
 
+	match := ""
+	for _, v := range prefixes {
+		if v != "" && strings.HasPrefix(content, v) && len(v) > len(match) {
+			match = v
+		}
+	}
+
+	return match
+}
+
+// resolve looks up the command path refers to and expands aliases, path is returned with the name of the command as the first element.
+func (d *discordInstsnce) resolve(guildID string, path []string) (command.Cmd, []string) {
 	d.cmdsMtx.Lock()
 	defer d.cmdsMtx.Unlock()
-	if cmd, ok := d.cmds[strings.ToLower(path[0])]; ok {
+
+	name := strings.ToLower(path[0])
+	if cmd, ok := d.cmds[name]; ok {
+		return cmd, append([]string{name}, path[1:]...)
+	}
+
+	target, ok := d.aliases[name]
+	if !ok {
+		target, ok = d.customAliases[guildID][name]
+	}
+	if !ok {
+		return nil, path
+	}
+
+	return d.cmds[target[0]], append(append([]string{}, target...), path[1:]...)
+}
+
+func (d *discordInstsnce) interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID == "" {
+		return
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		// the only options with autocomplete are member options.
+		search := ""
+		if focused := command.FocusedOption(i.ApplicationCommandData().Options); focused != nil {
+			search = fmt.Sprint(focused.Value)
+		}
+
+		if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+			Data: &discordgo.InteractionResponseData{
+				Choices: command.MemberChoices(s, i.GuildID, search),
+			},
+		}); err != nil {
+			logrus.Error("failed to respond to autocomplete: ", err)
+		}
+	case discordgo.InteractionApplicationCommand:
+		data := i.ApplicationCommandData()
+
+		d.cmdsMtx.Lock()
+		cmd, ok := d.cmds[strings.ToLower(data.Name)]
+		d.cmdsMtx.Unlock()
+		if !ok || !cmd.Enabled(i.GuildID) {
+			return
+		}
+
+		ctx := command.NewInteractionContext(s, i)
+		path := command.InteractionPath(cmd, data)
+
+		err := command.ErrCommandNotFound
 		if cmd.Match(path) {
-			err := cmd.Execute(s, m, path[1:])
-			switch err {
-			case command.ErrCommandNotFound:
-				logrus.Info("command not found")
-			case nil:
-				return
-			default:
-				logrus.Info("internal server error: ", err)
+			err = d.execute(ctx, cmd, path)
+		}
+
+		// interactions must always be answered otherwise discord shows the command as failed.
+		if !ctx.Replied() {
+			content := "Done."
+			if err != nil {
+				content = "Something went wrong while running this command."
+			}
+			if err := ctx.ReplyError(content); err != nil {
+				command.Log(ctx).Error("failed to respond to interaction: ", err)
 			}
 		}
 	}
 }
 
+// execute runs cmd through the middlewares and replies to the invoker when it failed, path still contains the name of the command.
+func (d *discordInstsnce) execute(ctx *command.Context, cmd command.Cmd, path []string) error {
+	ctx.Chain = command.Chain(cmd, path[1:])
+	ctx.Command = ctx.Chain[len(ctx.Chain)-1]
+
+	err := command.Run(ctx, d.middlewares, func() error {
+		return cmd.Execute(ctx, path[1:])
+	})
+
+	switch e := err.(type) {
+	case nil:
+	case *command.UsageError:
+		return ctx.ReplyUsage(e.Reason)
+	default:
+		if _, err := ctx.ReplyEphemeral(&discordgo.MessageSend{
+			Embed: command.ErrorEmbed(ctx.ID, e),
+		}); err != nil {
+			command.Log(ctx).Error("failed to reply with error: ", err)
+		}
+	}
+
+	return err
+}
+
 func (d *discordInstsnce) initLogger() {
 	if !d.gCtx.Config().Discord.Logging.Enabled {
 		return
@@ -0,0 +1,226 @@
+package discord
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/discord/command"
+	"github.com/bwmarrin/discordgo"
+)
+
+// helpCmd lists the commands the invoker is allowed to run or shows the details of a single command.
+func (d *discordInstsnce) helpCmd() command.Cmd {
+	return &command.Command{
+		NameCmd: func() string {
+			return "help"
+		},
+		MatchCmd: func(path []string) bool {
+			return len(path) != 0 && strings.EqualFold(path[0], "help")
+		},
+		Info:        "Shows the commands you can use",
+		ExampleInfo: []string{"help", "help inhouse", "help inhouse join"},
+		Args: []command.Arg{
+			{
+				Name:        "command",
+				Description: "The command to show the details of",
+				Type:        command.ArgText,
+			},
+		},
+		ExecuteCmd: func(ctx *command.Context, path []string) error {
+			path = strings.Fields(strings.ToLower(ctx.Args.String("command")))
+
+			d.cmdsMtx.Lock()
+			cmds := make(map[string]command.Cmd, len(d.cmds))
+			for k, v := range d.cmds {
+				cmds[k] = v
+			}
+			d.cmdsMtx.Unlock()
+
+			var (
+				embed *discordgo.MessageEmbed
+				err   error
+			)
+			if len(path) == 0 {
+				embed, err = d.helpOverview(ctx, cmds)
+			} else {
+				embed, err = d.helpDetails(ctx, path)
+			}
+			if err != nil {
+				return err
+			}
+
+			if embed == nil {
+				return ctx.ReplyError(fmt.Sprintf("Unknown command `%s`, use `%shelp` to see the commands you can use.", strings.Join(path, " "), ctx.Prefix))
+			}
+
+			embed.Color = ctx.Session.State.UserColor(ctx.Author.ID, ctx.ChannelID)
+			_, err = ctx.ReplyComplex(&discordgo.MessageSend{
+				Embed: embed,
+			})
+			return err
+		},
+	}
+}
+
+func (d *discordInstsnce) helpOverview(ctx *command.Context, cmds map[string]command.Cmd) (*discordgo.MessageEmbed, error) {
+	prefixes := make([]string, 0, len(cmds))
+	for prefix := range cmds {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	lines := []string{}
+	for _, prefix := range prefixes {
+		cmdLines, err := d.helpLines(ctx, cmds[prefix], 0)
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, cmdLines...)
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "Commands",
+		Description: truncateLines(lines, 4096),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Use %shelp <command> to see the details of a command", ctx.Prefix),
+		},
+	}, nil
+}
+
+func (d *discordInstsnce) helpDetails(ctx *command.Context, path []string) (*discordgo.MessageEmbed, error) {
+	cmd, path := d.resolve(ctx.GuildID, path)
+	if cmd == nil || !cmd.Enabled(ctx.GuildID) {
+		return nil, nil
+	}
+
+	// every part of the path has to be a command, otherwise the rest would be treated as arguments.
+	leaf := command.Lookup(cmd, path[1:])
+	if leaf == nil {
+		return nil, nil
+	}
+
+	allowed, err := command.Allowed(ctx, cmd, path[1:], d.gCtx.Inst().Guilds.Config(ctx.GuildID).AdminRoles)
+	if err != nil || !allowed {
+		return nil, err
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       command.FormatUsage(ctx.Prefix, leaf),
+		Description: leaf.Description(),
+	}
+
+	if aliases := d.aliasesOf(ctx.GuildID, path); len(aliases) != 0 {
+		for i, v := range aliases {
+			aliases[i] = fmt.Sprintf("`%s%s`", ctx.Prefix, v)
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Aliases",
+			Value: strings.Join(aliases, ", "),
+		})
+	}
+
+	if examples := leaf.Examples(); len(examples) != 0 {
+		lines := make([]string, len(examples))
+		for i, v := range examples {
+			lines[i] = fmt.Sprintf("`%s%s`", ctx.Prefix, v)
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Examples",
+			Value: truncateLines(lines, 1024),
+		})
+	}
+
+	if group, ok := leaf.(*command.CommandGroup); ok {
+		lines := []string{}
+		for _, name := range sortedCommands(group) {
+			subLines, err := d.helpLines(ctx, group.Commands[name], 0)
+			if err != nil {
+				return nil, err
+			}
+
+			lines = append(lines, subLines...)
+		}
+
+		if len(lines) != 0 {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:  "Commands",
+				Value: truncateLines(lines, 1024),
+			})
+		}
+	}
+
+	return embed, nil
+}
+
+// helpLines returns a line for cmd and its sub commands, leaving out everything the invoker is not allowed to run.
+func (d *discordInstsnce) helpLines(ctx *command.Context, cmd command.Cmd, depth int) ([]string, error) {
+	if cmd == nil || !cmd.Enabled(ctx.GuildID) {
+		return nil, nil
+	}
+
+	allowed, err := cmd.Permission().Allowed(ctx, d.gCtx.Inst().Guilds.Config(ctx.GuildID).AdminRoles)
+	if err != nil || !allowed {
+		return nil, err
+	}
+
+	indent := ""
+	if depth != 0 {
+		indent = strings.Repeat("    ", depth-1) + "↳ "
+	}
+
+	line := fmt.Sprintf("%s`%s`", indent, command.FormatUsage(ctx.Prefix, cmd))
+	if cmd.Description() != "" {
+		line += " - " + cmd.Description()
+	}
+
+	group, ok := cmd.(*command.CommandGroup)
+	if !ok {
+		return []string{line}, nil
+	}
+
+	lines := []string{}
+	for _, name := range sortedCommands(group) {
+		subLines, err := d.helpLines(ctx, group.Commands[name], depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, subLines...)
+	}
+
+	// groups without any command the invoker can run are left out entirely.
+	if len(lines) == 0 && group.DefaultComnmnd == nil {
+		return nil, nil
+	}
+
+	return append([]string{line}, lines...), nil
+}
+
+func sortedCommands(group *command.CommandGroup) []string {
+	names := make([]string, 0, len(group.Commands))
+	for name := range group.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// truncateLines joins lines and drops the lines which do not fit into max characters.
+func truncateLines(lines []string, max int) string {
+	buf := strings.Builder{}
+	for _, v := range lines {
+		if buf.Len()+len(v)+1 > max-3 {
+			buf.WriteString("...")
+			break
+		}
+
+		buf.WriteString(v + "\n")
+	}
+
+	return strings.TrimSpace(buf.String())
+}
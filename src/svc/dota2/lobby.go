@@ -0,0 +1,70 @@
+package dota2
+
+import (
+	"context"
+
+	"github.com/Philipp15b/go-steam/v3/steamid"
+	"github.com/paralin/go-dota2/cso"
+	"github.com/paralin/go-dota2/protocol"
+)
+
+// CreateLobby leaves the current lobby and creates a practice lobby, the client moves itself out of the team slots.
+func (c *DotaClient) CreateLobby(ctx context.Context, details *protocol.CMsgPracticeLobbySetDetails) error {
+	if err := c.client.LeaveCreateLobby(ctx, details, true); err != nil {
+		return err
+	}
+
+	c.client.JoinLobbyTeam(protocol.DOTA_GC_TEAM_DOTA_GC_TEAM_PLAYER_POOL, 1)
+	return nil
+}
+
+// Lobby returns the lobby the client is in, nil when it is in none.
+func (c *DotaClient) Lobby() *protocol.CSODOTALobby {
+	container, err := c.client.GetCache().GetContainerForTypeID(uint32(cso.Lobby))
+	if err != nil {
+		return nil
+	}
+
+	lobby, _ := container.GetOne().(*protocol.CSODOTALobby)
+	return lobby
+}
+
+func (c *DotaClient) InviteLobbyMember(steamID uint64) {
+	c.client.InviteLobbyMember(steamid.SteamId(steamID))
+}
+
+// KickLobbyMemberFromTeam moves a member of the lobby back to the unassigned players.
+func (c *DotaClient) KickLobbyMemberFromTeam(accountID uint32) {
+	c.client.KickLobbyMemberFromTeam(accountID)
+}
+
+func (c *DotaClient) LaunchLobby() {
+	c.client.LaunchLobby()
+}
+
+// LeaveLobby leaves the current lobby, it is destroyed when destroy is set and the game did not start yet.
+func (c *DotaClient) LeaveLobby(ctx context.Context, destroy bool) error {
+	lobby := c.Lobby()
+	if lobby == nil {
+		return nil
+	}
+
+	if destroy && lobby.GetState() == protocol.CSODOTALobby_UI {
+		if _, err := c.client.DestroyLobby(ctx); err != nil {
+			return err
+		}
+	}
+
+	c.client.LeaveLobby()
+	return nil
+}
+
+// MatchDetails returns the details of a match, they are only available once the game is over.
+func (c *DotaClient) MatchDetails(ctx context.Context, matchID uint64) (*protocol.CMsgDOTAMatch, error) {
+	data, err := c.client.RequestMatchDetails(ctx, matchID)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.GetMatch(), nil
+}
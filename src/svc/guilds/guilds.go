@@ -0,0 +1,234 @@
+package guilds
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AdmiralBulldogTv/DiscordBot/src/configure"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/instance"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/structures"
+	"github.com/AdmiralBulldogTv/DiscordBot/src/svc/mongo"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SetupOptions struct {
+	Mongo instance.Mongo
+	// Primary is the guild which uses Defaults as is.
+	Primary  string
+	Defaults configure.Guild
+}
+
+type guildsInst struct {
+	mongo    instance.Mongo
+	primary  string
+	defaults configure.Guild
+
+	mtx       sync.RWMutex
+	overrides map[string]bson.Raw
+	configs   map[string]*configure.Guild
+}
+
+func New(ctx context.Context, opts SetupOptions) (instance.Guilds, error) {
+	g := &guildsInst{
+		mongo:     opts.Mongo,
+		primary:   opts.Primary,
+		defaults:  opts.Defaults,
+		overrides: map[string]bson.Raw{},
+		configs:   map[string]*configure.Guild{},
+	}
+
+	overrides, err := g.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	g.overrides = overrides
+
+	logrus.Infof("guilds, loaded %d overrides", len(overrides))
+
+	return g, nil
+}
+
+// load returns the config overrides of every guild.
+func (g *guildsInst) load(ctx context.Context) (map[string]bson.Raw, error) {
+	cur, err := g.mongo.Collection(mongo.CollectionNameGuilds).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	guilds := []structures.Guild{}
+	if err = cur.All(ctx, &guilds); err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]bson.Raw{}
+	for _, v := range guilds {
+		if len(v.Config) != 0 {
+			overrides[v.ID] = v.Config
+		}
+	}
+
+	return overrides, nil
+}
+
+func (g *guildsInst) Primary() string {
+	return g.primary
+}
+
+func (g *guildsInst) Config(guildID string) *configure.Guild {
+	g.mtx.RLock()
+	cfg, ok := g.configs[guildID]
+	g.mtx.RUnlock()
+	if ok {
+		return cfg
+	}
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	cfg = g.build(guildID)
+	g.configs[guildID] = cfg
+	return cfg
+}
+
+// build applies the overrides of a guild to its defaults, mtx must be held.
+func (g *guildsInst) build(guildID string) *configure.Guild {
+	cfg := g.base(guildID)
+
+	if raw, ok := g.overrides[guildID]; ok {
+		// decoding into an existing struct only replaces the fields which are in the document.
+		if err := bson.Unmarshal(raw, cfg); err != nil {
+			logrus.WithField("guild_id", guildID).Error("failed to decode guild config: ", err)
+		}
+	}
+
+	return cfg
+}
+
+// base returns the config of a guild without its overrides.
+func (g *guildsInst) base(guildID string) *configure.Guild {
+	cfg := &configure.Guild{}
+
+	// the defaults are deep copied so that overriding a slice does not change the defaults.
+	data, _ := json.Marshal(g.defaults)
+	_ = json.Unmarshal(data, cfg)
+
+	if guildID != g.primary {
+		cfg.AdminRoles = nil
+		cfg.Modules.Points.Enabled = false
+		cfg.Modules.Common.Enabled = false
+		cfg.Modules.GoodNight.Enabled = false
+		cfg.Modules.InHouse.Enabled = false
+		cfg.Modules.Tracker.Enabled = false
+	}
+
+	return cfg
+}
+
+func (g *guildsInst) Overridden(guildID string, key string) bool {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	raw, ok := g.overrides[guildID]
+	if !ok {
+		return false
+	}
+
+	_, err := raw.LookupErr(strings.Split(key, ".")...)
+	return err == nil
+}
+
+func (g *guildsInst) Set(ctx context.Context, guildID string, key string, value interface{}) error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	cfg := g.build(guildID)
+	if err := cfg.SetValue(key, value); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	return g.update(ctx, guildID, bson.M{
+		"$set": bson.M{
+			"config." + key: value,
+		},
+	})
+}
+
+func (g *guildsInst) Reset(ctx context.Context, guildID string, key string) error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	value, err := g.base(guildID).Value(key)
+	if err != nil {
+		return err
+	}
+
+	cfg := g.build(guildID)
+	if err = cfg.SetValue(key, value); err != nil {
+		return err
+	}
+	if err = cfg.Validate(); err != nil {
+		return err
+	}
+
+	return g.update(ctx, guildID, bson.M{
+		"$unset": bson.M{
+			"config." + key: 1,
+		},
+	})
+}
+
+// update applies an update to the stored guild and replaces its overrides with the result, mtx must be held.
+func (g *guildsInst) update(ctx context.Context, guildID string, update bson.M) error {
+	guild := structures.Guild{}
+	err := g.mongo.Collection(mongo.CollectionNameGuilds).FindOneAndUpdate(ctx, bson.M{
+		"_id": guildID,
+	}, update, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&guild)
+	if err != nil {
+		return err
+	}
+
+	g.overrides[guildID] = guild.Config
+	delete(g.configs, guildID)
+
+	return nil
+}
+
+func (g *guildsInst) Reload(ctx context.Context) error {
+	overrides, err := g.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	g.overrides = overrides
+	g.configs = map[string]*configure.Guild{}
+
+	return nil
+}
+
+func (g *guildsInst) Join(ctx context.Context, guild *discordgo.Guild) error {
+	_, err := g.mongo.Collection(mongo.CollectionNameGuilds).UpdateOne(ctx, bson.M{
+		"_id": guild.ID,
+	}, bson.M{
+		"$set": bson.M{
+			"name": guild.Name,
+		},
+		"$setOnInsert": bson.M{
+			"joined_at": time.Now(),
+		},
+	}, options.Update().SetUpsert(true))
+
+	return err
+}
@@ -6,4 +6,13 @@ const (
 	CollectionNameUsers           instance.MongoCollectionName = "users"
 	CollectionNameDotaGames       instance.MongoCollectionName = "dota_games"
 	CollectionNameDotaGamePlayers instance.MongoCollectionName = "dota_game_players"
+	CollectionNameCommandAliases  instance.MongoCollectionName = "command_aliases"
+	CollectionNameGuilds          instance.MongoCollectionName = "guilds"
+	CollectionNameMembers         instance.MongoCollectionName = "members"
+	CollectionNamePointsLedger    instance.MongoCollectionName = "points_ledger"
+	CollectionNamePointsSeasons   instance.MongoCollectionName = "points_seasons"
+	CollectionNameSeasonStandings instance.MongoCollectionName = "points_season_standings"
+	CollectionNameShopItems       instance.MongoCollectionName = "shop_items"
+	CollectionNameShopRedemptions instance.MongoCollectionName = "shop_redemptions"
+	CollectionNameInhouseMatches  instance.MongoCollectionName = "inhouse_matches"
 )
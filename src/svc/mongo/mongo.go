@@ -53,7 +53,8 @@ type IndexRef struct {
 }
 
 var (
-	ErrNoDocuments = mongo.ErrNoDocuments
+	ErrNoDocuments      = mongo.ErrNoDocuments
+	IsDuplicateKeyError = mongo.IsDuplicateKeyError
 )
 
 type (
@@ -7,10 +7,24 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type mon struct{}
+type mon struct {
+	commandExecutions *prometheus.CounterVec
+	commandDuration   *prometheus.HistogramVec
+}
 
 func (m *mon) Register(r prometheus.Registerer) {
-	r.MustRegister()
+	r.MustRegister(
+		m.commandExecutions,
+		m.commandDuration,
+	)
+}
+
+func (m *mon) CommandExecutions() *prometheus.CounterVec {
+	return m.commandExecutions
+}
+
+func (m *mon) CommandDuration() *prometheus.HistogramVec {
+	return m.commandDuration
 }
 
 func LabelsFromKeyValue(kv []configure.KeyValue) prometheus.Labels {
@@ -24,7 +38,19 @@ func LabelsFromKeyValue(kv []configure.KeyValue) prometheus.Labels {
 }
 
 func New(opts SetupOptions) instance.Prometheus {
-	return &mon{}
+	return &mon{
+		commandExecutions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "discord_bot_command_executions_total",
+			Help:        "The total number of executed commands by command and status",
+			ConstLabels: opts.Labels,
+		}, []string{"command", "status"}),
+		commandDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "discord_bot_command_duration_seconds",
+			Help:        "The time it took to execute a command",
+			ConstLabels: opts.Labels,
+			Buckets:     []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"command"}),
+	}
 }
 
 type SetupOptions struct {
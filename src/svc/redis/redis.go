@@ -12,6 +12,9 @@ import (
 
 const RedisPrefix = "discord-bot:"
 
+// delIfEqualsScript deletes a key only while it still has the expected value.
+var delIfEqualsScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`)
+
 type RedisInst struct {
 	client  *redis.Client
 	sub     *redis.PubSub
@@ -134,9 +137,17 @@ func (r *RedisInst) Ping(ctx context.Context) error {
 }
 
 func (r *RedisInst) Del(ctx context.Context, key string) (int, error) {
-	i, err := r.client.Del(ctx).Result()
+	i, err := r.client.Del(ctx, key).Result()
 	return int(i), err
 }
+
+// DelIfEquals deletes a key if it still holds value, locks use it so that a lock which expired and was taken by
+// someone else is not released.
+func (r *RedisInst) DelIfEquals(ctx context.Context, key string, value string) (bool, error) {
+	i, err := delIfEqualsScript.Run(ctx, r.client, []string{key}, value).Int()
+	return i > 0, err
+}
+
 func (r *RedisInst) Exists(ctx context.Context, key string) (bool, error) {
 	i, err := r.client.Exists(ctx, key).Result()
 	return i > 0, err
@@ -173,3 +184,14 @@ func (r *RedisInst) Pipeline(ctx context.Context) redis.Pipeliner {
 func (r *RedisInst) Expire(ctx context.Context, key string, ttl time.Duration) error {
 	return r.client.Expire(ctx, key, ttl).Err()
 }
+
+// Scan returns every key which matches a pattern.
+func (r *RedisInst) Scan(ctx context.Context, match string) ([]string, error) {
+	keys := []string{}
+	iter := r.client.Scan(ctx, 0, match, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+
+	return keys, iter.Err()
+}